@@ -44,4 +44,38 @@ COMMENT ON COLUMN public.t_historical_records.video_seek IS '视频播放进度(
 COMMENT ON COLUMN public.t_historical_records.user_id IS '用户id';
 COMMENT ON COLUMN public.t_historical_records.change_time IS '更改时间';
 COMMENT ON COLUMN public.t_historical_records.video_name IS '文件名';
-COMMENT ON COLUMN public.t_historical_records.total_video_duration IS '视频总时长(单位S)';
\ No newline at end of file
+COMMENT ON COLUMN public.t_historical_records.total_video_duration IS '视频总时长(单位S)';
+
+-- public.t_bridge_usage definition
+-- Go 桥启动时也会按驱动自动建表，此处供手工初始化参考。
+
+CREATE TABLE IF NOT EXISTS t_bridge_usage (
+                                      identity varchar(128) NOT NULL, -- 鉴权身份名称
+                                      target_host varchar(255) NOT NULL, -- 代理目标主机
+                                      period_type varchar(8) NOT NULL, -- 统计周期类型 day/month
+                                      period varchar(16) NOT NULL, -- 周期值，如 2024-05-01 或 2024-05
+                                      bytes int8 NOT NULL DEFAULT 0, -- 代理字节数
+                                      requests int8 NOT NULL DEFAULT 0, -- 请求次数
+                                      updated_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                      CONSTRAINT t_bridge_usage_pk PRIMARY KEY (identity, target_host, period_type, period)
+);
+COMMENT ON TABLE public.t_bridge_usage IS 'Go 桥代理流量计量表';
+
+-- public.t_bridge_audit definition
+-- Go 桥写操作审计表，启动时同样会按驱动自动建表。
+CREATE TABLE IF NOT EXISTS t_bridge_audit (
+                                      id varchar(32) NOT NULL, -- 随机记录 ID
+                                      created_at timestamp NOT NULL, -- 记录时间（UTC）
+                                      identity varchar(128) NOT NULL, -- 鉴权身份名称
+                                      client_ip varchar(64), -- 客户端 IP
+                                      endpoint varchar(128) NOT NULL, -- 接口路由
+                                      table_name varchar(128), -- 目标表
+                                      where_clause text, -- 过滤条件或原始语句
+                                      args text, -- 绑定参数（JSON）
+                                      affected_rows int8 NOT NULL DEFAULT 0, -- 影响行数（截图同步为删除文件数）
+                                      outcome varchar(16) NOT NULL, -- ok / error / denied
+                                      error_message varchar(1000), -- 失败原因（已脱敏）
+                                      CONSTRAINT t_bridge_audit_pk PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_bridge_audit_created ON t_bridge_audit (created_at);
+COMMENT ON TABLE public.t_bridge_audit IS 'Go 桥写操作审计表';
@@ -2,7 +2,7 @@
 #
 # 用途:
 #   在最小化（-trimpath + -ldflags "-s -w"）的前提下编译 Go 数据库桥。
-#   可通过环境变量 GOOS / GOARCH / BIN_NAME 控制目标平台与输出文件名。
+#   可通过环境变量 GOOS / GOARCH / BIN_NAME 控制目标平台与输出文件名，VERSION 覆盖写入二进制的版本号。
 #
 # 示例:
 #   ./build_release.sh                # 本机平台
@@ -72,7 +72,13 @@ declare -a BUILD_ENV=()
 if [[ -n "${TARGET_OS}" ]]; then BUILD_ENV+=("GOOS=${TARGET_OS}"); fi
 if [[ -n "${TARGET_ARCH}" ]]; then BUILD_ENV+=("GOARCH=${TARGET_ARCH}"); fi
 
-declare -a BUILD_FLAGS=("-trimpath" "-ldflags" "-s -w")
+# 版本号默认取 git describe，可通过 VERSION 覆盖；提交号写入 /admin/status。
+VERSION="${VERSION:-$(git -C "${SCRIPT_DIR}" describe --tags --always --dirty 2>/dev/null || echo dev)}"
+COMMIT="$(git -C "${SCRIPT_DIR}" rev-parse HEAD 2>/dev/null || echo unknown)"
+BUILDINFO_PKG="github.com/zhouquan/webdav_video/go_bridge/internal/buildinfo"
+LDFLAGS="-s -w -X ${BUILDINFO_PKG}.Version=${VERSION} -X ${BUILDINFO_PKG}.Commit=${COMMIT}"
+
+declare -a BUILD_FLAGS=("-trimpath" "-ldflags" "${LDFLAGS}")
 if [[ -n "${GO_BUILD_TAGS:-}" ]]; then
   BUILD_FLAGS+=("-tags" "${GO_BUILD_TAGS}")
 fi
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/buildinfo"
+)
+
+// requireDatabase 表示当前构建是否需要数据库配置，与 serve 的校验保持一致。
+const requireDatabase = buildinfo.Mode == buildinfo.ModeFull
+
+// newCommandFlags 创建子命令的 FlagSet，usage 为位置参数说明。
+func newCommandFlags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags]%s\n\n", os.Args[0], name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// runCheckConfig 实现 `go_bridge check-config`：按 serve 相同的分层规则加载配置并校验，不连接数据库也不创建目录；
+// 校验失败时逐行输出全部问题及其位置。
+func runCheckConfig(args []string, out io.Writer) error {
+	fs := newCommandFlags("check-config", "")
+	flags := addConfigFlags(fs)
+	printConfig := fs.Bool("print", false, "also print the effective config with sources")
+	_ = fs.Parse(args)
+
+	opts := flags.options()
+	opts.CheckWritable = true
+	cfg, sources, err := appconfig.LoadWithSources(requireDatabase, opts)
+	var invalid *appconfig.ValidationError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			fmt.Fprintln(out, problem)
+		}
+		return fmt.Errorf("%d problem(s) found", len(invalid.Problems))
+	}
+	if err != nil {
+		return err
+	}
+	path, _ := opts.ConfigPath()
+	if _, err := os.Stat(path); err != nil {
+		path += " (not found, using defaults, env and flags)"
+	}
+	fmt.Fprintf(out, "config ok: %s\n", path)
+	if *printConfig {
+		return appconfig.PrintConfig(out, cfg, sources)
+	}
+	return nil
+}
+
+// runTokenHash 实现 `go_bridge token hash`：输出可写入 tokens[].secretHash 的摘要。
+// 未给出明文时从标准输入读取一行，避免令牌留在 shell 历史中。
+func runTokenHash(args []string, out io.Writer) error {
+	fs := newCommandFlags("token hash", " [secret]")
+	generate := fs.Bool("generate", false, "generate a random secret and print it together with its hash")
+	positional := parseInterspersed(fs, args)
+
+	var secret string
+	switch {
+	case *generate:
+		if len(positional) > 0 {
+			return errors.New("--generate does not take a secret")
+		}
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return err
+		}
+		secret = base64.RawURLEncoding.EncodeToString(buf)
+		fmt.Fprintf(out, "secret:     %s\n", secret)
+		fmt.Fprintf(out, "secretHash: %s\n", appconfig.HashTokenSecret(secret))
+		return nil
+	case len(positional) > 1:
+		return errors.New("expected at most one secret")
+	case len(positional) == 1:
+		secret = positional[0]
+	default:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		secret = strings.TrimRight(line, "\r\n")
+	}
+	if secret == "" {
+		return errors.New("secret is empty")
+	}
+	_, err := fmt.Fprintln(out, appconfig.HashTokenSecret(secret))
+	return err
+}
+
+// runProxyProbe 实现 `go_bridge proxy probe <url>`：按配置构建代理链路（含 hop mTLS 与自适应选路的并行链路），
+// 逐条读取目标的前若干字节并输出首包耗时与吞吐，全部链路失败时返回错误。
+func runProxyProbe(args []string, out io.Writer) error {
+	fs := newCommandFlags("proxy probe", " <url>")
+	flags := addConfigFlags(fs)
+	probeBytes := fs.Int64("bytes", 256<<10, "bytes to read from the target via Range")
+	timeout := fs.Duration("timeout", 10*time.Second, "timeout per route")
+	asJSON := fs.Bool("json", false, "print results as JSON")
+	positional := parseInterspersed(fs, args)
+	if len(positional) != 1 {
+		fs.Usage()
+		return errors.New("expected exactly one target url")
+	}
+	target, err := url.Parse(positional[0])
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("invalid target url %q", positional[0])
+	}
+
+	cfg, _, err := appconfig.LoadWithSources(false, flags.options())
+	if err != nil {
+		return err
+	}
+	registrar, err := newProxyRegistrar(cfg)
+	if err != nil {
+		return err
+	}
+	defer registrar.Close(context.Background())
+
+	results := registrar.Probe(context.Background(), target.String(), *probeBytes, *timeout)
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ROUTE\tHOPS\tSTATUS\tTTFB\tTHROUGHPUT\tBYTES\tERROR")
+		for _, r := range results {
+			hops := strings.Join(r.Hops, " -> ")
+			if hops == "" {
+				hops = "direct"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.0fms\t%.0fKB/s\t%d\t%s\n",
+				r.Route, hops, r.Status, r.TTFBMs, r.ThroughputKbps, r.Bytes, r.Error)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	for _, r := range results {
+		if r.Error == "" {
+			return nil
+		}
+	}
+	return errors.New("all routes failed")
+}
+
+// runVersion 实现 `go_bridge version`。
+func runVersion(args []string, out io.Writer) error {
+	fs := newCommandFlags("version", "")
+	_ = fs.Parse(args)
+	_, err := fmt.Fprintf(out, "go_bridge %s (commit %s, %s build, %s %s/%s)\n",
+		buildinfo.Version, buildinfo.CommitHash(), buildinfo.Mode, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return err
+}
@@ -0,0 +1,134 @@
+//go:build !proxy_only
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/database"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/audit"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/screenshot"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/usage"
+)
+
+// cliIdentity 为命令行执行写操作时审计记录中的身份。
+const cliIdentity = "cli"
+
+// runMigrate 实现 `go_bridge migrate`：连接数据库并创建桥接自有的表（审计、流量计量），已存在的表保持不变。
+func runMigrate(args []string, out io.Writer) error {
+	fs := newCommandFlags("migrate", "")
+	flags := addConfigFlags(fs)
+	_ = fs.Parse(args)
+
+	cfg, _, err := appconfig.LoadWithSources(true, flags.options())
+	if err != nil {
+		return err
+	}
+	db, err := database.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	steps := []struct {
+		name    string
+		migrate func(context.Context, *sqlx.DB) error
+	}{
+		{"audit", audit.Migrate},
+		{"usage", usage.Migrate},
+	}
+	for _, step := range steps {
+		if err := step.migrate(ctx, db); err != nil {
+			return fmt.Errorf("%s: %s", step.name, cfg.Redact(err.Error()))
+		}
+		fmt.Fprintf(out, "%s: ok\n", step.name)
+	}
+	return nil
+}
+
+// runScreenshotsSync 实现 `go_bridge screenshots sync`：与 /history/screenshot/sync 相同，默认只预览孤立截图，
+// --apply 时删除并清理空目录；执行结果写入审计记录，身份为 cli。
+func runScreenshotsSync(args []string, out io.Writer) error {
+	fs := newCommandFlags("screenshots sync", "")
+	flags := addConfigFlags(fs)
+	apply := fs.Bool("apply", false, "delete the orphan files instead of only listing them")
+	previewLimit := fs.Int("preview-limit", 0, "how many orphan files to list (default 50, max 2000)")
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	_ = fs.Parse(args)
+
+	cfg, _, err := appconfig.LoadWithSources(true, flags.options())
+	if err != nil {
+		return err
+	}
+	db, err := database.Connect(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	auditRegistrar, err := audit.NewRegistrar(db, cfg)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	result, err := screenshot.SyncOrphans(ctx, db, cfg.ScreenshotDir, !*apply, *previewLimit)
+	entry := audit.Entry{
+		Identity:     cliIdentity,
+		Endpoint:     "/history/screenshot/sync",
+		Args:         map[string]any{"dryRun": result.DryRun, "previewLimit": result.OrphanPreviewLimit},
+		AffectedRows: int64(result.DeletedFiles),
+		Outcome:      audit.OutcomeOK,
+	}
+	if err != nil {
+		entry.Outcome, entry.Error = audit.OutcomeError, err.Error()
+	} else {
+		entry.Args["orphanCandidates"] = result.OrphanCandidates
+	}
+	auditRegistrar.Recorder.Record(ctx, entry)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	fmt.Fprintf(out, "dir: %s\ncandidates: %d, orphans: %d, deleted: %d, skipped: %d (%dms)\n",
+		cfg.ScreenshotDir, result.TotalCandidates, result.OrphanCandidates, result.DeletedFiles,
+		result.SkippedEntries, result.DurationMillis)
+	if len(result.OrphanDetails) > 0 {
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "USER\tVIDEO SHA1\tFILE\tBYTES\tMODIFIED")
+		for _, o := range result.OrphanDetails {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%s\n", o.UserID, o.VideoSha1, o.RelativePath, o.SizeBytes, o.ModTime.Format(time.RFC3339))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	if result.OrphanOverflow > 0 {
+		fmt.Fprintf(out, "... %d more not listed (use --preview-limit)\n", result.OrphanOverflow)
+	}
+	for _, msg := range result.Errors {
+		fmt.Fprintf(out, "error: %s\n", msg)
+	}
+	if result.DryRun && result.OrphanCandidates > 0 {
+		fmt.Fprintf(out, "dry run: re-run with --apply to delete %d file(s)\n", result.OrphanCandidates)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build proxy_only
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// errNoDatabase 为仅代理包中调用依赖数据库的子命令时的错误。
+var errNoDatabase = errors.New("not available in the proxy_only build (no database support)")
+
+func runMigrate([]string, io.Writer) error {
+	return errNoDatabase
+}
+
+func runScreenshotsSync([]string, io.Writer) error {
+	return errNoDatabase
+}
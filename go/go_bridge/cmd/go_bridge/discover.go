@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/discovery"
+)
+
+// runDiscover 实现 `go_bridge discover`：发送 UDP 广播探测与 mDNS 查询，列出局域网内的桥接节点。
+func runDiscover(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 2*time.Second, "how long to wait for replies")
+	port := fs.Int("port", appconfig.DefaultDiscoveryPort, "UDP probe port (negative to use mDNS only)")
+	noMDNS := fs.Bool("no-mdns", false, "only send the UDP broadcast probe")
+	asJSON := fs.Bool("json", false, "print nodes as JSON")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s discover [flags]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	nodes, err := discovery.Browse(ctx, discovery.BrowseOptions{UDPPort: *port, DisableMDNS: *noMDNS})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(nodes)
+	}
+	if len(nodes) == 0 {
+		_, err := fmt.Fprintln(out, "no bridge found")
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tADDRESS\tMODE\tTLS\tAUTH\tVERSION\tNODE ID\tVIA")
+	for _, node := range nodes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%t\t%s\t%s\t%s\n",
+			node.Name, fmt.Sprintf("%s:%d", node.Addr, node.Port), node.Mode, node.TLS, node.AuthRequired,
+			node.Version, node.NodeID, strings.Join(node.Via, ","))
+	}
+	return w.Flush()
+}
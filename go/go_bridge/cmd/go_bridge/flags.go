@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// overrideFlags 收集可重复的 --set key=value。
+type overrideFlags []string
+
+func (o *overrideFlags) String() string { return strings.Join(*o, ",") }
+
+func (o *overrideFlags) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+// cliOptions 为命令行解析结果。
+type cliOptions struct {
+	config      appconfig.Options
+	printConfig bool
+}
+
+// configFlags 为需要加载配置的子命令注册 --config、--set 与常用简写参数。
+type configFlags struct {
+	fs        *flag.FlagSet
+	path      string
+	listen    *string
+	driver    *string
+	dsn       *string
+	authToken *string
+	overrides overrideFlags
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	f := &configFlags{fs: fs}
+	fs.StringVar(&f.path, "config", "", "config file path (overrides GO_BRIDGE_CONFIG, default config.yaml)")
+	f.listen = fs.String("listen", "", "comma-separated listen addresses, e.g. :7788 or 127.0.0.1:7788,unix:///run/go_bridge.sock")
+	f.driver = fs.String("driver", "", "database driver: postgres, mysql or oracle")
+	f.dsn = fs.String("dsn", "", "database DSN")
+	f.authToken = fs.String("auth-token", "", "legacy shared auth token")
+	fs.Var(&f.overrides, "set", "override any config key, e.g. --set proxyChain.0.endpoint=https://hop (repeatable)")
+	return f
+}
+
+// options 在解析完成后组装命令行覆盖层：简写参数只有显式传入时才覆盖，空字符串同样视为有意设置。
+func (f *configFlags) options() appconfig.Options {
+	opts := appconfig.Options{Path: f.path}
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "listen":
+			opts.Overrides = append(opts.Overrides, "listen="+*f.listen)
+		case "driver":
+			opts.Overrides = append(opts.Overrides, "driver="+*f.driver)
+		case "dsn":
+			opts.Overrides = append(opts.Overrides, "dsn="+*f.dsn)
+		case "auth-token":
+			opts.Overrides = append(opts.Overrides, "authToken="+*f.authToken)
+		}
+	})
+	opts.Overrides = append(opts.Overrides, f.overrides...)
+	return opts
+}
+
+// parseFlags 解析 serve 的命令行参数：--listen 等常用项是对应 --set 的简写，命令行覆盖优先级最高。
+func parseFlags(args []string) cliOptions {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	var opts cliOptions
+	flags := addConfigFlags(fs)
+	fs.BoolVar(&opts.printConfig, "print-config", false, "print the effective config with sources and exit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [serve] [flags]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Config layers (later wins): defaults, config file, GO_BRIDGE_* env vars, flags.")
+		fs.PrintDefaults()
+		fmt.Fprintln(fs.Output())
+		printCommands(fs.Output())
+	}
+	_ = fs.Parse(args)
+	opts.config = flags.options()
+	return opts
+}
+
+// parseInterspersed 允许位置参数与选项交替出现（如 `proxy probe <url> --json`），返回全部位置参数。
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// fatal 记录启动失败原因后退出。
+func fatal(msg string, err error) {
+	// 配置校验失败时每条问题单独一行，避免多行报告在 JSON 日志中挤成一条转义字符串。
+	var invalid *appconfig.ValidationError
+	if errors.As(err, &invalid) {
+		for _, problem := range invalid.Problems {
+			slog.Error(msg, "problem", problem.String())
+		}
+		os.Exit(1)
+	}
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// loadConfig 按分层规则加载启动配置（含目录可写检查）；--print-config 时输出生效配置后退出。
+func loadConfig(opts cliOptions, requireDatabase bool) (appconfig.Config, error) {
+	options := opts.config
+	options.CheckWritable = true
+	cfg, sources, err := appconfig.LoadWithSources(requireDatabase, options)
+	if err != nil {
+		return appconfig.Config{}, err
+	}
+	if opts.printConfig {
+		if err := appconfig.PrintConfig(os.Stdout, cfg, sources); err != nil {
+			return appconfig.Config{}, err
+		}
+		os.Exit(0)
+	}
+	return cfg, nil
+}
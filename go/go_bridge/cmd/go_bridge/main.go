@@ -1,63 +1,80 @@
-//go:build !proxy_only
-
 package main
 
 import (
-	"errors"
-	"log"
-	"net/http"
+	"fmt"
+	"io"
+	"os"
 	"strings"
-
-	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
-	"github.com/zhouquan/webdav_video/go_bridge/internal/database"
-	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/proxy"
-	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/screenshot"
-	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/sqlapi"
-	"github.com/zhouquan/webdav_video/go_bridge/internal/server"
+	"text/tabwriter"
 )
 
-func main() {
-	cfg, err := appconfig.Load(true)
-	if err != nil {
-		log.Fatalf("load config: %v", err)
-	}
-	if err := cfg.EnsureScreenshotDir(); err != nil {
-		log.Fatalf("ensure screenshot dir: %v", err)
-	}
+// command 为一个子命令，name 可以是两级（如 `token hash`）。
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, out io.Writer) error
+}
 
-	db, err := database.Connect(cfg)
-	if err != nil {
-		log.Fatalf("connect db: %v", err)
+// commandTable 列出全部子命令；migrate 与 screenshots sync 依赖数据库，仅代理包中调用时报错。
+func commandTable() []command {
+	return []command{
+		{"serve", "start the bridge (default when no command is given)", runServe},
+		{"check-config", "load and validate the layered config without starting the server", runCheckConfig},
+		{"migrate", "create the bridge-owned tables (audit, usage) in the configured database", runMigrate},
+		{"screenshots sync", "preview orphan screenshots; --apply deletes them", runScreenshotsSync},
+		{"token hash", "print the secretHash for a token secret, or --generate a new one", runTokenHash},
+		{"proxy probe", "fetch the head of a URL through every configured proxy route", runProxyProbe},
+		{"discover", "list bridge nodes on the local network", runDiscover},
+		{"version", "print version, commit and build mode", runVersion},
 	}
-	defer db.Close()
+}
 
-	router := server.NewRouter(
-		cfg,
-		proxy.NewRegistrar(nil, toProxyChain(cfg.ProxyChain)),
-		sqlapi.NewRegistrar(db),
-		screenshot.NewRegistrar(db, cfg),
-	)
+// lookupCommand 按两级、一级的顺序匹配子命令，返回剩余参数；不以子命令开头时视为 serve，兼容旧的 `go_bridge --listen ...`。
+func lookupCommand(args []string) (command, []string, bool) {
+	table := commandTable()
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return table[0], args, true
+	}
+	if len(args) >= 2 {
+		for _, cmd := range table {
+			if cmd.name == args[0]+" "+args[1] {
+				return cmd, args[2:], true
+			}
+		}
+	}
+	for _, cmd := range table {
+		if cmd.name == args[0] {
+			return cmd, args[1:], true
+		}
+	}
+	return command{}, nil, false
+}
 
-	log.Printf("Go bridge listening on %s (driver=%s)", cfg.Listen, cfg.Driver)
-	if err := router.Run(cfg.Listen); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		log.Fatalf("server error: %v", err)
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, cmd := range commandTable() {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.name, cmd.summary)
 	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", os.Args[0])
 }
 
-func toProxyChain(hops []appconfig.ProxyChainHop) []proxy.ChainHop {
-	if len(hops) == 0 {
-		return nil
-	}
-	result := make([]proxy.ChainHop, 0, len(hops))
-	for _, hop := range hops {
-		endpoint := strings.TrimSpace(hop.Endpoint)
-		if endpoint == "" {
-			continue
-		}
-		result = append(result, proxy.ChainHop{
-			Endpoint:  endpoint,
-			AuthToken: strings.TrimSpace(hop.AuthToken),
-		})
+func main() {
+	args := os.Args[1:]
+	if len(args) > 0 && (args[0] == "help" || args[0] == "--help") {
+		fmt.Fprintf(os.Stdout, "Usage: %s <command> [flags]\n\n", os.Args[0])
+		printCommands(os.Stdout)
+		return
+	}
+	cmd, rest, ok := lookupCommand(args)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", strings.Join(args[:min(len(args), 2)], " "))
+		printCommands(os.Stderr)
+		os.Exit(2)
+	}
+	if err := cmd.run(rest, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		os.Exit(1)
 	}
-	return result
 }
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/proxy"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/tlsutil"
+)
+
+// proxyModule 在代理模块之上负责把配置转换为链路，并在配置热更新时替换链路与 hop 证书监视。
+type proxyModule struct {
+	*proxy.Registrar
+
+	mu    sync.Mutex
+	certs []*tlsutil.CertReloader
+}
+
+// hopSet 为一次配置转换得到的链路及其客户端证书监视器。
+type hopSet struct {
+	chain  []proxy.ChainHop
+	routes []proxy.Route
+	certs  []*tlsutil.CertReloader
+}
+
+// newProxyRegistrar 按配置创建代理模块，完整模式与仅代理模式共用。
+func newProxyRegistrar(cfg appconfig.Config) (*proxyModule, error) {
+	hops, err := buildHopSet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	allHops := append([]proxy.ChainHop{}, hops.chain...)
+	for _, route := range hops.routes {
+		allHops = append(allHops, route.Hops...)
+	}
+
+	registrar := proxy.NewRegistrar(proxy.NewHopClient(allHops), hops.chain)
+	if len(hops.routes) > 0 {
+		registrar.UpdateHops(hops.chain, hops.routes, routeProbeOptions(cfg))
+	}
+	return &proxyModule{Registrar: registrar, certs: hops.certs}, nil
+}
+
+// Reload 替换代理链与并行链路（自适应选路或静态备用）；新链路的证书加载失败时保留原链路。
+func (m *proxyModule) Reload(cfg appconfig.Config) error {
+	hops, err := buildHopSet(cfg)
+	if err != nil {
+		return err
+	}
+	m.Registrar.UpdateHops(hops.chain, hops.routes, routeProbeOptions(cfg))
+
+	m.mu.Lock()
+	previous := m.certs
+	m.certs = hops.certs
+	m.mu.Unlock()
+	for _, reloader := range previous {
+		reloader.Stop()
+	}
+	return nil
+}
+
+// Close 停止代理后台任务与 hop 证书监视。
+func (m *proxyModule) Close(ctx context.Context) error {
+	m.mu.Lock()
+	for _, reloader := range m.certs {
+		reloader.Stop()
+	}
+	m.certs = nil
+	m.mu.Unlock()
+	return m.Registrar.Close(ctx)
+}
+
+// buildHopSet 转换 proxyChain 与 proxyRouting.chains，任一 hop 失败时停止已启动的证书监视。
+// 自适应模式下 proxyChain 以 default 名称参与选路；静态模式下 chains 仅作为首跳熔断时的备用链路。
+func buildHopSet(cfg appconfig.Config) (hopSet, error) {
+	var hops hopSet
+	chain, err := toProxyChain(cfg.ProxyChain, &hops.certs)
+	if err == nil {
+		defaultRoute := chain
+		if !cfg.ProxyRouting.Adaptive() {
+			defaultRoute = nil
+		}
+		hops.routes, err = toProxyRoutes(cfg, defaultRoute, &hops.certs)
+	}
+	if err != nil {
+		for _, reloader := range hops.certs {
+			reloader.Stop()
+		}
+		return hopSet{}, err
+	}
+	hops.chain = chain
+	return hops, nil
+}
+
+func routeProbeOptions(cfg appconfig.Config) proxy.RouteProbeOptions {
+	return proxy.RouteProbeOptions{
+		CanaryURL:  strings.TrimSpace(cfg.ProxyRouting.CanaryURL),
+		Interval:   cfg.ProxyRouting.ProbeIntervalDuration(),
+		Timeout:    cfg.ProxyRouting.ProbeTimeoutDuration(),
+		ProbeBytes: cfg.ProxyRouting.ProbeBytes,
+		Hysteresis: cfg.ProxyRouting.Hysteresis,
+		SessionTTL: cfg.ProxyRouting.SessionTTLDuration(),
+		Static:     !cfg.ProxyRouting.Adaptive(),
+	}
+}
+
+func toProxyChain(hops []appconfig.ProxyChainHop, certs *[]*tlsutil.CertReloader) ([]proxy.ChainHop, error) {
+	if len(hops) == 0 {
+		return nil, nil
+	}
+	result := make([]proxy.ChainHop, 0, len(hops))
+	for _, hop := range hops {
+		endpoint := strings.TrimSpace(hop.Endpoint)
+		if endpoint == "" {
+			continue
+		}
+		converted := proxy.ChainHop{
+			Endpoint:  endpoint,
+			AuthToken: strings.TrimSpace(hop.AuthToken),
+		}
+		if hop.TLS.Enabled() {
+			tlsCfg, reloader, err := tlsutil.ClientConfig(hop.TLS.CertFile, hop.TLS.KeyFile, hop.TLS.CAFile, hop.TLS.ServerName)
+			if err != nil {
+				return nil, fmt.Errorf("hop %s tls: %w", endpoint, err)
+			}
+			if reloader != nil {
+				reloader.Watch(0)
+				*certs = append(*certs, reloader)
+			}
+			converted.TLS = tlsCfg
+		}
+		result = append(result, converted)
+	}
+	return result, nil
+}
+
+// toProxyRoutes 将 proxyRouting.chains 转换为并行链路；若同时配置了 proxyChain，
+// 则作为名为 default 的链路参与选路（复用已转换的 chain）。
+func toProxyRoutes(cfg appconfig.Config, chain []proxy.ChainHop, certs *[]*tlsutil.CertReloader) ([]proxy.Route, error) {
+	routes := make([]proxy.Route, 0, len(cfg.ProxyRouting.Chains)+1)
+	if len(chain) > 0 {
+		routes = append(routes, proxy.Route{Name: "default", Hops: chain})
+	}
+	for _, route := range cfg.ProxyRouting.Chains {
+		hops, err := toProxyChain(route.Hops, certs)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, proxy.Route{Name: route.Name, Hops: hops})
+	}
+	return routes, nil
+}
@@ -0,0 +1,95 @@
+//go:build !proxy_only
+
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/database"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/discovery"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/logging"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/audit"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/screenshot"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/sqlapi"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/usage"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server"
+)
+
+// runServe 启动完整模式的桥接服务，阻塞到收到退出信号并完成优雅退出；启动失败时直接退出进程。
+func runServe(args []string, _ io.Writer) error {
+	opts := parseFlags(args)
+	cfg, err := loadConfig(opts, true)
+	if err != nil {
+		fatal("load config", err)
+	}
+	logging.Setup(cfg.Log)
+	if err := cfg.EnsureScreenshotDir(); err != nil {
+		fatal("ensure screenshot dir", err)
+	}
+
+	db, err := database.Connect(cfg)
+	if err != nil {
+		fatal("connect db", err)
+	}
+
+	usageRegistrar, err := usage.NewRegistrar(db, cfg)
+	if err != nil {
+		fatal("init usage", err)
+	}
+
+	auditRegistrar, err := audit.NewRegistrar(db, cfg)
+	if err != nil {
+		fatal("init audit", err)
+	}
+
+	proxyRegistrar, err := newProxyRegistrar(cfg)
+	if err != nil {
+		fatal("init proxy", err)
+	}
+
+	router := server.NewRouter(
+		cfg,
+		proxyRegistrar,
+		usageRegistrar,
+		auditRegistrar,
+		sqlapi.NewRegistrar(db, cfg),
+		screenshot.NewRegistrar(db, cfg),
+	)
+
+	slog.Info("go bridge listening", "listen", cfg.Listen, "driver", cfg.Driver, "tls", cfg.TLS.Enabled())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// 首个信号触发优雅退出，恢复默认处理后再次 Ctrl+C 可立即终止进程。
+		<-ctx.Done()
+		stop()
+	}()
+	// 配置文件修改或收到 SIGHUP 时热更新令牌、代理链与配额，播放中的连接不受影响。
+	configPath, _ := opts.config.ConfigPath()
+	go server.WatchConfig(ctx, configPath, cfg, func() (appconfig.Config, error) {
+		next, _, err := appconfig.LoadWithSources(true, opts.config)
+		if err == nil {
+			err = next.EnsureScreenshotDir()
+		}
+		return next, err
+	}, router)
+	// 在局域网内通告本节点，Flutter 端无需逐个扫描地址即可找到桥接服务。
+	if announcer, ok := discovery.NewAnnouncer(cfg, discovery.Options{}); ok {
+		go announcer.Run(ctx)
+	}
+	if err := server.Serve(ctx, cfg, router, proxyRegistrar, usageRegistrar); err != nil {
+		fatal("server error", err)
+	}
+	// 连接排空、代理轮询停止、计量落库完成后再关闭数据库。
+	if err := db.Close(); err != nil {
+		slog.Error("close db failed", "error", err)
+	}
+	slog.Info("go bridge stopped")
+	return nil
+}
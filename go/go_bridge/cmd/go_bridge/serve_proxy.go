@@ -0,0 +1,68 @@
+//go:build proxy_only
+
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/discovery"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/logging"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/usage"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server"
+)
+
+// runServe 启动仅代理模式的桥接服务，用于需要轻量代理能力的桌面/移动端节点。
+func runServe(args []string, _ io.Writer) error {
+	opts := parseFlags(args)
+	cfg, err := loadConfig(opts, false)
+	if err != nil {
+		fatal("load config", err)
+	}
+	logging.Setup(cfg.Log)
+
+	usageRegistrar, err := usage.NewRegistrar(nil, cfg)
+	if err != nil {
+		fatal("init usage", err)
+	}
+
+	proxyRegistrar, err := newProxyRegistrar(cfg)
+	if err != nil {
+		fatal("init proxy", err)
+	}
+
+	router := server.NewRouter(
+		cfg,
+		proxyRegistrar,
+		usageRegistrar,
+	)
+
+	slog.Info("go bridge listening", "mode", "proxy_only", "listen", cfg.Listen, "tls", cfg.TLS.Enabled())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// 首个信号触发优雅退出，恢复默认处理后再次 Ctrl+C 可立即终止进程。
+		<-ctx.Done()
+		stop()
+	}()
+	// 配置文件修改或收到 SIGHUP 时热更新令牌、代理链与配额，播放中的连接不受影响。
+	configPath, _ := opts.config.ConfigPath()
+	go server.WatchConfig(ctx, configPath, cfg, func() (appconfig.Config, error) {
+		next, _, err := appconfig.LoadWithSources(false, opts.config)
+		return next, err
+	}, router)
+	// 在局域网内通告本节点，Flutter 端无需逐个扫描地址即可找到桥接服务。
+	if announcer, ok := discovery.NewAnnouncer(cfg, discovery.Options{}); ok {
+		go announcer.Run(ctx)
+	}
+	if err := server.Serve(ctx, cfg, router, proxyRegistrar, usageRegistrar); err != nil {
+		fatal("server error", err)
+	}
+	slog.Info("go bridge stopped")
+	return nil
+}
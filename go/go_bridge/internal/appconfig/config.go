@@ -1,88 +1,753 @@
 package appconfig
 
 import (
-	"errors"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 // Config 描述 Go 桥服务的公共配置，既可用于完整模式，也可用于仅代理模式。
 type Config struct {
-	Listen        string          `yaml:"listen"`
-	Driver        string          `yaml:"driver"`
-	DSN           string          `yaml:"dsn"`
-	AuthToken     string          `yaml:"authToken"`
-	MaxOpenConns  int             `yaml:"maxOpenConns"`
-	MaxIdleConns  int             `yaml:"maxIdleConns"`
-	ConnMaxLife   string          `yaml:"connMaxLifetime"`
-	ScreenshotDir string          `yaml:"screenshotDir"`
-	ProxyChain    []ProxyChainHop `yaml:"proxyChain"`
+	Listen          string          `yaml:"listen"`
+	UnixSocket      UnixSocket      `yaml:"unixSocket"`
+	Driver          string          `yaml:"driver"`
+	DSN             string          `yaml:"dsn"`
+	AuthToken       string          `yaml:"authToken"`
+	Tokens          []APIToken      `yaml:"tokens"`
+	Auth            AuthConfig      `yaml:"auth"`
+	Isolation       Isolation       `yaml:"isolation"`
+	MaxOpenConns    int             `yaml:"maxOpenConns"`
+	MaxIdleConns    int             `yaml:"maxIdleConns"`
+	ConnMaxLife     string          `yaml:"connMaxLifetime"`
+	ScreenshotDir   string          `yaml:"screenshotDir"`
+	TLS             ListenerTLS     `yaml:"tls"`
+	ShutdownTimeout string          `yaml:"shutdownTimeout"`
+	ProxyChain      []ProxyChainHop `yaml:"proxyChain"`
+	ProxyRouting    ProxyRouting    `yaml:"proxyRouting"`
+	Usage           UsageConfig     `yaml:"usage"`
+	Log             LogConfig       `yaml:"log"`
+	Audit           AuditConfig     `yaml:"audit"`
+	RateLimit       RateLimitConfig `yaml:"rateLimit"`
+	CORS            CORSConfig      `yaml:"cors"`
+	Discovery       DiscoveryConfig `yaml:"discovery"`
+	// TrustedProxies 为可信反向代理的 IP 或 CIDR，仅来自这些地址的 X-Forwarded-For / X-Real-IP 会被采信；
+	// 默认为空，限流、锁定、审计与访问日志一律使用连接的对端地址。
+	TrustedProxies []string `yaml:"trustedProxies"`
+
+	// secrets 为解析后的敏感值，供 Redact 在错误与日志中脱敏。
+	secrets []string
+}
+
+// 鉴权模式：token 仅校验桥接令牌，alist 仅接受 AList JWT，mixed 先校验桥接令牌再回退到 AList。
+const (
+	AuthModeToken = "token"
+	AuthModeAList = "alist"
+	AuthModeMixed = "mixed"
+)
+
+// AuthConfig 描述鉴权模式及 AList 委托鉴权参数。
+type AuthConfig struct {
+	Mode  string    `yaml:"mode"`
+	AList AListAuth `yaml:"alist"`
+}
+
+// AListAuth 通过调用 AList 的 /api/me 校验 JWT，结果按 cacheTtl 缓存。
+type AListAuth struct {
+	BaseURL  string `yaml:"baseUrl"`
+	CacheTTL string `yaml:"cacheTtl"`
+	Timeout  string `yaml:"timeout"`
+	// UserScopes 为普通 AList 用户获得的权限，AList 管理员始终拥有全部权限。
+	UserScopes []string `yaml:"userScopes"`
+}
+
+// CacheTTLDuration 返回 JWT 校验结果缓存时长，未配置时为 0 由鉴权模块使用默认值。
+func (a AListAuth) CacheTTLDuration() time.Duration {
+	return parseOptionalDuration(a.CacheTTL)
+}
+
+// TimeoutDuration 返回调用 AList 的超时时间，未配置时为 0。
+func (a AListAuth) TimeoutDuration() time.Duration {
+	return parseOptionalDuration(a.Timeout)
+}
+
+// Isolation 描述按用户隔离的数据表：调用方绑定了用户时，桥接服务会强制这些表只读写该用户的行。
+type Isolation struct {
+	UserTables []string `yaml:"userTables"`
+	UserColumn string   `yaml:"userColumn"`
+}
+
+// APIToken 描述一个具名访问令牌：仅保存密钥摘要，按 scope 限定可访问的路由组。
+type APIToken struct {
+	Name string `yaml:"name"`
+	// SecretHash 为 `sha256:<hex>` 格式的令牌摘要，可由 HashTokenSecret 生成。
+	SecretHash string   `yaml:"secretHash"`
+	Scopes     []string `yaml:"scopes"`
+	// ExpiresAt 为可选的 RFC3339 过期时间。
+	ExpiresAt string `yaml:"expiresAt"`
+	// UserID 为可选的绑定用户，后续可用于按用户隔离数据。
+	UserID *int64 `yaml:"userId"`
+}
+
+// 令牌可授予的权限范围。
+const (
+	ScopeProxy      = "proxy"
+	ScopeSQLRead    = "sql:read"
+	ScopeSQLWrite   = "sql:write"
+	ScopeScreenshot = "screenshot"
+	ScopeAdmin      = "admin"
+)
+
+var knownScopes = map[string]bool{
+	ScopeProxy:      true,
+	ScopeSQLRead:    true,
+	ScopeSQLWrite:   true,
+	ScopeScreenshot: true,
+	ScopeAdmin:      true,
+}
+
+// tokenHashPrefix 为令牌摘要的算法前缀，预留以后切换算法的空间。
+const tokenHashPrefix = "sha256:"
+
+// HashTokenSecret 计算令牌明文的摘要，结果可直接写入 tokens[].secretHash。
+func HashTokenSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return tokenHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// Matches 以常量时间比较令牌明文与摘要。
+func (t APIToken) Matches(secret string) bool {
+	expected := strings.ToLower(strings.TrimSpace(t.SecretHash))
+	if !strings.HasPrefix(expected, tokenHashPrefix) {
+		return false
+	}
+	actual := HashTokenSecret(secret)
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
+
+// Expiry 返回令牌过期时间，未配置时 ok=false。
+func (t APIToken) Expiry() (time.Time, bool) {
+	if strings.TrimSpace(t.ExpiresAt) == "" {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339, strings.TrimSpace(t.ExpiresAt))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return at, true
 }
 
 // ProxyChainHop 描述多级代理链中下一跳 Go 服务的地址与访问令牌。
 type ProxyChainHop struct {
 	Endpoint  string `yaml:"endpoint"`
 	AuthToken string `yaml:"authToken"`
+	// TLS 为连接该 hop 时使用的客户端证书与 CA，用于 hop 之间的双向 TLS。
+	TLS HopTLS `yaml:"tls"`
 }
 
-// Load 从配置文件加载实例；当 requireDatabase=false 时允许省略数据库字段，
-// 便于编译仅包含代理功能的精简包。
-func Load(requireDatabase bool) (Config, error) {
-	cfgPath := os.Getenv("GO_BRIDGE_CONFIG")
-	if cfgPath == "" {
-		cfgPath = "config.yaml"
+// HopTLS 描述连接下一跳时的 TLS 设置，均为可选项。
+type HopTLS struct {
+	CertFile   string `yaml:"certFile"`
+	KeyFile    string `yaml:"keyFile"`
+	CAFile     string `yaml:"caFile"`
+	ServerName string `yaml:"serverName"`
+}
+
+// Enabled 判断是否为该 hop 配置了自定义 TLS。
+func (t HopTLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.CAFile != "" || t.ServerName != ""
+}
+
+// 监听端校验客户端证书的方式。
+const (
+	ClientAuthRequire       = "require"
+	ClientAuthVerifyIfGiven = "verify-if-given"
+)
+
+// ListenerTLS 描述监听端的 TLS 配置：证书文件修改后自动热加载，selfSigned 时首次启动自动生成自签名证书；
+// 配置 clientCAFile 后启用客户端证书校验（mTLS）。
+type ListenerTLS struct {
+	CertFile     string   `yaml:"certFile"`
+	KeyFile      string   `yaml:"keyFile"`
+	SelfSigned   bool     `yaml:"selfSigned"`
+	Hosts        []string `yaml:"hosts"`
+	ClientCAFile string   `yaml:"clientCAFile"`
+	ClientAuth   string   `yaml:"clientAuth"`
+	// ReloadInterval 为检查证书文件变化的间隔，默认 10s。
+	ReloadInterval string `yaml:"reloadInterval"`
+}
+
+// Enabled 判断监听端是否启用 TLS。
+func (t ListenerTLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// ReloadIntervalDuration 返回证书检查间隔，未配置时为 0。
+func (t ListenerTLS) ReloadIntervalDuration() time.Duration {
+	return parseOptionalDuration(t.ReloadInterval)
+}
+
+// 监听地址前缀：unix:///path.sock 为 Unix 域套接字，systemd:// 使用 systemd 传入的套接字（可带 LISTEN_FDNAMES 中的名称）。
+const (
+	ListenUnixPrefix    = "unix://"
+	ListenSystemdPrefix = "systemd://"
+)
+
+// ListenAddrs 返回逗号分隔的全部监听地址，例如 `127.0.0.1:7788,unix:///run/go_bridge.sock`。
+func (c Config) ListenAddrs() []string {
+	var addrs []string
+	for _, addr := range strings.Split(c.Listen, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
 	}
+	return addrs
+}
 
-	raw, err := os.ReadFile(cfgPath)
+// UnixSocket 描述 unix:// 监听创建的套接字文件权限。
+type UnixSocket struct {
+	// Mode 为八进制文件权限，默认 0660。
+	Mode string `yaml:"mode"`
+	// Group 为套接字文件的属组（组名或 gid），为空时保持进程默认属组。
+	Group string `yaml:"group"`
+}
+
+// FileMode 返回套接字文件权限，normalize 已保证 Mode 合法。
+func (u UnixSocket) FileMode() os.FileMode {
+	mode, err := strconv.ParseUint(u.Mode, 8, 32)
 	if err != nil {
-		return Config{}, fmt.Errorf("read config: %w", err)
+		return 0o660
 	}
+	return os.FileMode(mode)
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(raw, &cfg); err != nil {
-		return Config{}, fmt.Errorf("parse config: %w", err)
-	}
+// ProxyRouting 描述自适应选路：多条并行链路（如经东京、经香港、直连）按探测得分择优。
+type ProxyRouting struct {
+	// Mode 为 static（默认，仅使用 proxyChain）或 adaptive。
+	Mode          string       `yaml:"mode"`
+	CanaryURL     string       `yaml:"canaryUrl"`
+	ProbeInterval string       `yaml:"probeInterval"`
+	ProbeTimeout  string       `yaml:"probeTimeout"`
+	ProbeBytes    int64        `yaml:"probeBytes"`
+	Hysteresis    float64      `yaml:"hysteresis"`
+	SessionTTL    string       `yaml:"sessionTtl"`
+	Chains        []ProxyRoute `yaml:"chains"`
+}
+
+// ProxyRoute 为一条具名链路，hops 为空表示直连。
+type ProxyRoute struct {
+	Name string          `yaml:"name"`
+	Hops []ProxyChainHop `yaml:"hops"`
+}
+
+// 日志输出格式。
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogConfig 描述结构化日志的级别（debug/info/warn/error，支持热更新）与输出格式（text/json）。
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// AuditConfig 描述写操作审计；数据库不可用或仅代理模式时记录追加到 fallbackFile（JSONL）。
+type AuditConfig struct {
+	FallbackFile string `yaml:"fallbackFile"`
+}
+
+// DiscoveryConfig 描述局域网自动发现：mDNS/DNS-SD 通告 _alistbridge._tcp 并应答 UDP 广播探测。
+type DiscoveryConfig struct {
+	// Disabled 关闭全部通告。
+	Disabled bool `yaml:"disabled"`
+	// NodeID 为节点标识，默认由主机名与监听地址派生，重启后保持不变。
+	NodeID string `yaml:"nodeId"`
+	// Name 为 DNS-SD 实例名，默认为主机名。
+	Name string `yaml:"name"`
+	// UDPPort 为广播探测端口，默认 7789，负数只关闭 UDP 应答。
+	UDPPort int `yaml:"udpPort"`
+}
+
+// DefaultDiscoveryPort 为 UDP 广播探测的默认端口。
+const DefaultDiscoveryPort = 7789
 
-	if err := cfg.normalize(requireDatabase); err != nil {
-		return Config{}, err
+// CORSConfig 描述全局跨域策略：allowedOrigins 支持 `*`、完整来源（如 `https://app.example.com`）
+// 与子域通配（如 `https://*.example.com`）；allowedHeaders 含 `*` 时回显预检请求的头部。
+type CORSConfig struct {
+	AllowedOrigins   []string `yaml:"allowedOrigins"`
+	AllowedMethods   []string `yaml:"allowedMethods"`
+	AllowedHeaders   []string `yaml:"allowedHeaders"`
+	ExposedHeaders   []string `yaml:"exposedHeaders"`
+	AllowCredentials bool     `yaml:"allowCredentials"`
+	MaxAge           string   `yaml:"maxAge"`
+}
+
+// MaxAgeDuration 返回预检结果的缓存时长。
+func (c CORSConfig) MaxAgeDuration() time.Duration {
+	return parseOptionalDuration(c.MaxAge)
+}
+
+// RateLimitConfig 描述按路由组的限流预算与无效令牌锁定策略，全部可热更新。
+type RateLimitConfig struct {
+	Proxy      RateBudget    `yaml:"proxy"`
+	SQL        RateBudget    `yaml:"sql"`
+	Screenshot RateBudget    `yaml:"screenshot"`
+	Lockout    LockoutConfig `yaml:"lockout"`
+}
+
+// RateBudget 为单个路由组的令牌桶参数：perIp / perIdentity 为每秒请求数，burst 为桶容量；
+// 0 表示使用默认值，负数表示关闭对应维度的限流。
+type RateBudget struct {
+	PerIP       float64 `yaml:"perIp"`
+	PerIdentity float64 `yaml:"perIdentity"`
+	Burst       int     `yaml:"burst"`
+}
+
+// LockoutConfig 描述无效令牌锁定：window 内同一 IP 累计 maxFailures 次 401 后锁定 baseDuration，
+// 再次触发时锁定时长翻倍，最长 maxDuration；maxFailures 为负数时关闭锁定。
+type LockoutConfig struct {
+	MaxFailures  int    `yaml:"maxFailures"`
+	Window       string `yaml:"window"`
+	BaseDuration string `yaml:"baseDuration"`
+	MaxDuration  string `yaml:"maxDuration"`
+}
+
+// WindowDuration 返回失败计数窗口。
+func (l LockoutConfig) WindowDuration() time.Duration {
+	return parseOptionalDuration(l.Window)
+}
+
+// BaseDurationValue 返回首次锁定时长。
+func (l LockoutConfig) BaseDurationValue() time.Duration {
+	return parseOptionalDuration(l.BaseDuration)
+}
+
+// MaxDurationValue 返回锁定时长上限。
+func (l LockoutConfig) MaxDurationValue() time.Duration {
+	return parseOptionalDuration(l.MaxDuration)
+}
+
+// UsageConfig 描述流量计量的落库周期与按身份的月度配额。
+type UsageConfig struct {
+	FlushInterval string       `yaml:"flushInterval"`
+	Quotas        []UsageQuota `yaml:"quotas"`
+}
+
+// UsageQuota 为单个身份的月度限额；monthlyBytes 支持 50GiB、500MB 等写法，留空表示不限制。
+type UsageQuota struct {
+	Identity        string `yaml:"identity"`
+	MonthlyBytes    string `yaml:"monthlyBytes"`
+	MonthlyRequests int64  `yaml:"monthlyRequests"`
+}
+
+// Adaptive 判断是否启用了自适应选路。
+func (r ProxyRouting) Adaptive() bool {
+	return strings.EqualFold(r.Mode, "adaptive")
+}
+
+// Load 按默认值、配置文件、环境变量的顺序加载实例；当 requireDatabase=false 时允许省略数据库字段，
+// 便于编译仅包含代理功能的精简包。
+func Load(requireDatabase bool) (Config, error) {
+	cfg, _, err := LoadWithSources(requireDatabase, Options{CheckWritable: true})
+	return cfg, err
+}
+
+// RestartRequired 返回 next 相对 c 修改了的、只能在重启后生效的配置项，
+// 其余配置（令牌、鉴权、代理链与选路、配额）可热更新。
+func (c Config) RestartRequired(next Config) []string {
+	fields := []struct {
+		name        string
+		old, latest any
+	}{
+		{"listen", c.Listen, next.Listen},
+		{"unixSocket", c.UnixSocket, next.UnixSocket},
+		{"driver", c.Driver, next.Driver},
+		{"dsn", c.DSN, next.DSN},
+		{"maxOpenConns", c.MaxOpenConns, next.MaxOpenConns},
+		{"maxIdleConns", c.MaxIdleConns, next.MaxIdleConns},
+		{"connMaxLifetime", c.ConnMaxLife, next.ConnMaxLife},
+		{"screenshotDir", c.ScreenshotDir, next.ScreenshotDir},
+		{"tls", c.TLS, next.TLS},
+		{"shutdownTimeout", c.ShutdownTimeout, next.ShutdownTimeout},
+		{"isolation", c.Isolation, next.Isolation},
+		{"usage.flushInterval", c.Usage.FlushInterval, next.Usage.FlushInterval},
+		{"log.format", c.Log.Format, next.Log.Format},
+		{"audit.fallbackFile", c.Audit.FallbackFile, next.Audit.FallbackFile},
+		{"discovery", c.Discovery, next.Discovery},
+		{"trustedProxies", c.TrustedProxies, next.TrustedProxies},
 	}
-	return cfg, nil
+	var changed []string
+	for _, field := range fields {
+		if !reflect.DeepEqual(field.old, field.latest) {
+			changed = append(changed, field.name)
+		}
+	}
+	return changed
 }
 
-// normalize 填充默认值并视情况校验必填字段。
-func (c *Config) normalize(requireDatabase bool) error {
-	if c.Listen == "" {
+// normalize 填充默认值并校验全部字段；发现问题后继续检查其余字段，最终以 ValidationError 一次性返回。
+func (c *Config) normalize(requireDatabase, checkWritable bool) error {
+	var p problems
+	if strings.TrimSpace(c.Listen) == "" {
 		c.Listen = ":7788"
 	}
+	for _, addr := range c.ListenAddrs() {
+		p.listenAddr(addr)
+	}
+	c.UnixSocket.Mode = strings.TrimSpace(c.UnixSocket.Mode)
+	if c.UnixSocket.Mode == "" {
+		c.UnixSocket.Mode = "0660"
+	}
+	if mode, err := strconv.ParseUint(c.UnixSocket.Mode, 8, 32); err != nil || mode > 0o777 {
+		p.add("unixSocket.mode", "must be an octal permission such as 0660, got %q", c.UnixSocket.Mode)
+	}
+	c.UnixSocket.Group = strings.TrimSpace(c.UnixSocket.Group)
+
+	c.Driver = strings.TrimSpace(c.Driver)
+	if c.Driver != "" && !slices.Contains(supportedDrivers, c.Driver) {
+		p.add("driver", "unsupported driver %q, must be one of %s", c.Driver, strings.Join(supportedDrivers, ", "))
+	} else if c.Driver != "" && c.DSN != "" {
+		if msg := checkDSN(c.Driver, c.DSN); msg != "" {
+			p.add("dsn", "%s", msg)
+		}
+	}
+	if requireDatabase {
+		if c.Driver == "" {
+			p.add("driver", "is required in full mode")
+		}
+		if c.DSN == "" {
+			p.add("dsn", "is required in full mode")
+		}
+	}
 	if c.MaxOpenConns == 0 {
 		c.MaxOpenConns = 5
 	}
 	if c.MaxIdleConns == 0 {
 		c.MaxIdleConns = 2
+		if c.MaxOpenConns > 0 {
+			c.MaxIdleConns = min(c.MaxIdleConns, c.MaxOpenConns)
+		}
+	}
+	if c.MaxOpenConns > 0 && c.MaxIdleConns > c.MaxOpenConns {
+		p.add("maxIdleConns", "%d must not exceed maxOpenConns %d", c.MaxIdleConns, c.MaxOpenConns)
 	}
+	c.ConnMaxLife = strings.TrimSpace(c.ConnMaxLife)
+	p.duration("connMaxLifetime", c.ConnMaxLife, true)
+	p.duration("shutdownTimeout", c.ShutdownTimeout, false)
+
 	if c.ScreenshotDir == "" {
 		c.ScreenshotDir = filepath.Join("data", "screenshots")
 	}
 	c.ScreenshotDir = filepath.Clean(c.ScreenshotDir)
+	if requireDatabase && checkWritable {
+		if msg := checkWritableDir(c.ScreenshotDir); msg != "" {
+			p.add("screenshotDir", "%s", msg)
+		}
+	}
+	if strings.TrimSpace(c.Audit.FallbackFile) == "" {
+		c.Audit.FallbackFile = filepath.Join("data", "audit", "audit.jsonl")
+	}
+	c.Audit.FallbackFile = filepath.Clean(strings.TrimSpace(c.Audit.FallbackFile))
+	for i, proxy := range c.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		c.TrustedProxies[i] = proxy
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			p.add(fmt.Sprintf("trustedProxies.%d", i), "must be an IP address or CIDR, got %q", proxy)
+		}
+	}
+	c.Discovery.NodeID = strings.TrimSpace(c.Discovery.NodeID)
+	c.Discovery.Name = strings.TrimSpace(c.Discovery.Name)
+	if c.Discovery.UDPPort == 0 {
+		c.Discovery.UDPPort = DefaultDiscoveryPort
+	}
+	if c.Discovery.UDPPort > 65535 {
+		p.add("discovery.udpPort", "must be at most 65535, got %d", c.Discovery.UDPPort)
+	}
+
+	c.TLS.CertFile = strings.TrimSpace(c.TLS.CertFile)
+	c.TLS.KeyFile = strings.TrimSpace(c.TLS.KeyFile)
+	if c.TLS.SelfSigned {
+		if c.TLS.CertFile == "" {
+			c.TLS.CertFile = filepath.Join("data", "tls", "cert.pem")
+		}
+		if c.TLS.KeyFile == "" {
+			c.TLS.KeyFile = filepath.Join("data", "tls", "key.pem")
+		}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		p.add("tls", "certFile and keyFile must be set together")
+	}
+	c.TLS.ClientAuth = strings.ToLower(strings.TrimSpace(c.TLS.ClientAuth))
+	if c.TLS.ClientCAFile != "" {
+		if !c.TLS.Enabled() {
+			p.add("tls.clientCAFile", "requires tls.certFile and tls.keyFile")
+		}
+		switch c.TLS.ClientAuth {
+		case "":
+			c.TLS.ClientAuth = ClientAuthRequire
+		case ClientAuthRequire, ClientAuthVerifyIfGiven:
+		default:
+			p.add("tls.clientAuth", "must be %s or %s", ClientAuthRequire, ClientAuthVerifyIfGiven)
+		}
+	}
+	p.duration("tls.reloadInterval", c.TLS.ReloadInterval, false)
+
+	c.Log.Level = strings.ToLower(strings.TrimSpace(c.Log.Level))
+	switch c.Log.Level {
+	case "":
+		c.Log.Level = "info"
+	case "debug", "info", "warn", "error":
+	default:
+		p.add("log.level", "must be debug, info, warn or error")
+	}
+	c.Log.Format = strings.ToLower(strings.TrimSpace(c.Log.Format))
+	switch c.Log.Format {
+	case "":
+		c.Log.Format = LogFormatText
+	case LogFormatText, LogFormatJSON:
+	default:
+		p.add("log.format", "must be %s or %s", LogFormatText, LogFormatJSON)
+	}
+
+	c.RateLimit.normalize(&p)
+	c.CORS.normalize(&p)
 
 	for i := range c.ProxyChain {
-		c.ProxyChain[i].Endpoint = strings.TrimRight(strings.TrimSpace(c.ProxyChain[i].Endpoint), "/")
+		c.ProxyChain[i].normalize(&p, fmt.Sprintf("proxyChain.%d", i))
+	}
+	c.ProxyRouting.Mode = strings.ToLower(strings.TrimSpace(c.ProxyRouting.Mode))
+	switch c.ProxyRouting.Mode {
+	case "":
+		c.ProxyRouting.Mode = "static"
+	case "static", "adaptive":
+	default:
+		p.add("proxyRouting.mode", "must be static or adaptive")
+	}
+	c.ProxyRouting.CanaryURL = strings.TrimSpace(c.ProxyRouting.CanaryURL)
+	if c.ProxyRouting.CanaryURL != "" {
+		p.httpURL("proxyRouting.canaryUrl", c.ProxyRouting.CanaryURL)
+	}
+	p.duration("proxyRouting.probeInterval", c.ProxyRouting.ProbeInterval, false)
+	p.duration("proxyRouting.probeTimeout", c.ProxyRouting.ProbeTimeout, false)
+	p.duration("proxyRouting.sessionTtl", c.ProxyRouting.SessionTTL, false)
+	if c.ProxyRouting.ProbeBytes < 0 {
+		p.add("proxyRouting.probeBytes", "must not be negative")
+	}
+	if c.ProxyRouting.Hysteresis < 0 {
+		p.add("proxyRouting.hysteresis", "must not be negative")
+	}
+	seenRoutes := map[string]bool{}
+	for i := range c.ProxyRouting.Chains {
+		route := &c.ProxyRouting.Chains[i]
+		path := fmt.Sprintf("proxyRouting.chains.%d", i)
+		route.Name = strings.TrimSpace(route.Name)
+		switch {
+		case route.Name == "":
+			p.add(path+".name", "is required")
+		case seenRoutes[route.Name]:
+			p.add(path+".name", "duplicate route name %s", route.Name)
+		}
+		seenRoutes[route.Name] = true
+		for j := range route.Hops {
+			route.Hops[j].normalize(&p, fmt.Sprintf("%s.hops.%d", path, j))
+		}
 	}
 
-	if requireDatabase {
-		if c.Driver == "" {
-			return errors.New("driver is required in full mode")
+	c.Auth.Mode = strings.ToLower(strings.TrimSpace(c.Auth.Mode))
+	switch c.Auth.Mode {
+	case "":
+		c.Auth.Mode = AuthModeToken
+	case AuthModeToken, AuthModeAList, AuthModeMixed:
+	default:
+		p.add("auth.mode", "must be %s, %s or %s", AuthModeToken, AuthModeAList, AuthModeMixed)
+	}
+	c.Auth.AList.BaseURL = strings.TrimRight(strings.TrimSpace(c.Auth.AList.BaseURL), "/")
+	switch {
+	case c.Auth.AList.BaseURL != "":
+		p.httpURL("auth.alist.baseUrl", c.Auth.AList.BaseURL)
+	case c.Auth.Mode == AuthModeAList || c.Auth.Mode == AuthModeMixed:
+		p.add("auth.alist.baseUrl", "is required when auth.mode uses alist")
+	}
+	p.duration("auth.alist.cacheTtl", c.Auth.AList.CacheTTL, false)
+	p.duration("auth.alist.timeout", c.Auth.AList.Timeout, false)
+	if len(c.Auth.AList.UserScopes) == 0 {
+		c.Auth.AList.UserScopes = []string{ScopeProxy, ScopeSQLRead, ScopeSQLWrite, ScopeScreenshot}
+	}
+	for i, scope := range c.Auth.AList.UserScopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !knownScopes[scope] {
+			p.add(fmt.Sprintf("auth.alist.userScopes.%d", i), "unknown scope %q", scope)
 		}
-		if c.DSN == "" {
-			return errors.New("dsn is required in full mode")
+		c.Auth.AList.UserScopes[i] = scope
+	}
+
+	if c.Isolation.UserTables == nil {
+		c.Isolation.UserTables = []string{"t_historical_records", "t_favorite_directories"}
+	}
+	for i, table := range c.Isolation.UserTables {
+		c.Isolation.UserTables[i] = strings.ToLower(strings.TrimSpace(table))
+	}
+	c.Isolation.UserColumn = strings.ToLower(strings.TrimSpace(c.Isolation.UserColumn))
+	if c.Isolation.UserColumn == "" {
+		c.Isolation.UserColumn = "user_id"
+	}
+
+	seenTokens := map[string]bool{}
+	for i := range c.Tokens {
+		token := &c.Tokens[i]
+		path := fmt.Sprintf("tokens.%d", i)
+		token.Name = strings.TrimSpace(token.Name)
+		switch {
+		case token.Name == "":
+			p.add(path+".name", "is required")
+		case seenTokens[token.Name]:
+			p.add(path+".name", "duplicate token name %s", token.Name)
+		}
+		seenTokens[token.Name] = true
+		if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(token.SecretHash)), tokenHashPrefix) {
+			p.add(path+".secretHash", "must use %s<hex>", tokenHashPrefix)
+		}
+		for j, scope := range token.Scopes {
+			scope = strings.ToLower(strings.TrimSpace(scope))
+			if !knownScopes[scope] {
+				p.add(fmt.Sprintf("%s.scopes.%d", path, j), "unknown scope %q", scope)
+			}
+			token.Scopes[j] = scope
+		}
+		if strings.TrimSpace(token.ExpiresAt) != "" {
+			if _, ok := token.Expiry(); !ok {
+				p.add(path+".expiresAt", "must be RFC3339")
+			}
 		}
 	}
-	return nil
+
+	p.duration("usage.flushInterval", c.Usage.FlushInterval, false)
+	for i := range c.Usage.Quotas {
+		quota := &c.Usage.Quotas[i]
+		path := fmt.Sprintf("usage.quotas.%d", i)
+		quota.Identity = strings.TrimSpace(quota.Identity)
+		if quota.Identity == "" {
+			p.add(path+".identity", "is required")
+		}
+		if _, err := ParseByteSize(quota.MonthlyBytes); err != nil {
+			p.add(path+".monthlyBytes", "%v", err)
+		}
+	}
+	return p.err()
+}
+
+// normalize 去除 hop 地址末尾的 /，并校验地址为 http(s) 且 TLS 证书与私钥成对出现。
+func (h *ProxyChainHop) normalize(p *problems, path string) {
+	h.Endpoint = strings.TrimRight(strings.TrimSpace(h.Endpoint), "/")
+	if h.Endpoint == "" {
+		p.add(path+".endpoint", "is required")
+	} else {
+		p.httpURL(path+".endpoint", h.Endpoint)
+	}
+	if (strings.TrimSpace(h.TLS.CertFile) == "") != (strings.TrimSpace(h.TLS.KeyFile) == "") {
+		p.add(path+".tls", "certFile and keyFile must be set together")
+	}
+}
+
+// normalize 填充默认跨域策略（允许任意来源，与旧版代理接口行为一致）并校验来源格式。
+func (c *CORSConfig) normalize(p *problems) {
+	if len(c.AllowedOrigins) == 0 {
+		c.AllowedOrigins = []string{"*"}
+	}
+	if len(c.AllowedMethods) == 0 {
+		c.AllowedMethods = []string{"GET", "HEAD", "POST", "OPTIONS"}
+	}
+	if len(c.AllowedHeaders) == 0 {
+		c.AllowedHeaders = []string{"*"}
+	}
+	if c.ExposedHeaders == nil {
+		c.ExposedHeaders = []string{"Content-Length", "Content-Range", "Accept-Ranges", "ETag", "Last-Modified", "Retry-After", "X-Request-ID"}
+	}
+	for i, origin := range c.AllowedOrigins {
+		origin = strings.TrimRight(strings.ToLower(strings.TrimSpace(origin)), "/")
+		c.AllowedOrigins[i] = origin
+		if origin == "*" {
+			if c.AllowCredentials {
+				p.add("cors.allowCredentials", "cannot be combined with allowedOrigins *")
+			}
+			continue
+		}
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || (scheme != "http" && scheme != "https") || host == "" || strings.ContainsAny(host, "/?#") ||
+			strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+			p.add(fmt.Sprintf("cors.allowedOrigins.%d", i), "invalid origin %q", origin)
+		}
+	}
+	for i, method := range c.AllowedMethods {
+		c.AllowedMethods[i] = strings.ToUpper(strings.TrimSpace(method))
+	}
+	c.MaxAge = strings.TrimSpace(c.MaxAge)
+	if c.MaxAge == "" {
+		c.MaxAge = "10m"
+	}
+	p.duration("cors.maxAge", c.MaxAge, true)
+}
+
+// normalize 为未配置的限流预算填充默认值并校验锁定参数。
+func (r *RateLimitConfig) normalize(p *problems) {
+	budgets := []struct {
+		name     string
+		budget   *RateBudget
+		defaults RateBudget
+	}{
+		{"proxy", &r.Proxy, RateBudget{PerIP: 50, PerIdentity: 100, Burst: 200}},
+		{"sql", &r.SQL, RateBudget{PerIP: 20, PerIdentity: 20, Burst: 40}},
+		{"screenshot", &r.Screenshot, RateBudget{PerIP: 5, PerIdentity: 5, Burst: 20}},
+	}
+	for _, b := range budgets {
+		if b.budget.PerIP == 0 {
+			b.budget.PerIP = b.defaults.PerIP
+		}
+		if b.budget.PerIdentity == 0 {
+			b.budget.PerIdentity = b.defaults.PerIdentity
+		}
+		if b.budget.Burst == 0 {
+			b.budget.Burst = b.defaults.Burst
+		}
+		if b.budget.Burst < 0 {
+			p.add("rateLimit."+b.name+".burst", "must be positive")
+		}
+	}
+
+	lockout := &r.Lockout
+	if lockout.MaxFailures == 0 {
+		lockout.MaxFailures = 5
+	}
+	valid := true
+	for _, field := range []struct {
+		name  string
+		value *string
+		def   string
+	}{
+		{"window", &lockout.Window, "10m"},
+		{"baseDuration", &lockout.BaseDuration, "1m"},
+		{"maxDuration", &lockout.MaxDuration, "1h"},
+	} {
+		*field.value = strings.TrimSpace(*field.value)
+		if *field.value == "" {
+			*field.value = field.def
+		}
+		before := len(*p)
+		p.duration("rateLimit.lockout."+field.name, *field.value, false)
+		valid = valid && len(*p) == before
+	}
+	if valid && lockout.MaxDurationValue() < lockout.BaseDurationValue() {
+		p.add("rateLimit.lockout.maxDuration", "must not be shorter than baseDuration")
+	}
 }
 
 // EnsureScreenshotDir 确保截图目录存在，跨端部署时可直接复用。
@@ -98,6 +763,81 @@ func (c *Config) EnsureScreenshotDir() error {
 	return nil
 }
 
+// parseOptionalDuration 解析可选的 duration 字符串，空值或非法值返回 0。
+func parseOptionalDuration(raw string) time.Duration {
+	if strings.TrimSpace(raw) == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ProbeIntervalDuration 返回链路探测间隔，未配置时为 0 由代理模块使用默认值。
+func (r ProxyRouting) ProbeIntervalDuration() time.Duration {
+	return parseOptionalDuration(r.ProbeInterval)
+}
+
+// ProbeTimeoutDuration 返回单次探测超时，未配置时为 0。
+func (r ProxyRouting) ProbeTimeoutDuration() time.Duration {
+	return parseOptionalDuration(r.ProbeTimeout)
+}
+
+// SessionTTLDuration 返回会话粘滞时长，未配置时为 0。
+func (r ProxyRouting) SessionTTLDuration() time.Duration {
+	return parseOptionalDuration(r.SessionTTL)
+}
+
+// FlushIntervalDuration 返回计量落库间隔，未配置时为 0 由计量模块使用默认值。
+func (u UsageConfig) FlushIntervalDuration() time.Duration {
+	return parseOptionalDuration(u.FlushInterval)
+}
+
+// byteUnits 同时支持十进制（KB/MB/GB/TB）与二进制（KiB/MiB/GiB/TiB）单位。
+var byteUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseByteSize 解析 `50GiB`、`1.5TB`、`1048576` 等字节大小，空值返回 0。
+func ParseByteSize(raw string) (int64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	i := 0
+	for i < len(raw) && (raw[i] == '.' || (raw[i] >= '0' && raw[i] <= '9')) {
+		i++
+	}
+	value, err := strconv.ParseFloat(raw[:i], 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("invalid byte size %q", raw)
+	}
+	unit, ok := byteUnits[strings.ToLower(strings.TrimSpace(raw[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid byte size unit in %q", raw)
+	}
+	return int64(value * float64(unit)), nil
+}
+
+// ShutdownTimeoutDuration 返回优雅退出的排空超时，未配置或非法时为 30s。
+func (c Config) ShutdownTimeoutDuration() time.Duration {
+	if d := parseOptionalDuration(c.ShutdownTimeout); d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
 // ConnMaxLifetime 解析连接最大生命周期，便于数据库模块配置连接池。
 func (c Config) ConnMaxLifetime() (time.Duration, bool) {
 	if c.ConnMaxLife == "" {
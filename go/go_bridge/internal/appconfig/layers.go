@@ -0,0 +1,385 @@
+package appconfig
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix 为覆盖配置项的环境变量前缀，如 GO_BRIDGE_LISTEN、GO_BRIDGE_PROXYCHAIN_0_ENDPOINT。
+const envPrefix = "GO_BRIDGE_"
+
+// errUnknownKey 表示路径不对应任何配置字段；环境变量层据此忽略无关的 GO_BRIDGE_* 变量。
+var errUnknownKey = errors.New("unknown config key")
+
+// 配置项来源，按优先级从低到高。
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Options 描述命令行层的配置覆盖，优先级高于配置文件与环境变量。
+type Options struct {
+	// Path 为 --config 指定的配置文件，优先于 GO_BRIDGE_CONFIG。
+	Path string
+	// Overrides 为 key=value 形式的覆盖项，key 使用点分隔的 yaml 字段名（如 proxyChain.0.endpoint）。
+	Overrides []string
+	// CheckWritable 通过创建并删除临时文件确认 screenshotDir 可写，仅在启动与 check-config 时开启；
+	// 热更新不做该检查，避免校验产生文件系统副作用或因目录短暂只读而拒绝新配置。
+	CheckWritable bool
+}
+
+// Sources 记录每个配置项（点分隔路径）的来源，未记录的项为默认值。
+type Sources map[string]string
+
+// ConfigPath 返回本次加载使用的配置文件路径，以及该路径是否由调用方显式指定。
+func (o Options) ConfigPath() (string, bool) {
+	if o.Path != "" {
+		return o.Path, true
+	}
+	if env := os.Getenv("GO_BRIDGE_CONFIG"); env != "" {
+		return env, true
+	}
+	return "config.yaml", false
+}
+
+// LoadWithSources 依次叠加默认值、可选的配置文件、GO_BRIDGE_* 环境变量与命令行覆盖项，
+// 再解析敏感字段中的 file:// / env:// / exec:// 引用，返回生效的配置及各项来源。显式指定的配置文件不存在时报错，默认的 config.yaml 不存在时忽略。
+// 字段校验失败时返回 *ValidationError，列出全部问题及其所在的文件行号或环境变量、命令行参数。
+func LoadWithSources(requireDatabase bool, opts Options) (Config, Sources, error) {
+	var cfg Config
+	sources := Sources{}
+	lines := map[string]int{}
+	// typeErrors 为字段类型不匹配（如 maxOpenConns: abc）的问题，与 normalize 的结果合并报告。
+	var typeErrors problems
+
+	path, explicit := opts.ConfigPath()
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var doc yaml.Node
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return Config{}, nil, fmt.Errorf("parse config: %w", err)
+		}
+		var typeErr *yaml.TypeError
+		if err := doc.Decode(&cfg); errors.As(err, &typeErr) {
+			for _, msg := range typeErr.Errors {
+				problem := Problem{Message: msg}
+				var line int
+				if n, _ := fmt.Sscanf(msg, "line %d:", &line); n == 1 {
+					problem.Location = fmt.Sprintf("%s:%d", path, line)
+					problem.Message = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+				}
+				typeErrors = append(typeErrors, problem)
+			}
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, nil, fmt.Errorf("parse config: %w", err)
+		}
+		if len(doc.Content) > 0 {
+			markFileSources(doc.Content[0], "", sources)
+			fileLines(doc.Content[0], "", lines)
+		}
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+	default:
+		return Config{}, nil, fmt.Errorf("read config: %w", err)
+	}
+
+	env := os.Environ()
+	sort.Strings(env)
+	for _, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		if !strings.HasPrefix(key, envPrefix) || key == "GO_BRIDGE_CONFIG" {
+			continue
+		}
+		tokens := strings.Split(strings.TrimPrefix(key, envPrefix), "_")
+		field, err := assignPath(reflect.ValueOf(&cfg).Elem(), tokens, true, value)
+		if errors.Is(err, errUnknownKey) {
+			// 容器环境中常有同前缀的部署元数据或已改名的旧变量，忽略并提示，不阻止启动。
+			slog.Warn("ignoring unknown config env var", "env", key, "error", err)
+			continue
+		}
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("env %s: %w", key, err)
+		}
+		sources[field] = SourceEnv + " " + key
+	}
+
+	for _, override := range opts.Overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			return Config{}, nil, fmt.Errorf("flag %q: expected key=value", override)
+		}
+		field, err := assignPath(reflect.ValueOf(&cfg).Elem(), strings.Split(strings.TrimSpace(key), "."), false, value)
+		if err != nil {
+			return Config{}, nil, fmt.Errorf("flag %s: %w", key, err)
+		}
+		sources[field] = SourceFlag + " " + key
+	}
+
+	if err := cfg.resolveSecrets(sources); err != nil {
+		return Config{}, nil, err
+	}
+	err = cfg.normalize(requireDatabase, opts.CheckWritable)
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		invalid.locate(path, lines, sources)
+		invalid.Problems = append(typeErrors, invalid.Problems...)
+		return Config{}, nil, invalid
+	}
+	if err := typeErrors.err(); err != nil {
+		return Config{}, nil, err
+	}
+	return cfg, sources, nil
+}
+
+// markFileSources 记录配置文件中出现的字段路径；标量数组作为一个整体记录。
+func markFileSources(node *yaml.Node, prefix string, sources Sources) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			markFileSources(node.Content[i+1], joinPath(prefix, node.Content[i].Value), sources)
+		}
+	case yaml.SequenceNode:
+		scalars := true
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				scalars = false
+				break
+			}
+		}
+		if scalars {
+			sources[prefix] = SourceFile
+			return
+		}
+		for i, item := range node.Content {
+			markFileSources(item, joinPath(prefix, strconv.Itoa(i)), sources)
+		}
+	default:
+		sources[prefix] = SourceFile
+	}
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" || name == "" {
+		return prefix + name
+	}
+	return prefix + "." + name
+}
+
+// assignPath 按路径段定位配置字段并写入 raw，返回规范化的点分隔路径。
+// 字段名与 yaml 标签不区分大小写匹配；group 为 true 时（环境变量）允许多个段拼成一个字段名，
+// 因此 GO_BRIDGE_AUTHTOKEN 与 GO_BRIDGE_AUTH_TOKEN 都对应 authToken，数字段表示数组下标。
+func assignPath(v reflect.Value, tokens []string, group bool, raw string) (string, error) {
+	if len(tokens) == 0 || isLeaf(v.Type()) {
+		if len(tokens) > 0 {
+			return "", fmt.Errorf("%w %s", errUnknownKey, strings.Join(tokens, "."))
+		}
+		return "", setLeaf(v, raw)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		maxGroup := 1
+		if group {
+			maxGroup = len(tokens)
+		}
+		for n := maxGroup; n >= 1; n-- {
+			name := strings.Join(tokens[:n], "")
+			for i := 0; i < v.NumField(); i++ {
+				tag := yamlName(v.Type().Field(i))
+				if tag == "" || !strings.EqualFold(tag, name) {
+					continue
+				}
+				rest, err := assignPath(v.Field(i), tokens[n:], group, raw)
+				if err != nil {
+					// 较长的分组可能恰好命中同名前缀，路径不存在时继续尝试更短的分组；
+					// 字段已命中但取值无法解析时直接返回，不能当作未知字段忽略。
+					if n > 1 && errors.Is(err, errUnknownKey) {
+						break
+					}
+					return "", err
+				}
+				return joinPath(tag, rest), nil
+			}
+		}
+		return "", fmt.Errorf("%w %s", errUnknownKey, strings.Join(tokens, "."))
+	case reflect.Slice:
+		index, err := strconv.Atoi(tokens[0])
+		if err != nil || index < 0 || index > 64 {
+			return "", fmt.Errorf("%w %s: invalid index %q", errUnknownKey, strings.Join(tokens, "."), tokens[0])
+		}
+		// 先写入元素副本，成功后再扩容数组，避免无效路径留下空元素。
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if index < v.Len() {
+			elem.Set(v.Index(index))
+		}
+		rest, err := assignPath(elem, tokens[1:], group, raw)
+		if err != nil {
+			return "", err
+		}
+		if index >= v.Len() {
+			grown := reflect.MakeSlice(v.Type(), index+1, index+1)
+			reflect.Copy(grown, v)
+			v.Set(grown)
+		}
+		v.Index(index).Set(elem)
+		return joinPath(tokens[0], rest), nil
+	}
+	return "", fmt.Errorf("unsupported config key %s", strings.Join(tokens, "."))
+}
+
+// isLeaf 判断字段是否按单个值赋值：标量、可选整数与逗号分隔的字符串数组。
+func isLeaf(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return false
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	case reflect.Ptr:
+		return isLeaf(t.Elem())
+	}
+	return true
+}
+
+func setLeaf(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool %q", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setLeaf(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// secretFields 为输出时需要脱敏的字段名。
+var secretFields = map[string]bool{
+	"authToken":  true,
+	"dsn":        true,
+	"secretHash": true,
+}
+
+// PrintConfig 以 `路径: 值  # 来源` 的形式输出生效配置，敏感字段以 ****** 代替。
+func PrintConfig(w io.Writer, cfg Config, sources Sources) error {
+	var lines []string
+	walkLeaves(reflect.ValueOf(cfg), "", "", func(path, name string, v reflect.Value) {
+		value := formatLeaf(v)
+		if secretFields[name] && value != `""` {
+			value = secretRedacted
+		}
+		source, ok := sources[path]
+		if !ok {
+			source = SourceDefault
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s  # %s", path, value, source))
+	})
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// EffectiveValues 返回以配置路径为键的生效值（如 proxyChain.0.endpoint），敏感字段以 ****** 代替，
+// 供 /admin/status 等诊断接口输出。
+func EffectiveValues(cfg Config) map[string]any {
+	values := map[string]any{}
+	walkLeaves(reflect.ValueOf(cfg), "", "", func(path, name string, v reflect.Value) {
+		if secretFields[name] && !v.IsZero() {
+			values[path] = secretRedacted
+			return
+		}
+		values[path] = v.Interface()
+	})
+	return values
+}
+
+func walkLeaves(v reflect.Value, path, name string, visit func(path, name string, v reflect.Value)) {
+	if path != "" && isLeaf(v.Type()) {
+		visit(path, name, v)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			tag := yamlName(v.Type().Field(i))
+			if tag == "" {
+				continue
+			}
+			walkLeaves(v.Field(i), joinPath(path, tag), tag, visit)
+		}
+	case reflect.Slice:
+		if v.Len() == 0 {
+			visit(path, name, v)
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			walkLeaves(v.Index(i), joinPath(path, strconv.Itoa(i)), name, visit)
+		}
+	}
+}
+
+func formatLeaf(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return "null"
+		}
+		return formatLeaf(v.Elem())
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Slice:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, formatLeaf(v.Index(i)))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,98 @@
+package appconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWithSourcesLayersFileEnvAndFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	raw := "listen: \":8000\"\nauthToken: from-file\nproxyChain:\n  - endpoint: https://file.example.com\n"
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("GO_BRIDGE_CONFIG", path)
+	t.Setenv("GO_BRIDGE_LISTEN", ":8100")
+	t.Setenv("GO_BRIDGE_PROXYCHAIN_1_ENDPOINT", "https://env.example.com/")
+	t.Setenv("GO_BRIDGE_PROXYCHAIN_1_AUTH_TOKEN", "hop-secret")
+	t.Setenv("GO_BRIDGE_TLS_HOSTS", "a.example.com, b.example.com")
+
+	cfg, sources, err := LoadWithSources(false, Options{Overrides: []string{"listen=:8200", "usage.quotas.0.identity=bob"}})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Listen != ":8200" || sources["listen"] != "flag listen" {
+		t.Fatalf("flag should win: %q from %q", cfg.Listen, sources["listen"])
+	}
+	if len(cfg.ProxyChain) != 2 || cfg.ProxyChain[0].Endpoint != "https://file.example.com" ||
+		cfg.ProxyChain[1].Endpoint != "https://env.example.com" || cfg.ProxyChain[1].AuthToken != "hop-secret" {
+		t.Fatalf("unexpected proxy chain: %+v", cfg.ProxyChain)
+	}
+	if sources["proxyChain.0.endpoint"] != SourceFile || sources["proxyChain.1.authToken"] != "env GO_BRIDGE_PROXYCHAIN_1_AUTH_TOKEN" {
+		t.Fatalf("unexpected sources: %v", sources)
+	}
+	if len(cfg.TLS.Hosts) != 2 || cfg.TLS.Hosts[1] != "b.example.com" {
+		t.Fatalf("unexpected hosts: %v", cfg.TLS.Hosts)
+	}
+
+	var out strings.Builder
+	if err := PrintConfig(&out, cfg, sources); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	printed := out.String()
+	if strings.Contains(printed, "from-file") || strings.Contains(printed, "hop-secret") {
+		t.Fatalf("secrets must be redacted:\n%s", printed)
+	}
+	for _, want := range []string{
+		`listen: ":8200"  # flag listen`,
+		`maxOpenConns: 5  # default`,
+		`proxyChain.1.authToken: ******  # env GO_BRIDGE_PROXYCHAIN_1_AUTH_TOKEN`,
+	} {
+		if !strings.Contains(printed, want) {
+			t.Fatalf("missing %q in:\n%s", want, printed)
+		}
+	}
+}
+
+func TestLoadWithSourcesOptionalFile(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+	defer os.Chdir(wd)
+	t.Setenv("GO_BRIDGE_CONFIG", "")
+	if _, _, err := LoadWithSources(false, Options{}); err != nil {
+		t.Fatalf("missing default config.yaml should be allowed: %v", err)
+	}
+	if _, _, err := LoadWithSources(false, Options{Path: "missing.yaml"}); err == nil {
+		t.Fatalf("explicit config path must exist")
+	}
+	t.Setenv("GO_BRIDGE_UNKNOWN", "1")
+	t.Setenv("GO_BRIDGE_PROXYCHAIN_0_REGION", "jp")
+	cfg, _, err := LoadWithSources(false, Options{})
+	if err != nil {
+		t.Fatalf("unknown env keys should be ignored: %v", err)
+	}
+	if len(cfg.ProxyChain) != 0 {
+		t.Fatalf("ignored env key must not leave an empty hop: %+v", cfg.ProxyChain)
+	}
+	if _, _, err := LoadWithSources(false, Options{Overrides: []string{"unknown=1"}}); err == nil {
+		t.Fatalf("unknown flag key should be rejected")
+	}
+	for key, value := range map[string]string{
+		"GO_BRIDGE_MAX_OPEN_CONNS":  "abc",
+		"GO_BRIDGE_TLS_SELF_SIGNED": "yes",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			if _, _, err := LoadWithSources(false, Options{}); err == nil || !strings.Contains(err.Error(), "env "+key) {
+				t.Fatalf("invalid value for a known env key must fail, got %v", err)
+			}
+		})
+	}
+}
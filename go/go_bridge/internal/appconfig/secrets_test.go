@@ -0,0 +1,69 @@
+package appconfig
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadResolvesSecretReferences(t *testing.T) {
+	dir := t.TempDir()
+	dsnFile := filepath.Join(dir, "db_dsn")
+	if err := os.WriteFile(dsnFile, []byte("postgres://bridge:s3cr3t-pass@db:5432/alist\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	raw := "driver: postgres\n" +
+		"dsn: file://" + dsnFile + "\n" +
+		"authToken: env://BRIDGE_TEST_TOKEN\n" +
+		"proxyChain:\n  - endpoint: https://hop.example.com\n    authToken: exec://echo hop-token-value\n"
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("BRIDGE_TEST_TOKEN", "legacy-token-value")
+
+	cfg, sources, err := LoadWithSources(true, Options{Path: path})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.DSN != "postgres://bridge:s3cr3t-pass@db:5432/alist" || cfg.AuthToken != "legacy-token-value" ||
+		cfg.ProxyChain[0].AuthToken != "hop-token-value" {
+		t.Fatalf("references not resolved: %+v", cfg)
+	}
+	if sources["dsn"] != "file, secret file://"+dsnFile || sources["proxyChain.0.authToken"] != "file, secret exec://echo" {
+		t.Fatalf("unexpected sources: %v", sources)
+	}
+
+	redacted := cfg.Redact(`pq: password authentication failed for "bridge" (s3cr3t-pass) legacy-token-value`)
+	if strings.Contains(redacted, "s3cr3t-pass") || strings.Contains(redacted, "legacy-token-value") {
+		t.Fatalf("secrets not redacted: %s", redacted)
+	}
+
+	var out strings.Builder
+	if err := PrintConfig(&out, cfg, sources); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	if strings.Contains(out.String(), "s3cr3t") || strings.Contains(out.String(), "hop-token-value") {
+		t.Fatalf("print-config leaked a secret:\n%s", out.String())
+	}
+
+	os.Unsetenv("BRIDGE_TEST_TOKEN")
+	if _, _, err := LoadWithSources(true, Options{Path: path}); err == nil || !strings.Contains(err.Error(), "authToken") {
+		t.Fatalf("missing env reference should fail with field name, got %v", err)
+	}
+}
+
+func TestDSNPassword(t *testing.T) {
+	cases := map[string]string{
+		"host=127.0.0.1 user=root password=w1234 dbname=postgres": "w1234",
+		"root:pa:ss@tcp(127.0.0.1:3306)/alist":                    "pa:ss",
+		"oracle://scott:tiger@db:1521/XE":                         "tiger",
+		"host=127.0.0.1 dbname=postgres":                          "",
+	}
+	for dsn, want := range cases {
+		if got := dsnPassword(dsn); got != want {
+			t.Fatalf("dsnPassword(%q) = %q, want %q", dsn, got, want)
+		}
+	}
+}
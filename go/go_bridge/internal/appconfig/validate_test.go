@@ -0,0 +1,110 @@
+package appconfig
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadReportsEveryProblemWithLocation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	raw := strings.Join([]string{
+		"listen: \"127.0.0.1:http\"",
+		"driver: mysql",
+		"dsn: \"root:s3cr3t@tcp(db:3306\"",
+		"maxOpenConns: 2",
+		"maxIdleConns: 4",
+		"connMaxLifetime: 30 minutes",
+		"screenshotDir: " + filepath.Join(dir, "shots"),
+		"proxyChain:",
+		"  - endpoint: ftp://hop.example.com",
+		"  - endpoint: https://",
+		"tokens:",
+		"  - secretHash: plain",
+		"    scopes: [proxy, everything]",
+		"rateLimit:",
+		"  sql:",
+		"    burst: many",
+		"",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(raw), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("GO_BRIDGE_SHUTDOWNTIMEOUT", "-5s")
+
+	_, _, err := LoadWithSources(true, Options{Path: path})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	report := err.Error()
+	if strings.Contains(report, "s3cr3t") {
+		t.Fatalf("report must not leak the dsn:\n%s", report)
+	}
+	for _, want := range []string{
+		path + ":16: cannot unmarshal",
+		path + ":1: listen: \"127.0.0.1:http\": port must be a number",
+		path + ":3: dsn: mysql DSN must look like",
+		path + ":5: maxIdleConns: 4 must not exceed maxOpenConns 2",
+		path + ":6: connMaxLifetime: invalid duration",
+		"env GO_BRIDGE_SHUTDOWNTIMEOUT: shutdownTimeout: must be a positive duration",
+		path + ":9: proxyChain.0.endpoint: URL \"ftp://hop.example.com\" must use http or https",
+		path + ":10: proxyChain.1.endpoint: URL \"https:\" has no host",
+		path + ":12: tokens.0.name: is required",
+		path + ":12: tokens.0.secretHash: must use sha256:<hex>",
+		path + ":13: tokens.0.scopes.1: unknown scope \"everything\"",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("missing %q in:\n%s", want, report)
+		}
+	}
+	if len(invalid.Problems) != 11 {
+		t.Errorf("expected 11 problems, got %d:\n%s", len(invalid.Problems), report)
+	}
+}
+
+func TestCheckDSN(t *testing.T) {
+	cases := []struct {
+		driver, dsn string
+		ok          bool
+	}{
+		{"mysql", "root:p@ss/word@tcp(127.0.0.1:3306)/alist?parseTime=true", true},
+		{"mysql", "root@/alist", true},
+		{"mysql", "root:pass@tcp(127.0.0.1:3306/alist", false},
+		{"mysql", "root:pass@127.0.0.1", false},
+		{"postgres", "user=postgres password='a b\\'c' host=127.0.0.1 dbname=alist sslmode=disable", true},
+		{"postgres", "postgresql://bridge:pass@db:5432/alist", true},
+		{"postgres", "host=127.0.0.1 dbname", false},
+		{"postgres", "password='unterminated host=db", false},
+		{"postgres", "mysql://db/alist", false},
+		{"oracle", "oracle://scott:tiger@db:1521/ORCLPDB1", true},
+		{"oracle", "scott/tiger@db:1521/ORCLPDB1", false},
+	}
+	for _, tc := range cases {
+		if msg := checkDSN(tc.driver, tc.dsn); (msg == "") != tc.ok {
+			t.Errorf("checkDSN(%s, %q) = %q, want ok=%v", tc.driver, tc.dsn, msg, tc.ok)
+		}
+	}
+}
+
+func TestScreenshotDirMustBeWritable(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("GO_BRIDGE_CONFIG", "")
+	overrides := []string{"driver=postgres", "dsn=host=db dbname=alist", "screenshotDir=" + filepath.Join(file, "shots")}
+	_, _, err := LoadWithSources(true, Options{Overrides: overrides, CheckWritable: true})
+	if err == nil || !strings.Contains(err.Error(), "flag screenshotDir: screenshotDir: ") || !strings.Contains(err.Error(), "not a directory") {
+		t.Fatalf("expected screenshotDir problem, got %v", err)
+	}
+	if _, _, err := LoadWithSources(true, Options{Overrides: overrides}); err != nil {
+		t.Fatalf("hot reload must not probe the screenshot dir: %v", err)
+	}
+	if _, _, err := LoadWithSources(false, Options{Overrides: []string{"screenshotDir=" + file}, CheckWritable: true}); err != nil {
+		t.Fatalf("proxy-only mode does not use the screenshot dir: %v", err)
+	}
+}
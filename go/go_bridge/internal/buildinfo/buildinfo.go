@@ -0,0 +1,43 @@
+package buildinfo
+
+import "runtime/debug"
+
+// Version 与 Commit 由发布脚本通过 -ldflags "-X" 注入；本地 go build 时 Version 为 dev，
+// Commit 回退到 Go 工具链记录的 vcs.revision。
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// 构建模式，对应是否带 proxy_only 构建标签。
+const (
+	ModeFull      = "full"
+	ModeProxyOnly = "proxy_only"
+)
+
+// CommitHash 返回构建所用的提交；工作区有未提交修改时追加 -dirty，无法获知时返回 unknown。
+func CommitHash() string {
+	if Commit != "" {
+		return Commit
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
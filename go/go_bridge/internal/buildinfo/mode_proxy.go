@@ -0,0 +1,6 @@
+//go:build proxy_only
+
+package buildinfo
+
+// Mode 为当前二进制的构建模式。
+const Mode = ModeProxyOnly
@@ -11,11 +11,11 @@ import (
 	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
 )
 
-// Connect 根据配置建立数据库连接，并设置连接池参数。
+// Connect 根据配置建立数据库连接，并设置连接池参数；返回的错误已去除 DSN 中的敏感信息。
 func Connect(cfg appconfig.Config) (*sqlx.DB, error) {
 	db, err := sqlx.Open(cfg.Driver, cfg.DSN)
 	if err != nil {
-		return nil, fmt.Errorf("open db: %w", err)
+		return nil, fmt.Errorf("open db: %s", cfg.Redact(err.Error()))
 	}
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
@@ -24,7 +24,7 @@ func Connect(cfg appconfig.Config) (*sqlx.DB, error) {
 	}
 	if err := db.Ping(); err != nil {
 		_ = db.Close()
-		return nil, fmt.Errorf("ping db: %w", err)
+		return nil, fmt.Errorf("ping db: %s", cfg.Redact(err.Error()))
 	}
 	return db, nil
 }
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// TableDDL 按驱动名提供建表语句，key 与 sqlx.DB.DriverName() 一致（mysql/postgres/oracle）。
+type TableDDL map[string][]string
+
+// EnsureTable 在表不存在时执行对应驱动的建表语句；探测使用 `SELECT 1 FROM t WHERE 1=0`，
+// 兼容不支持 CREATE TABLE IF NOT EXISTS 的 Oracle。
+func EnsureTable(ctx context.Context, db *sqlx.DB, table string, ddl TableDDL) error {
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("SELECT 1 FROM %s WHERE 1=0", table)); err == nil {
+		return nil
+	}
+	statements, ok := ddl[db.DriverName()]
+	if !ok {
+		return fmt.Errorf("no ddl for table %s on driver %s", table, db.DriverName())
+	}
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("create table %s: %w", table, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package discovery
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/buildinfo"
+)
+
+// ServiceType 为 DNS-SD 服务类型。
+const ServiceType = "_alistbridge._tcp"
+
+// ProbeMessage 为 UDP 广播探测的请求内容，节点以 JSON 编码的 Announcement 应答。
+const ProbeMessage = "alistbridge.discover.v1"
+
+// Announcement 为节点通告的信息，UDP 应答与 mDNS TXT 记录携带相同的字段。
+type Announcement struct {
+	NodeID       string `json:"nodeId"`
+	Name         string `json:"name"`
+	Port         int    `json:"port"`
+	Mode         string `json:"mode"`
+	TLS          bool   `json:"tls"`
+	AuthRequired bool   `json:"authRequired"`
+	Version      string `json:"version"`
+}
+
+// Options 控制通告使用的网络参数，零值使用标准 mDNS 组播地址并在所有支持组播的网卡上监听。
+type Options struct {
+	// Interface 非空时只在该网卡上收发组播，测试中用于回环组播。
+	Interface *net.Interface
+	// MDNSAddr 默认 224.0.0.251:5353。
+	MDNSAddr *net.UDPAddr
+}
+
+// mdnsGroup 为标准 mDNS 组播地址。
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+func (o Options) mdnsAddr() *net.UDPAddr {
+	if o.MDNSAddr != nil {
+		return o.MDNSAddr
+	}
+	return mdnsGroup
+}
+
+// Announcer 在局域网内通告本节点：应答 UDP 广播探测，并作为 mDNS 响应者发布 _alistbridge._tcp 服务。
+type Announcer struct {
+	info    Announcement
+	udpPort int
+	opts    Options
+}
+
+// NewAnnouncer 根据配置创建通告器；关闭了发现、或没有局域网可达的 TCP 监听地址（只监听回环或 Unix 套接字）时返回 ok=false。
+func NewAnnouncer(cfg appconfig.Config, opts Options) (*Announcer, bool) {
+	if cfg.Discovery.Disabled {
+		return nil, false
+	}
+	port, ok := lanPort(cfg.ListenAddrs())
+	if !ok {
+		return nil, false
+	}
+	hostname, _ := os.Hostname()
+	name := cfg.Discovery.Name
+	if name == "" {
+		name = hostname
+	}
+	nodeID := cfg.Discovery.NodeID
+	if nodeID == "" {
+		sum := sha256.Sum256([]byte(hostname + "|" + cfg.Listen))
+		nodeID = hex.EncodeToString(sum[:8])
+	}
+	return &Announcer{
+		info: Announcement{
+			NodeID:       nodeID,
+			Name:         name,
+			Port:         port,
+			Mode:         buildinfo.Mode,
+			TLS:          cfg.TLS.Enabled(),
+			AuthRequired: authRequired(cfg),
+			Version:      buildinfo.Version,
+		},
+		udpPort: cfg.Discovery.UDPPort,
+		opts:    opts,
+	}, true
+}
+
+// Info 返回通告内容。
+func (a *Announcer) Info() Announcement {
+	return a.info
+}
+
+// lanPort 返回第一个局域网可达的 TCP 监听端口：监听全部地址或非回环地址；unix:// 与 systemd:// 不参与通告。
+func lanPort(addrs []string) (int, bool) {
+	for _, addr := range addrs {
+		if strings.HasPrefix(addr, appconfig.ListenUnixPrefix) || strings.HasPrefix(addr, appconfig.ListenSystemdPrefix) {
+			continue
+		}
+		host, rawPort, err := net.SplitHostPort(addr)
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() || host == "localhost" {
+			continue
+		}
+		port, err := strconv.Atoi(rawPort)
+		if err != nil || port <= 0 {
+			continue
+		}
+		return port, true
+	}
+	return 0, false
+}
+
+// authRequired 判断访问节点是否需要令牌：配置了桥接令牌或启用了 AList 委托鉴权。
+func authRequired(cfg appconfig.Config) bool {
+	return cfg.AuthToken != "" || len(cfg.Tokens) > 0 ||
+		cfg.Auth.Mode == appconfig.AuthModeAList || cfg.Auth.Mode == appconfig.AuthModeMixed
+}
+
+// Run 启动 UDP 探测应答与 mDNS 响应者，阻塞到 ctx 取消；单个通道启动失败只记录日志，不影响服务。
+func (a *Announcer) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	if a.udpPort > 0 {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{Port: a.udpPort})
+		if err != nil {
+			slog.Warn("discovery: udp responder disabled", "port", a.udpPort, "error", err)
+		} else {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				a.serveProbes(ctx, conn)
+			}()
+		}
+	}
+	responder, err := newMDNSResponder(a.info, a.opts)
+	if err != nil {
+		slog.Warn("discovery: mdns responder disabled", "error", err)
+	} else {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responder.run(ctx)
+		}()
+	}
+	slog.Info("discovery: announcing", "node_id", a.info.NodeID, "name", a.info.Name, "port", a.info.Port, "udp_port", a.udpPort)
+	wg.Wait()
+}
+
+// serveProbes 应答 UDP 广播探测，直到 ctx 取消。
+func (a *Announcer) serveProbes(ctx context.Context, conn *net.UDPConn) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+	reply, _ := json.Marshal(a.info)
+	buf := make([]byte, 512)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				slog.Warn("discovery: udp read failed", "error", err)
+			}
+			return
+		}
+		if strings.TrimSpace(string(buf[:n])) != ProbeMessage {
+			continue
+		}
+		if _, err := conn.WriteToUDP(reply, src); err != nil {
+			slog.Debug("discovery: udp reply failed", "to", src.String(), "error", err)
+		}
+	}
+}
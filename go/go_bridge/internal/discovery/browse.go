@@ -0,0 +1,230 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// probeInterval 为浏览期间重发探测的间隔，降低单个 UDP 包丢失的影响。
+const probeInterval = 500 * time.Millisecond
+
+// BrowseOptions 控制浏览参数，零值同时使用 UDP 广播与标准 mDNS。
+type BrowseOptions struct {
+	Options
+	// UDPPort 为广播探测端口，默认 7789，负数时只使用 mDNS。
+	UDPPort int
+	// Targets 为 UDP 探测的目标地址，默认发往 255.255.255.255 与各网卡的定向广播地址。
+	Targets []*net.UDPAddr
+	// DisableMDNS 为 true 时只发送 UDP 广播探测。
+	DisableMDNS bool
+}
+
+// Node 为浏览到的节点，Addr 为应答来源 IP，Via 为发现途径（udp、mdns）。
+type Node struct {
+	Announcement
+	Addr string   `json:"addr"`
+	Via  []string `json:"via"`
+}
+
+// Browse 发送 UDP 广播探测与 mDNS 查询并收集应答，直到 ctx 结束；同一节点按 nodeId 合并。
+func Browse(ctx context.Context, opts BrowseOptions) ([]Node, error) {
+	if opts.UDPPort == 0 {
+		opts.UDPPort = appconfig.DefaultDiscoveryPort
+	}
+	var (
+		mu    sync.Mutex
+		nodes = map[string]*Node{}
+		wg    sync.WaitGroup
+	)
+	found := func(info Announcement, addr net.IP, via string) {
+		if info.NodeID == "" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		node, ok := nodes[info.NodeID]
+		if !ok {
+			node = &Node{Announcement: info, Addr: addr.String()}
+			nodes[info.NodeID] = node
+		}
+		for _, v := range node.Via {
+			if v == via {
+				return
+			}
+		}
+		node.Via = append(node.Via, via)
+	}
+
+	if opts.UDPPort > 0 {
+		conn, err := net.ListenUDP("udp4", nil)
+		if err != nil {
+			return nil, err
+		}
+		targets := opts.Targets
+		if len(targets) == 0 {
+			targets = broadcastTargets(opts.UDPPort)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			browseUDP(ctx, conn, targets, found)
+		}()
+	}
+	if !opts.DisableMDNS {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+		if err != nil {
+			return nil, err
+		}
+		if opts.Interface != nil {
+			pc := ipv4.NewPacketConn(conn)
+			_ = pc.SetMulticastInterface(opts.Interface)
+			_ = pc.SetMulticastLoopback(true)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			browseMDNS(ctx, conn, opts.mdnsAddr(), found)
+		}()
+	}
+	wg.Wait()
+
+	result := make([]Node, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, *node)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].NodeID < result[j].NodeID
+	})
+	return result, nil
+}
+
+// browseUDP 定期向广播地址发送探测并解析 JSON 应答。
+func browseUDP(ctx context.Context, conn *net.UDPConn, targets []*net.UDPAddr, found func(Announcement, net.IP, string)) {
+	go resend(ctx, conn, func() {
+		for _, target := range targets {
+			_, _ = conn.WriteToUDP([]byte(ProbeMessage), target)
+		}
+	})
+	buf := make([]byte, 2048)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		var info Announcement
+		if json.Unmarshal(buf[:n], &info) == nil {
+			found(info, src.IP, "udp")
+		}
+	}
+}
+
+// browseMDNS 以一次性查询（临时端口）询问 _alistbridge._tcp 的 PTR 记录，响应者会以单播回复。
+func browseMDNS(ctx context.Context, conn *net.UDPConn, group *net.UDPAddr, found func(Announcement, net.IP, string)) {
+	service := dnsmessage.MustNewName(ServiceType + ".local.")
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{})
+	_ = b.StartQuestions()
+	_ = b.Question(dnsmessage.Question{Name: service, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET})
+	query, err := b.Finish()
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	go resend(ctx, conn, func() {
+		_, _ = conn.WriteToUDP(query, group)
+	})
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if info, ok := parseMDNSResponse(buf[:n]); ok {
+			found(info, src.IP, "mdns")
+		}
+	}
+}
+
+// parseMDNSResponse 从应答的答案与附加记录中提取 SRV 端口与 TXT 字段。
+func parseMDNSResponse(packet []byte) (Announcement, bool) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil || !header.Response {
+		return Announcement{}, false
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return Announcement{}, false
+	}
+	var records []dnsmessage.Resource
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return Announcement{}, false
+	}
+	records = append(records, answers...)
+	if err := p.SkipAllAuthorities(); err == nil {
+		if additionals, err := p.AllAdditionals(); err == nil {
+			records = append(records, additionals...)
+		}
+	}
+	var info Announcement
+	for _, record := range records {
+		switch body := record.Body.(type) {
+		case *dnsmessage.SRVResource:
+			info.Port = int(body.Port)
+		case *dnsmessage.TXTResource:
+			parseTXT(body.TXT, &info)
+		}
+	}
+	return info, info.NodeID != "" && info.Port > 0
+}
+
+// resend 立即执行 send，之后按 probeInterval 重发，ctx 结束时关闭连接以结束读取。
+func resend(ctx context.Context, conn *net.UDPConn, send func()) {
+	defer conn.Close()
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	for {
+		send()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// broadcastTargets 返回受限广播地址与各网卡的定向广播地址。
+func broadcastTargets(port int) []*net.UDPAddr {
+	targets := []*net.UDPAddr{{IP: net.IPv4bcast, Port: port}}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return targets
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() {
+			continue
+		}
+		ip := ipNet.IP.To4()
+		if ip == nil || len(ipNet.Mask) != net.IPv4len {
+			continue
+		}
+		bcast := make(net.IP, net.IPv4len)
+		for i := range ip {
+			bcast[i] = ip[i] | ^ipNet.Mask[i]
+		}
+		targets = append(targets, &net.UDPAddr{IP: bcast, Port: port})
+	}
+	return targets
+}
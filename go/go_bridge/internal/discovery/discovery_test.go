@@ -0,0 +1,102 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// multicastInterface 优先返回支持组播的回环网卡，否则返回任一已启用的组播网卡。
+func multicastInterface(t *testing.T) *net.Interface {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("interfaces: %v", err)
+	}
+	var fallback *net.Interface
+	for i := range ifaces {
+		iface := &ifaces[i]
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface
+		}
+		if fallback == nil {
+			fallback = iface
+		}
+	}
+	if fallback == nil {
+		t.Skip("no multicast-capable interface")
+	}
+	return fallback
+}
+
+func freeUDPPort(t *testing.T) int {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestAnnouncerAnswersUDPProbeAndMDNS(t *testing.T) {
+	iface := multicastInterface(t)
+	opts := Options{Interface: iface, MDNSAddr: &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: freeUDPPort(t)}}
+	udpPort := freeUDPPort(t)
+	cfg := appconfig.Config{
+		Listen:    "0.0.0.0:7788,unix:///tmp/bridge.sock",
+		AuthToken: "secret",
+		Discovery: appconfig.DiscoveryConfig{NodeID: "node-a", Name: "nas.living-room", UDPPort: udpPort},
+	}
+	announcer, ok := NewAnnouncer(cfg, opts)
+	if !ok {
+		t.Fatalf("announcer should be enabled for a LAN listener")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		announcer.Run(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	browseCtx, stop := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer stop()
+	nodes, err := Browse(browseCtx, BrowseOptions{
+		Options: opts,
+		UDPPort: udpPort,
+		Targets: []*net.UDPAddr{{IP: net.IPv4(127, 0, 0, 1), Port: udpPort}},
+	})
+	if err != nil {
+		t.Fatalf("browse: %v", err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("expected one node, got %+v", nodes)
+	}
+	node := nodes[0]
+	want := Announcement{NodeID: "node-a", Name: "nas.living-room", Port: 7788, Mode: announcer.Info().Mode, AuthRequired: true, Version: announcer.Info().Version}
+	if node.Announcement != want {
+		t.Fatalf("announcement mismatch:\n got %+v\nwant %+v", node.Announcement, want)
+	}
+	if len(node.Via) != 2 {
+		t.Fatalf("node should be found via udp and mdns, got %v", node.Via)
+	}
+}
+
+func TestNewAnnouncerSkipsLocalOnlyListeners(t *testing.T) {
+	for _, listen := range []string{"127.0.0.1:7788", "unix:///run/bridge.sock", "localhost:7788,systemd://http"} {
+		if _, ok := NewAnnouncer(appconfig.Config{Listen: listen}, Options{}); ok {
+			t.Fatalf("%s is not reachable from the LAN and must not be announced", listen)
+		}
+	}
+	if _, ok := NewAnnouncer(appconfig.Config{Listen: ":7788", Discovery: appconfig.DiscoveryConfig{Disabled: true}}, Options{}); ok {
+		t.Fatalf("disabled discovery must not announce")
+	}
+}
@@ -0,0 +1,275 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// recordTTL 为通告记录的缓存时间（秒），退出时以 TTL 0 发送 goodbye。
+	recordTTL = 120
+	// cacheFlush 为 mDNS 唯一记录的 class 高位（RFC 6762 §10.2）。
+	cacheFlush = 1 << 15
+	// unicastResponse 为问题 class 的 QU 位，表示希望以单播应答。
+	unicastResponse = 1 << 15
+)
+
+// mdnsResponder 应答 _alistbridge._tcp 的 PTR、SRV、TXT 与 A 查询，启动时主动通告一次。
+type mdnsResponder struct {
+	info     Announcement
+	group    *net.UDPAddr
+	conn     *net.UDPConn
+	service  dnsmessage.Name
+	instance dnsmessage.Name
+	host     dnsmessage.Name
+	ips      []net.IP
+}
+
+func newMDNSResponder(info Announcement, opts Options) (*mdnsResponder, error) {
+	group := opts.mdnsAddr()
+	conn, err := net.ListenMulticastUDP("udp4", opts.Interface, group)
+	if err != nil {
+		return nil, err
+	}
+	pc := ipv4.NewPacketConn(conn)
+	if opts.Interface != nil {
+		_ = pc.SetMulticastInterface(opts.Interface)
+		_ = pc.SetMulticastLoopback(true)
+	} else if ifaces, err := net.Interfaces(); err == nil {
+		// ListenMulticastUDP 只加入默认网卡，其余支持组播的网卡逐个加入。
+		for i := range ifaces {
+			if ifaces[i].Flags&net.FlagUp != 0 && ifaces[i].Flags&net.FlagMulticast != 0 {
+				_ = pc.JoinGroup(&ifaces[i], &net.UDPAddr{IP: group.IP})
+			}
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	r := &mdnsResponder{info: info, group: group, conn: conn, ips: announceIPs(opts.Interface)}
+	names := []struct {
+		dst  *dnsmessage.Name
+		text string
+	}{
+		{&r.service, ServiceType + ".local."},
+		{&r.instance, dnsLabel(info.Name) + "." + ServiceType + ".local."},
+		{&r.host, dnsLabel(hostname) + ".local."},
+	}
+	for _, n := range names {
+		if *n.dst, err = dnsmessage.NewName(n.text); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("mdns name %q: %w", n.text, err)
+		}
+	}
+	return r, nil
+}
+
+// run 处理查询直到 ctx 取消，退出前发送 goodbye 使浏览器及时移除本节点。
+func (r *mdnsResponder) run(ctx context.Context) {
+	stop := context.AfterFunc(ctx, func() { _ = r.conn.Close() })
+	defer stop()
+	defer r.conn.Close()
+	r.announce(recordTTL)
+	defer r.announce(0)
+
+	buf := make([]byte, 9000)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, net.ErrClosed) {
+				slog.Warn("discovery: mdns read failed", "error", err)
+			}
+			return
+		}
+		r.handle(buf[:n], src)
+	}
+}
+
+// announce 以组播发送完整记录；ttl 为 0 时为 goodbye，连接已关闭时改用临时套接字发送。
+func (r *mdnsResponder) announce(ttl uint32) {
+	msg, err := r.response(dnsmessage.Header{Response: true, Authoritative: true}, nil, true, true, ttl)
+	if err != nil {
+		return
+	}
+	if _, err := r.conn.WriteToUDP(msg, r.group); err == nil {
+		return
+	}
+	if conn, err := net.DialUDP("udp4", nil, r.group); err == nil {
+		_, _ = conn.Write(msg)
+		_ = conn.Close()
+	}
+}
+
+// handle 解析查询并应答命中的问题；来源端口不是 mDNS 端口（一次性查询）或带 QU 位时以单播回复。
+func (r *mdnsResponder) handle(packet []byte, src *net.UDPAddr) {
+	var p dnsmessage.Parser
+	header, err := p.Start(packet)
+	if err != nil || header.Response {
+		return
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return
+	}
+	var ptr, records bool
+	unicast := src.Port != r.group.Port
+	for _, q := range questions {
+		if q.Class&unicastResponse != 0 {
+			unicast = true
+		}
+		switch {
+		case sameName(q.Name, r.service) && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL):
+			ptr, records = true, true
+		case sameName(q.Name, r.instance) || sameName(q.Name, r.host):
+			records = true
+		}
+	}
+	if !ptr && !records {
+		return
+	}
+	replyHeader := dnsmessage.Header{Response: true, Authoritative: true}
+	var echo []dnsmessage.Question
+	if src.Port != r.group.Port {
+		// 传统单播查询（RFC 6762 §6.7）需要回显 ID 与问题。
+		replyHeader.ID = header.ID
+		echo = questions
+	}
+	msg, err := r.response(replyHeader, echo, ptr, records, recordTTL)
+	if err != nil {
+		return
+	}
+	dst := r.group
+	if unicast {
+		dst = src
+	}
+	if _, err := r.conn.WriteToUDP(msg, dst); err != nil {
+		slog.Debug("discovery: mdns reply failed", "to", dst.String(), "error", err)
+	}
+}
+
+// response 构造应答：ptr 时包含服务到实例的 PTR 记录，records 时附带实例的 SRV、TXT 与主机的 A 记录。
+func (r *mdnsResponder) response(header dnsmessage.Header, questions []dnsmessage.Question, ptr, records bool, ttl uint32) ([]byte, error) {
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), header)
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		if err := b.Question(q); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if ptr {
+		hdr := dnsmessage.ResourceHeader{Name: r.service, Class: dnsmessage.ClassINET, TTL: ttl}
+		if err := b.PTRResource(hdr, dnsmessage.PTRResource{PTR: r.instance}); err != nil {
+			return nil, err
+		}
+	}
+	if records {
+		if err := r.appendRecords(&b, ttl); err != nil {
+			return nil, err
+		}
+	}
+	return b.Finish()
+}
+
+func (r *mdnsResponder) appendRecords(b *dnsmessage.Builder, ttl uint32) error {
+	unique := dnsmessage.ClassINET | cacheFlush
+	srv := dnsmessage.SRVResource{Port: uint16(r.info.Port), Target: r.host}
+	if err := b.SRVResource(dnsmessage.ResourceHeader{Name: r.instance, Class: unique, TTL: ttl}, srv); err != nil {
+		return err
+	}
+	if err := b.TXTResource(dnsmessage.ResourceHeader{Name: r.instance, Class: unique, TTL: ttl}, dnsmessage.TXTResource{TXT: txtRecords(r.info)}); err != nil {
+		return err
+	}
+	for _, ip := range r.ips {
+		var a dnsmessage.AResource
+		copy(a.A[:], ip.To4())
+		if err := b.AResource(dnsmessage.ResourceHeader{Name: r.host, Class: unique, TTL: ttl}, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// txtRecords 将通告内容编码为 DNS-SD TXT 键值对。
+func txtRecords(info Announcement) []string {
+	return []string{
+		"id=" + info.NodeID,
+		"name=" + info.Name,
+		"mode=" + info.Mode,
+		"tls=" + strconv.FormatBool(info.TLS),
+		"auth=" + strconv.FormatBool(info.AuthRequired),
+		"version=" + info.Version,
+	}
+}
+
+// parseTXT 从 TXT 键值对还原通告内容，端口取自 SRV 记录。
+func parseTXT(txt []string, info *Announcement) {
+	for _, item := range txt {
+		key, value, _ := strings.Cut(item, "=")
+		switch key {
+		case "id":
+			info.NodeID = value
+		case "name":
+			info.Name = value
+		case "mode":
+			info.Mode = value
+		case "tls":
+			info.TLS = value == "true"
+		case "auth":
+			info.AuthRequired = value == "true"
+		case "version":
+			info.Version = value
+		}
+	}
+}
+
+// announceIPs 返回 A 记录中的 IPv4 地址：指定网卡时取该网卡地址，否则取所有已启用的非回环地址。
+func announceIPs(iface *net.Interface) []net.IP {
+	var addrs []net.Addr
+	if iface != nil {
+		addrs, _ = iface.Addrs()
+	} else {
+		addrs, _ = net.InterfaceAddrs()
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		if iface == nil && ipNet.IP.IsLoopback() {
+			continue
+		}
+		ips = append(ips, ipNet.IP.To4())
+	}
+	return ips
+}
+
+// dnsLabel 将实例名或主机名转为单个 DNS 标签：点号会被解析为层级，替换为连字符并限制在 63 字节内。
+func dnsLabel(name string) string {
+	name = strings.ReplaceAll(strings.TrimSpace(name), ".", "-")
+	if name == "" {
+		name = "go-bridge"
+	}
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+func sameName(a, b dnsmessage.Name) bool {
+	return strings.EqualFold(a.String(), b.String())
+}
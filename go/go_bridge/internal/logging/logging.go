@@ -0,0 +1,206 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+// RequestIDHeader 为透传与回写请求 ID 的响应头，调用方传入的合法值会被沿用；代理经 hop 转发时
+// 会携带该头，下一跳日志中的 request_id 与本节点一致，便于跨 hop 关联日志。
+const RequestIDHeader = "X-Request-ID"
+
+// level 为全局日志级别，配置热更新时直接替换。
+var level = new(slog.LevelVar)
+
+type loggerKey struct{}
+
+type requestIDKey struct{}
+
+// Setup 按配置创建共享 logger 并设为 slog 默认 logger；标准库 log 与 gin 的调试输出同样转入该 logger。
+func Setup(cfg appconfig.LogConfig) *slog.Logger {
+	return setup(os.Stderr, cfg)
+}
+
+func setup(w io.Writer, cfg appconfig.LogConfig) *slog.Logger {
+	SetLevel(cfg.Level)
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(w, opts)
+	if cfg.Format == appconfig.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	gin.DebugPrintFunc = func(format string, values ...any) {
+		slog.Debug(strings.TrimSpace(fmt.Sprintf(format, values...)), "component", "gin")
+	}
+	gin.DebugPrintRouteFunc = func(method, path, handler string, _ int) {
+		slog.Debug("route registered", "component", "gin", "method", method, "path", path, "handler", handler)
+	}
+	return logger
+}
+
+// SetLevel 调整日志级别，未知取值按 info 处理。
+func SetLevel(name string) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(name)); err != nil {
+		l = slog.LevelInfo
+	}
+	level.Set(l)
+}
+
+// RequestID 返回当前请求的 ID，请求之外的调用返回空字符串。
+func RequestID(ctx context.Context) string {
+	if ctx != nil {
+		if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+// FromContext 返回携带请求 ID 的 logger，请求之外的调用返回默认 logger。
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// Middleware 为每个请求分配请求 ID，并在请求结束后输出一条访问日志：路由、状态码、耗时、响应字节数与调用方身份。
+// URL 中的 access_token 会被脱敏；5xx 记为 error，4xx 记为 warn。
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+		logger := slog.Default().With("request_id", requestID)
+		ctx := context.WithValue(c.Request.Context(), loggerKey{}, logger)
+		c.Request = c.Request.WithContext(context.WithValue(ctx, requestIDKey{}, requestID))
+		// 鉴权中间件会从 URL 中移除 access_token，这里提前记录脱敏后的原始地址。
+		path := RedactURL(c.Request.URL)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		lvl := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			lvl = slog.LevelError
+		case status >= http.StatusBadRequest:
+			lvl = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("identity", identity.Name(c)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		logger.LogAttrs(c.Request.Context(), lvl, "http request", attrs...)
+	}
+}
+
+// Recovery 捕获处理器 panic，记录堆栈后返回 500，替代 gin 默认的文本输出。
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		FromContext(c.Request.Context()).Error("panic recovered",
+			"panic", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	})
+}
+
+// RedactURL 返回用于日志的路径与查询串，access_token 的值以 REDACTED 代替；
+// 嵌套在查询参数中的地址（如代理链的 target）同样脱敏，避免下一跳的令牌出现在日志中。
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	if query, changed := redactQuery(u.RawQuery, 0); changed {
+		return u.Path + "?" + query
+	}
+	return u.Path + "?" + u.RawQuery
+}
+
+// maxRedactDepth 限制嵌套地址的递归层数，代理链通常只有两三跳。
+const maxRedactDepth = 8
+
+// redactQuery 脱敏查询串中的 access_token 及参数值中嵌套地址的 access_token，未改动时返回 false 以保留原始写法。
+func redactQuery(raw string, depth int) (string, bool) {
+	params, _ := url.ParseQuery(raw)
+	changed := false
+	for key, values := range params {
+		for i, value := range values {
+			if key == "access_token" {
+				values[i] = "REDACTED"
+				changed = true
+				continue
+			}
+			if depth >= maxRedactDepth || !strings.Contains(value, "?") {
+				continue
+			}
+			nested, err := url.Parse(value)
+			if err != nil || nested.RawQuery == "" {
+				continue
+			}
+			if query, ok := redactQuery(nested.RawQuery, depth+1); ok {
+				nested.RawQuery = query
+				values[i] = nested.String()
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return raw, false
+	}
+	return params.Encode(), true
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r == '-' || r == '_' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var buf [8]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+func TestMiddlewareLogsStructuredRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	setup(&buf, appconfig.LogConfig{Level: "info", Format: appconfig.LogFormatJSON})
+
+	engine := gin.New()
+	engine.Use(Middleware(), Recovery())
+	engine.GET("/proxy/media", func(c *gin.Context) {
+		identity.Set(c, identity.Identity{Name: "friend-phone"})
+		FromContext(c.Request.Context()).Debug("hidden at info level")
+		c.String(http.StatusOK, "hello")
+	})
+	engine.GET("/panic", func(*gin.Context) { panic("boom") })
+
+	req := httptest.NewRequest(http.MethodGet, "/proxy/media?target=x&access_token=s3cret", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Header().Get(RequestIDHeader) != "req-123" {
+		t.Fatalf("request id should be echoed, got %q", w.Header().Get(RequestIDHeader))
+	}
+	if strings.Contains(buf.String(), "s3cret") || strings.Contains(buf.String(), "hidden") {
+		t.Fatalf("unexpected log output: %s", buf.String())
+	}
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log line is not JSON: %v (%s)", err, buf.String())
+	}
+	want := map[string]any{
+		"msg":        "http request",
+		"request_id": "req-123",
+		"route":      "/proxy/media",
+		"identity":   "friend-phone",
+		"status":     float64(http.StatusOK),
+		"bytes":      float64(5),
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Fatalf("%s: expected %v, got %v", key, value, entry[key])
+		}
+	}
+	if !strings.Contains(entry["path"].(string), "access_token=REDACTED") {
+		t.Fatalf("access_token should be redacted in path: %v", entry["path"])
+	}
+
+	buf.Reset()
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(buf.String(), `"msg":"panic recovered"`) {
+		t.Fatalf("panic should be logged and answered with 500, got %d: %s", w.Code, buf.String())
+	}
+	if w.Header().Get(RequestIDHeader) == "" {
+		t.Fatalf("generated request id missing")
+	}
+}
+
+func TestRedactURLNestedTargets(t *testing.T) {
+	inner := "https://cdn.example.com/v.mp4?" + url.Values{"sign": {"abc"}}.Encode()
+	hop2 := "https://hop2.example.com/proxy/media?" + url.Values{"target": {inner}, "access_token": {"hop2-secret"}}.Encode()
+	u, _ := url.Parse("/proxy/media?" + url.Values{"target": {hop2}, "access_token": {"hop1-secret"}}.Encode())
+	got := RedactURL(u)
+	if strings.Contains(got, "secret") {
+		t.Fatalf("nested tokens must be redacted: %s", got)
+	}
+	if !strings.Contains(got, "cdn.example.com") || !strings.Contains(got, "sign%253Dabc") {
+		t.Fatalf("unrelated parameters should be kept: %s", got)
+	}
+	plain, _ := url.Parse("/proxy/media?target=https%3A%2F%2Fcdn.example.com%2Fv.mp4%3Fa%3D1&b=2")
+	if got := RedactURL(plain); got != "/proxy/media?"+plain.RawQuery {
+		t.Fatalf("query without tokens should be kept verbatim: %s", got)
+	}
+}
@@ -0,0 +1,122 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+func newTestEngine(r *Registrar) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Identity{Name: "alice"})
+		c.Next()
+	})
+	engine.Use(r.Middleware())
+	engine.POST("/sql/delete", func(c *gin.Context) {
+		Record(c, Entry{
+			Table:        "t_historical_records",
+			Where:        "id = @id",
+			Args:         map[string]any{"cond_id": 7},
+			AffectedRows: 1,
+			Outcome:      OutcomeOK,
+		})
+		c.JSON(http.StatusOK, gin.H{"affectedRows": 1})
+	})
+	r.Register(engine)
+	return engine
+}
+
+func TestRecordFallsBackToJSONLAndPaginates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	engine := newTestEngine(&Registrar{Recorder: newRecorder(nil, path)})
+
+	for range 3 {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sql/delete", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("delete: %d", w.Code)
+		}
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read fallback: %v", err)
+	}
+	if lines := strings.Count(string(raw), "\n"); lines != 3 {
+		t.Fatalf("expected 3 jsonl lines, got %d", lines)
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit?pageSize=2&page=2&identity=alice", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin audit: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Entries []Entry `json:"entries"`
+		Total   int64   `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Total != 3 || len(resp.Entries) != 1 {
+		t.Fatalf("unexpected page total=%d entries=%d", resp.Total, len(resp.Entries))
+	}
+	entry := resp.Entries[0]
+	if entry.Identity != "alice" || entry.Endpoint != "/sql/delete" || entry.Table != "t_historical_records" ||
+		entry.AffectedRows != 1 || entry.Outcome != OutcomeOK || entry.ID == "" || entry.ClientIP == "" {
+		t.Fatalf("unexpected entry %+v", entry)
+	}
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/audit?outcome=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid outcome, got %d", w.Code)
+	}
+}
+
+func TestRecordWritesFallbackWhenInsertFails(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	recorder := newRecorder(&store{db: sqlx.NewDb(db, "sqlmock")}, path)
+	recorder.now = func() time.Time { return time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC) }
+
+	mock.ExpectExec(`INSERT INTO t_bridge_audit`).
+		WithArgs(sqlmock.AnyArg(), time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC), "alice", "10.0.0.1",
+			"/sql/update", "t_user", "id = @id", `{"cond_id":1}`, int64(1), OutcomeOK, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO t_bridge_audit`).WillReturnError(errors.New("connection refused"))
+
+	entry := Entry{Identity: "alice", ClientIP: "10.0.0.1", Endpoint: "/sql/update", Table: "t_user",
+		Where: "id = @id", Args: map[string]any{"cond_id": 1}, AffectedRows: 1, Outcome: OutcomeOK}
+	recorder.Record(context.Background(), entry)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("fallback file should not exist after a successful insert")
+	}
+
+	recorder.Record(context.Background(), entry)
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+	entries, total, err := recorder.queryFallback(Filter{Page: 1, PageSize: 10})
+	if err != nil || total != 1 || entries[0].Table != "t_user" {
+		t.Fatalf("fallback entries=%+v total=%d err=%v", entries, total, err)
+	}
+}
@@ -0,0 +1,144 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+// recorderKey 为 gin.Context 中保存 Recorder 的键。
+const recorderKey = "bridge.audit"
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Registrar 负责写操作审计的记录入口与 /admin/audit 查询接口。
+type Registrar struct {
+	Recorder *Recorder
+}
+
+// NewRegistrar 创建审计模块；db 为 nil 时（仅代理模式）记录只写入本地 JSONL 文件。
+func NewRegistrar(db *sqlx.DB, cfg appconfig.Config) (*Registrar, error) {
+	var st *store
+	if db != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		var err error
+		if st, err = newStore(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+	return &Registrar{Recorder: newRecorder(st, cfg.Audit.FallbackFile)}, nil
+}
+
+// Middleware 将 Recorder 放入请求上下文，业务处理器通过 Record 写入审计记录。
+func (r *Registrar) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(recorderKey, r.Recorder)
+		c.Next()
+	}
+}
+
+// Record 为当前请求写入一条审计记录，自动补全调用方身份、客户端 IP 与路由；未启用审计时忽略。
+func Record(c *gin.Context, entry Entry) {
+	value, ok := c.Get(recorderKey)
+	if !ok {
+		return
+	}
+	recorder, ok := value.(*Recorder)
+	if !ok || recorder == nil {
+		return
+	}
+	entry.Identity = identity.Name(c)
+	entry.ClientIP = c.ClientIP()
+	if entry.Endpoint == "" {
+		entry.Endpoint = c.FullPath()
+	}
+	recorder.Record(c.Request.Context(), entry)
+}
+
+// Register 挂载 /admin/audit，支持 identity、endpoint、outcome、from/to（RFC3339）过滤与 page/pageSize 分页。
+func (r *Registrar) Register(engine *gin.Engine) {
+	if engine == nil || r.Recorder == nil {
+		return
+	}
+	engine.GET("/admin/audit", func(c *gin.Context) {
+		filter, err := parseFilter(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		entries, total, err := r.Recorder.Query(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if entries == nil {
+			entries = []Entry{}
+		}
+		c.JSON(http.StatusOK, queryResponse{Entries: entries, Total: total, Page: filter.Page, PageSize: filter.PageSize})
+	})
+}
+
+// queryResponse 为 /admin/audit 的分页响应。
+type queryResponse struct {
+	Entries  []Entry `json:"entries"`
+	Total    int64   `json:"total"`
+	Page     int     `json:"page"`
+	PageSize int     `json:"pageSize"`
+}
+
+func parseFilter(c *gin.Context) (Filter, error) {
+	filter := Filter{
+		Identity: strings.TrimSpace(c.Query("identity")),
+		Endpoint: strings.TrimSpace(c.Query("endpoint")),
+		Outcome:  strings.ToLower(strings.TrimSpace(c.Query("outcome"))),
+		Page:     1,
+		PageSize: defaultPageSize,
+	}
+	if raw := strings.TrimSpace(c.Query("page")); raw != "" {
+		page, err := strconv.Atoi(raw)
+		if err != nil || page < 1 {
+			return Filter{}, errors.New("page must be a positive integer")
+		}
+		filter.Page = page
+	}
+	if raw := strings.TrimSpace(c.Query("pageSize")); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 {
+			return Filter{}, errors.New("pageSize must be a positive integer")
+		}
+		filter.PageSize = min(size, maxPageSize)
+	}
+	switch filter.Outcome {
+	case "", OutcomeOK, OutcomeError, OutcomeDenied:
+	default:
+		return Filter{}, errors.New("outcome must be ok, error or denied")
+	}
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		raw := strings.TrimSpace(c.Query(bound.name))
+		if raw == "" {
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return Filter{}, errors.New(bound.name + " must be RFC3339")
+		}
+		*bound.target = at.UTC()
+	}
+	return filter, nil
+}
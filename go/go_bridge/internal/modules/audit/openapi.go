@@ -0,0 +1,27 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/openapi"
+)
+
+// APIRoutes 描述 /admin/audit 查询接口。
+func (r *Registrar) APIRoutes() []openapi.Route {
+	if r.Recorder == nil {
+		return nil
+	}
+	return []openapi.Route{{
+		Method: http.MethodGet, Path: "/admin/audit", Tag: "admin",
+		Summary: "倒序分页查询写操作审计记录", Response: queryResponse{},
+		Query: []openapi.Parameter{
+			openapi.QueryParam("identity", "string", false, "调用方身份"),
+			openapi.QueryParam("endpoint", "string", false, "接口路由，如 /sql/delete"),
+			openapi.QueryParam("outcome", "string", false, "ok、error 或 denied"),
+			openapi.QueryParam("from", "string", false, "起始时间（RFC3339，含）"),
+			openapi.QueryParam("to", "string", false, "结束时间（RFC3339，不含）"),
+			openapi.QueryParam("page", "integer", false, "页码，从 1 开始"),
+			openapi.QueryParam("pageSize", "integer", false, "每页条数，默认 50，最大 500"),
+		},
+	}}
+}
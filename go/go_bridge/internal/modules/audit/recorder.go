@@ -0,0 +1,168 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 审计结果：ok 为执行成功，error 为执行失败，denied 为被权限或隔离规则拒绝。
+const (
+	OutcomeOK     = "ok"
+	OutcomeError  = "error"
+	OutcomeDenied = "denied"
+)
+
+// Entry 为一条写操作审计记录。
+type Entry struct {
+	ID           string         `json:"id"`
+	Time         time.Time      `json:"time"`
+	Identity     string         `json:"identity"`
+	ClientIP     string         `json:"clientIp"`
+	Endpoint     string         `json:"endpoint"`
+	Table        string         `json:"table"`
+	Where        string         `json:"where,omitempty"`
+	Args         map[string]any `json:"args,omitempty"`
+	AffectedRows int64          `json:"affectedRows"`
+	Outcome      string         `json:"outcome"`
+	Error        string         `json:"error,omitempty"`
+}
+
+// Filter 描述 /admin/audit 的过滤与分页参数，Page 从 1 开始。
+type Filter struct {
+	Identity string
+	Endpoint string
+	Outcome  string
+	From     time.Time
+	To       time.Time
+	Page     int
+	PageSize int
+}
+
+func (f Filter) offset() int {
+	return (f.Page - 1) * f.PageSize
+}
+
+func (f Filter) matches(entry Entry) bool {
+	return (f.Identity == "" || entry.Identity == f.Identity) &&
+		(f.Endpoint == "" || entry.Endpoint == f.Endpoint) &&
+		(f.Outcome == "" || entry.Outcome == f.Outcome) &&
+		(f.From.IsZero() || !entry.Time.Before(f.From)) &&
+		(f.To.IsZero() || entry.Time.Before(f.To))
+}
+
+// Recorder 将审计记录写入数据库；数据库不可用（或仅代理模式）时追加到本地 JSONL 文件，保证记录不丢失。
+type Recorder struct {
+	store        *store
+	fallbackPath string
+	mu           sync.Mutex
+	now          func() time.Time
+}
+
+func newRecorder(st *store, fallbackPath string) *Recorder {
+	return &Recorder{store: st, fallbackPath: fallbackPath, now: time.Now}
+}
+
+// Record 补全 ID 与时间后写入记录。
+func (r *Recorder) Record(ctx context.Context, entry Entry) {
+	if entry.ID == "" {
+		entry.ID = newID()
+	}
+	if entry.Time.IsZero() {
+		entry.Time = r.now().UTC()
+	}
+	if r.store != nil {
+		// 请求可能已被取消，审计写入使用独立的超时。
+		writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		err := r.store.insert(writeCtx, entry)
+		cancel()
+		if err == nil {
+			return
+		}
+		slog.Warn("audit insert failed, writing to fallback file", "file", r.fallbackPath, "error", err)
+	}
+	if err := r.appendFallback(entry); err != nil {
+		slog.Error("audit record lost", "endpoint", entry.Endpoint, "identity", entry.Identity, "error", err)
+	}
+}
+
+// Query 查询审计记录：有数据库时查询审计表，否则读取本地 JSONL 文件。
+func (r *Recorder) Query(ctx context.Context, filter Filter) ([]Entry, int64, error) {
+	if r.store != nil {
+		return r.store.query(ctx, filter)
+	}
+	return r.queryFallback(filter)
+}
+
+func (r *Recorder) appendFallback(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode audit entry: %w", err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(r.fallbackPath), 0o755); err != nil {
+		return fmt.Errorf("create audit dir: %w", err)
+	}
+	f, err := os.OpenFile(r.fallbackPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write audit file: %w", err)
+	}
+	return f.Close()
+}
+
+// queryFallback 读取 JSONL 文件并在内存中过滤分页，适用于仅代理模式下的少量记录。
+func (r *Recorder) queryFallback(filter Filter) ([]Entry, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.Open(r.fallbackPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("open audit file: %w", err)
+	}
+	defer f.Close()
+
+	var matched []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if filter.matches(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("read audit file: %w", err)
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return matched[i].Time.After(matched[j].Time) })
+
+	total := int64(len(matched))
+	start := min(filter.offset(), len(matched))
+	end := min(start+filter.PageSize, len(matched))
+	return matched[start:end], total, nil
+}
+
+func newID() string {
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,212 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/database"
+)
+
+const auditTable = "t_bridge_audit"
+
+// maxErrorLength 与 error_message 列宽一致。
+const maxErrorLength = 1000
+
+// auditDDL 为三种驱动的建表语句，与 db/migrations/init.sql 中的 t_bridge_audit 保持一致。
+var auditDDL = database.TableDDL{
+	"postgres": {
+		`CREATE TABLE IF NOT EXISTS t_bridge_audit (
+			id VARCHAR(32) NOT NULL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL,
+			identity VARCHAR(128) NOT NULL,
+			client_ip VARCHAR(64),
+			endpoint VARCHAR(128) NOT NULL,
+			table_name VARCHAR(128),
+			where_clause TEXT,
+			args TEXT,
+			affected_rows BIGINT NOT NULL DEFAULT 0,
+			outcome VARCHAR(16) NOT NULL,
+			error_message VARCHAR(1000)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_bridge_audit_created ON t_bridge_audit (created_at)`,
+	},
+	"mysql": {
+		`CREATE TABLE IF NOT EXISTS t_bridge_audit (
+			id VARCHAR(32) NOT NULL PRIMARY KEY,
+			created_at DATETIME(3) NOT NULL,
+			identity VARCHAR(128) NOT NULL,
+			client_ip VARCHAR(64),
+			endpoint VARCHAR(128) NOT NULL,
+			table_name VARCHAR(128),
+			where_clause TEXT,
+			args TEXT,
+			affected_rows BIGINT NOT NULL DEFAULT 0,
+			outcome VARCHAR(16) NOT NULL,
+			error_message VARCHAR(1000),
+			INDEX idx_bridge_audit_created (created_at)
+		)`,
+	},
+	"oracle": {
+		`CREATE TABLE t_bridge_audit (
+			id VARCHAR2(32) NOT NULL PRIMARY KEY,
+			created_at TIMESTAMP NOT NULL,
+			identity VARCHAR2(128) NOT NULL,
+			client_ip VARCHAR2(64),
+			endpoint VARCHAR2(128) NOT NULL,
+			table_name VARCHAR2(128),
+			where_clause CLOB,
+			args CLOB,
+			affected_rows NUMBER(19) DEFAULT 0 NOT NULL,
+			outcome VARCHAR2(16) NOT NULL,
+			error_message VARCHAR2(1000)
+		)`,
+		`CREATE INDEX idx_bridge_audit_created ON t_bridge_audit (created_at)`,
+	},
+}
+
+// store 负责 t_bridge_audit 的写入与分页查询。
+type store struct {
+	db *sqlx.DB
+}
+
+func newStore(ctx context.Context, db *sqlx.DB) (*store, error) {
+	if err := Migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	return &store{db: db}, nil
+}
+
+// Migrate 在审计表不存在时按驱动建表，供启动与 `go_bridge migrate` 共用。
+func Migrate(ctx context.Context, db *sqlx.DB) error {
+	return database.EnsureTable(ctx, db, auditTable, auditDDL)
+}
+
+func (s *store) insert(ctx context.Context, entry Entry) error {
+	args, err := json.Marshal(entry.Args)
+	if err != nil {
+		return fmt.Errorf("encode audit args: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, s.db.Rebind(`INSERT INTO t_bridge_audit
+		(id, created_at, identity, client_ip, endpoint, table_name, where_clause, args, affected_rows, outcome, error_message)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		entry.ID, entry.Time, entry.Identity, entry.ClientIP, entry.Endpoint, entry.Table,
+		entry.Where, string(args), entry.AffectedRows, entry.Outcome, truncate(entry.Error, maxErrorLength))
+	if err != nil {
+		return fmt.Errorf("insert audit: %w", err)
+	}
+	return nil
+}
+
+// query 按过滤条件倒序分页查询，返回当前页与总条数。
+func (s *store) query(ctx context.Context, filter Filter) ([]Entry, int64, error) {
+	var conds []string
+	var params []any
+	if filter.Identity != "" {
+		conds = append(conds, "identity = ?")
+		params = append(params, filter.Identity)
+	}
+	if filter.Endpoint != "" {
+		conds = append(conds, "endpoint = ?")
+		params = append(params, filter.Endpoint)
+	}
+	if filter.Outcome != "" {
+		conds = append(conds, "outcome = ?")
+		params = append(params, filter.Outcome)
+	}
+	if !filter.From.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		params = append(params, filter.From)
+	}
+	if !filter.To.IsZero() {
+		conds = append(conds, "created_at < ?")
+		params = append(params, filter.To)
+	}
+	where := ""
+	if len(conds) > 0 {
+		where = " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	var total int64
+	if err := s.db.QueryRowxContext(ctx, s.db.Rebind("SELECT COUNT(*) FROM t_bridge_audit"+where), params...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count audit: %w", err)
+	}
+
+	// MySQL 不支持 OFFSET ... FETCH，Postgres 与 Oracle 12c+ 均支持标准写法。
+	page := " ORDER BY created_at DESC, id DESC OFFSET ? ROWS FETCH NEXT ? ROWS ONLY"
+	pageParams := []any{filter.offset(), filter.PageSize}
+	if s.db.DriverName() == "mysql" {
+		page = " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+		pageParams = []any{filter.PageSize, filter.offset()}
+	}
+	rows, err := s.db.QueryxContext(ctx, s.db.Rebind(`SELECT id, created_at, identity, client_ip, endpoint, table_name,
+		where_clause, args, affected_rows, outcome, error_message FROM t_bridge_audit`+where+page),
+		append(params, pageParams...)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query audit: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var entry Entry
+		var createdAt any
+		var clientIP, table, whereClause, args, errMsg *string
+		if err := rows.Scan(&entry.ID, &createdAt, &entry.Identity, &clientIP, &entry.Endpoint, &table,
+			&whereClause, &args, &entry.AffectedRows, &entry.Outcome, &errMsg); err != nil {
+			return nil, 0, fmt.Errorf("scan audit: %w", err)
+		}
+		entry.ClientIP = deref(clientIP)
+		entry.Table = deref(table)
+		entry.Where = deref(whereClause)
+		entry.Error = deref(errMsg)
+		if raw := deref(args); raw != "" {
+			_ = json.Unmarshal([]byte(raw), &entry.Args)
+		}
+		entry.Time = scanTime(createdAt)
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// scanTime 兼容未开启 parseTime 的 MySQL DSN，此时时间列以文本返回。
+func scanTime(value any) time.Time {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC()
+	case []byte:
+		return parseDBTime(string(v))
+	case string:
+		return parseDBTime(v)
+	}
+	return time.Time{}
+}
+
+func parseDBTime(raw string) time.Time {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t.UTC()
+		}
+	}
+	return time.Time{}
+}
+
+// truncate 按字符截断，避免超出 error_message 列宽。
+func truncate(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit])
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
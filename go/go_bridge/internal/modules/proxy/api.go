@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAPIBodyBytes 限制云盘 API 请求/响应体大小，此类接口只传输 JSON。
+const maxAPIBodyBytes = 8 << 20
+
+// apiHandler 代理云盘 JSON API 调用：保留请求方法与请求体，直连上游时运行签名插件，
+// 并在插件实现 ResponseDecrypter 时解密响应后返回。
+func (r *Registrar) apiHandler(client *http.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target := strings.TrimSpace(c.Query("target"))
+		if target == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target is required"})
+			return
+		}
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target url"})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxAPIBodyBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("read body: %v", err)})
+			return
+		}
+		if len(body) > maxAPIBodyBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body exceeds 8MB limit"})
+			return
+		}
+
+		hops := r.selectHops(c, parsed.String())
+		forwardTarget, hopHeaders, err := buildChainedURL(hops, parsed.String(), "/proxy/api")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req, err := http.NewRequestWithContext(c.Request.Context(), c.Request.Method, forwardTarget, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "proxy request build failed: " + upstreamError(err)})
+			return
+		}
+		for key, values := range hopHeaders {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		for _, key := range []string{
+			"Content-Type",
+			"Accept",
+			"Accept-Language",
+			"User-Agent",
+			"Origin",
+			"Referer",
+			"Authorization",
+			"Cookie",
+			"X-Requested-With",
+			"X-Custom-Signature",
+		} {
+			if value := c.GetHeader(key); value != "" {
+				req.Header.Set(key, value)
+			}
+		}
+		replaceRequestBody(req, body)
+		stripHopByHop(req.Header)
+
+		direct := forwardTarget == parsed.String()
+		forwardRequestID(req, direct)
+		signer, err := r.applySigner(req, direct)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("sign request failed: %v", err)})
+			return
+		}
+
+		ticket, rejection := r.breakers.acquire(breakerKeys(hops, parsed)...)
+		if rejection != nil {
+			rejectOpenCircuit(c, rejection)
+			return
+		}
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			reason := ticket.fail(time.Since(start), err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "proxy request failed: " + reason})
+			return
+		}
+		defer resp.Body.Close()
+		ticket.done(time.Since(start), resp.StatusCode >= http.StatusInternalServerError, resp.Status)
+
+		// 响应体整体读入后再交给解密插件；超过上限时明确报错，而不是把截断的 JSON 当作完整响应返回。
+		raw, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIBodyBytes+1))
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "read upstream response: " + upstreamError(err)})
+			return
+		}
+		if len(raw) > maxAPIBodyBytes {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "upstream response exceeds 8MB limit"})
+			return
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(raw))
+		resp.ContentLength = int64(len(raw))
+		if decrypter, ok := signer.(ResponseDecrypter); ok {
+			if err := decrypter.DecryptResponse(resp); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("decrypt response failed: %v", err)})
+				return
+			}
+		}
+		if signer != nil {
+			c.Header("X-Bridge-Signer", signer.Name())
+		}
+
+		copyResponseHeaders(c.Writer.Header(), resp.Header)
+		c.Writer.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(c.Writer, resp.Body)
+	}
+}
@@ -0,0 +1,470 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// breakerState 表示熔断器所处状态：闭合放行、打开快速失败、半开试探。
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerSettings 控制熔断判定窗口与阈值，零值字段会回落到默认配置。
+type BreakerSettings struct {
+	// Window 为统计错误率与慢请求比例的滑动窗口。
+	Window time.Duration
+	// MinRequests 为窗口内触发判定所需的最少样本数，避免冷启动误判。
+	MinRequests int
+	// ErrorRate 为打开熔断的错误率阈值（0-1）。
+	ErrorRate float64
+	// SlowLatency 为首包耗时超过该值即视为慢请求。
+	SlowLatency time.Duration
+	// SlowRate 为打开熔断的慢请求比例阈值（0-1）。
+	SlowRate float64
+	// OpenTimeout 为打开状态持续多久后进入半开试探。
+	OpenTimeout time.Duration
+	// HalfOpenProbes 为半开状态允许并发放行的试探请求数，全部成功后闭合。
+	HalfOpenProbes int
+}
+
+// DefaultBreakerSettings 返回适合媒体回源的默认熔断阈值。
+func DefaultBreakerSettings() BreakerSettings {
+	return BreakerSettings{
+		Window:         30 * time.Second,
+		MinRequests:    5,
+		ErrorRate:      0.5,
+		SlowLatency:    8 * time.Second,
+		SlowRate:       0.8,
+		OpenTimeout:    20 * time.Second,
+		HalfOpenProbes: 1,
+	}
+}
+
+func (s BreakerSettings) withDefaults() BreakerSettings {
+	def := DefaultBreakerSettings()
+	if s.Window <= 0 {
+		s.Window = def.Window
+	}
+	if s.MinRequests <= 0 {
+		s.MinRequests = def.MinRequests
+	}
+	if s.ErrorRate <= 0 {
+		s.ErrorRate = def.ErrorRate
+	}
+	if s.SlowLatency <= 0 {
+		s.SlowLatency = def.SlowLatency
+	}
+	if s.SlowRate <= 0 {
+		s.SlowRate = def.SlowRate
+	}
+	if s.OpenTimeout <= 0 {
+		s.OpenTimeout = def.OpenTimeout
+	}
+	if s.HalfOpenProbes <= 0 {
+		s.HalfOpenProbes = def.HalfOpenProbes
+	}
+	return s
+}
+
+// breakerOutcome 记录窗口内的一次请求结果。
+type breakerOutcome struct {
+	t      time.Time
+	failed bool
+	slow   bool
+}
+
+// circuitBreaker 针对单个上游（主机或链路 hop）维护状态机。
+type circuitBreaker struct {
+	mu       sync.Mutex
+	key      string
+	settings BreakerSettings
+	now      func() time.Time
+
+	state          breakerState
+	outcomes       []breakerOutcome
+	openedAt       time.Time
+	lastChange     time.Time
+	lastUsed       time.Time
+	lastError      string
+	probesInFlight int
+	probeSuccesses int
+	trips          int64
+	rejected       int64
+}
+
+// BreakerSnapshot 对外暴露单个熔断器的状态，随 /proxy/metrics 一并返回。
+type BreakerSnapshot struct {
+	Key           string    `json:"key"`
+	State         string    `json:"state"`
+	Samples       int       `json:"samples"`
+	ErrorRate     float64   `json:"error_rate"`
+	SlowRate      float64   `json:"slow_rate"`
+	Trips         int64     `json:"trips"`
+	Rejected      int64     `json:"rejected"`
+	LastError     string    `json:"last_error,omitempty"`
+	LastChange    time.Time `json:"last_change"`
+	RetryAfterSec float64   `json:"retry_after_sec,omitempty"`
+}
+
+func newCircuitBreaker(key string, settings BreakerSettings, now func() time.Time) *circuitBreaker {
+	if now == nil {
+		now = time.Now
+	}
+	return &circuitBreaker{
+		key:        key,
+		settings:   settings.withDefaults(),
+		now:        now,
+		lastChange: now(),
+		lastUsed:   now(),
+	}
+}
+
+// allow 判断是否放行请求；拒绝时返回距离下次试探的剩余时间。
+// 返回的 probe 表示本次请求占用了半开试探名额，结束后必须回报结果。
+func (b *circuitBreaker) allow() (ok bool, probe bool, retryAfter time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.lastUsed = now
+	if b.state == breakerOpen {
+		elapsed := now.Sub(b.openedAt)
+		if elapsed < b.settings.OpenTimeout {
+			b.rejected++
+			return false, false, b.settings.OpenTimeout - elapsed
+		}
+		b.transition(breakerHalfOpen, now)
+	}
+	if b.state == breakerHalfOpen {
+		if b.probesInFlight >= b.settings.HalfOpenProbes {
+			b.rejected++
+			return false, false, time.Second
+		}
+		b.probesInFlight++
+		return true, true, 0
+	}
+	return true, false, 0
+}
+
+// cancel 归还未真正发出的半开试探名额。
+func (b *circuitBreaker) cancel(probe bool) {
+	if !probe {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.probesInFlight > 0 {
+		b.probesInFlight--
+	}
+}
+
+// record 回报一次请求结果，latency 为收到响应头的耗时。
+func (b *circuitBreaker) record(probe bool, latency time.Duration, failed bool, errMsg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	slow := latency >= b.settings.SlowLatency
+	if failed && errMsg != "" {
+		b.lastError = errMsg
+	}
+
+	if probe {
+		if b.probesInFlight > 0 {
+			b.probesInFlight--
+		}
+		if b.state != breakerHalfOpen {
+			return
+		}
+		if failed || slow {
+			b.trip(now)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.settings.HalfOpenProbes {
+			b.transition(breakerClosed, now)
+		}
+		return
+	}
+
+	if b.state != breakerClosed {
+		// 打开期间放行前发出的旧请求迟到回报，不再影响状态。
+		return
+	}
+	b.outcomes = append(b.outcomes, breakerOutcome{t: now, failed: failed, slow: slow})
+	b.prune(now)
+
+	total, failures, slows := b.counts()
+	if total < b.settings.MinRequests {
+		return
+	}
+	if float64(failures)/float64(total) >= b.settings.ErrorRate ||
+		float64(slows)/float64(total) >= b.settings.SlowRate {
+		b.trip(now)
+	}
+}
+
+func (b *circuitBreaker) trip(now time.Time) {
+	b.trips++
+	b.openedAt = now
+	b.transition(breakerOpen, now)
+}
+
+func (b *circuitBreaker) transition(state breakerState, now time.Time) {
+	b.state = state
+	b.lastChange = now
+	b.probesInFlight = 0
+	b.probeSuccesses = 0
+	if state != breakerHalfOpen {
+		b.outcomes = b.outcomes[:0]
+	}
+}
+
+func (b *circuitBreaker) prune(now time.Time) {
+	cutoff := now.Add(-b.settings.Window)
+	idx := 0
+	for idx < len(b.outcomes) && b.outcomes[idx].t.Before(cutoff) {
+		idx++
+	}
+	if idx > 0 {
+		b.outcomes = append(b.outcomes[:0], b.outcomes[idx:]...)
+	}
+}
+
+func (b *circuitBreaker) counts() (total, failures, slows int) {
+	for _, o := range b.outcomes {
+		total++
+		if o.failed {
+			failures++
+		}
+		if o.slow {
+			slows++
+		}
+	}
+	return total, failures, slows
+}
+
+func (b *circuitBreaker) snapshot() BreakerSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.prune(now)
+	total, failures, slows := b.counts()
+	snap := BreakerSnapshot{
+		Key:        b.key,
+		State:      b.state.String(),
+		Samples:    total,
+		Trips:      b.trips,
+		Rejected:   b.rejected,
+		LastError:  b.lastError,
+		LastChange: b.lastChange,
+	}
+	if total > 0 {
+		snap.ErrorRate = float64(failures) / float64(total)
+		snap.SlowRate = float64(slows) / float64(total)
+	}
+	if b.state == breakerOpen {
+		if remain := b.settings.OpenTimeout - now.Sub(b.openedAt); remain > 0 {
+			snap.RetryAfterSec = remain.Seconds()
+		}
+	}
+	return snap
+}
+
+// idle 判断熔断器是否已闭合、没有试探中的请求且超过 ttl 未被使用，可以安全回收。
+func (b *circuitBreaker) idle(now time.Time, ttl time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerClosed && b.probesInFlight == 0 && now.Sub(b.lastUsed) >= ttl
+}
+
+const (
+	// breakerIdleTTL 为闭合熔断器闲置多久后回收；目标主机来自调用方，不回收会无限增长。
+	breakerIdleTTL = 10 * time.Minute
+	// breakerSweepInterval 为新建熔断器时顺带清理闲置项的最小间隔。
+	breakerSweepInterval = time.Minute
+	// maxBreakers 为熔断器数量上限，达到上限时回收全部闭合且空闲的熔断器。
+	maxBreakers = 4096
+)
+
+// breakerRegistry 按 key 懒加载熔断器，key 形如 host:cdn.example.com 或 hop:https://hk.example.com。
+// 闭合且长期未使用的熔断器会在新建时被回收，打开或半开的熔断器始终保留。
+type breakerRegistry struct {
+	mu        sync.Mutex
+	settings  BreakerSettings
+	now       func() time.Time
+	items     map[string]*circuitBreaker
+	lastSweep time.Time
+}
+
+func newBreakerRegistry(settings BreakerSettings) *breakerRegistry {
+	return &breakerRegistry{
+		settings: settings.withDefaults(),
+		now:      time.Now,
+		items:    map[string]*circuitBreaker{},
+	}
+}
+
+func (r *breakerRegistry) get(key string) *circuitBreaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, ok := r.items[key]
+	if !ok {
+		now := r.now()
+		if now.Sub(r.lastSweep) >= breakerSweepInterval || len(r.items) >= maxBreakers {
+			r.evictIdle(now, breakerIdleTTL)
+			r.lastSweep = now
+		}
+		if len(r.items) >= maxBreakers {
+			r.evictIdle(now, 0)
+		}
+		b = newCircuitBreaker(key, r.settings, r.now)
+		r.items[key] = b
+	}
+	return b
+}
+
+// evictIdle 删除闲置超过 ttl 的闭合熔断器，调用方需持有 r.mu。
+func (r *breakerRegistry) evictIdle(now time.Time, ttl time.Duration) {
+	for key, b := range r.items {
+		if b.idle(now, ttl) {
+			delete(r.items, key)
+		}
+	}
+}
+
+// breakerTicket 持有一次请求所经过的全部熔断器，请求完成后统一回报结果。
+type breakerTicket struct {
+	breakers []*circuitBreaker
+	probes   []bool
+}
+
+// breakerRejection 描述被打开的熔断器拒绝的原因，用于生成 503 响应。
+type breakerRejection struct {
+	Key        string
+	RetryAfter time.Duration
+	LastError  string
+}
+
+func (e *breakerRejection) Error() string {
+	msg := fmt.Sprintf("upstream circuit open for %s, retry after %.0fs", e.Key, e.RetryAfter.Seconds())
+	if e.LastError != "" {
+		msg += ": " + e.LastError
+	}
+	return msg
+}
+
+// acquire 依次检查每个 key 对应的熔断器，任意一个拒绝即整体快速失败，
+// 并归还此前已占用的半开试探名额。
+func (r *breakerRegistry) acquire(keys ...string) (*breakerTicket, *breakerRejection) {
+	ticket := &breakerTicket{}
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		b := r.get(key)
+		ok, probe, retryAfter := b.allow()
+		if !ok {
+			ticket.cancel()
+			b.mu.Lock()
+			lastErr := b.lastError
+			b.mu.Unlock()
+			return nil, &breakerRejection{Key: key, RetryAfter: retryAfter, LastError: lastErr}
+		}
+		ticket.breakers = append(ticket.breakers, b)
+		ticket.probes = append(ticket.probes, probe)
+	}
+	return ticket, nil
+}
+
+// done 将本次请求的首包耗时与成败回报给所有相关熔断器。
+func (t *breakerTicket) done(latency time.Duration, failed bool, errMsg string) {
+	if t == nil {
+		return
+	}
+	for i, b := range t.breakers {
+		b.record(t.probes[i], latency, failed, errMsg)
+	}
+}
+
+// fail 回报一次没有拿到响应的请求并返回可对外展示的原因：客户端取消时只释放名额，
+// 避免客户端断开被计为上游故障、半开试探被当作成功；其余错误计为失败。
+func (t *breakerTicket) fail(latency time.Duration, err error) string {
+	reason := upstreamError(err)
+	if errors.Is(err, context.Canceled) {
+		t.cancel()
+	} else {
+		t.done(latency, true, reason)
+	}
+	return reason
+}
+
+// cancel 在请求未得到上游结果（如客户端取消）时释放名额，不计入成功或失败，
+// 半开状态的试探名额随之归还，由下一个请求重新试探。
+func (t *breakerTicket) cancel() {
+	if t == nil {
+		return
+	}
+	for i, b := range t.breakers {
+		b.cancel(t.probes[i])
+	}
+}
+
+// snapshot 返回按 key 排序的全部熔断器状态。
+func (r *breakerRegistry) snapshot() []BreakerSnapshot {
+	r.mu.Lock()
+	breakers := make([]*circuitBreaker, 0, len(r.items))
+	for _, b := range r.items {
+		breakers = append(breakers, b)
+	}
+	r.mu.Unlock()
+
+	result := make([]BreakerSnapshot, 0, len(breakers))
+	for _, b := range breakers {
+		result = append(result, b.snapshot())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+// isOpen 判断指定 key 当前是否处于打开状态，不占用试探名额。
+func (r *breakerRegistry) isOpen(key string) bool {
+	r.mu.Lock()
+	b, ok := r.items[key]
+	r.mu.Unlock()
+	if !ok {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == breakerOpen && b.now().Sub(b.openedAt) < b.settings.OpenTimeout
+}
+
+func hostBreakerKey(host string) string {
+	return "host:" + host
+}
+
+func hopBreakerKey(endpoint string) string {
+	return "hop:" + endpoint
+}
@@ -0,0 +1,262 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (f *fakeClock) now() time.Time { return f.t }
+
+func TestCircuitBreakerTripsAndRecovers(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newCircuitBreaker("host:cdn.example.com", BreakerSettings{
+		MinRequests:    3,
+		ErrorRate:      0.5,
+		OpenTimeout:    10 * time.Second,
+		HalfOpenProbes: 1,
+	}, clock.now)
+
+	for i := 0; i < 3; i++ {
+		ok, probe, _ := b.allow()
+		if !ok || probe {
+			t.Fatalf("closed breaker should allow without probe")
+		}
+		b.record(probe, 100*time.Millisecond, true, "502 Bad Gateway")
+	}
+	if ok, _, retry := b.allow(); ok || retry <= 0 {
+		t.Fatalf("expected open breaker to reject, ok=%v retry=%v", ok, retry)
+	}
+
+	clock.t = clock.t.Add(11 * time.Second)
+	ok, probe, _ := b.allow()
+	if !ok || !probe {
+		t.Fatalf("expected half-open probe after timeout")
+	}
+	if ok, _, _ := b.allow(); ok {
+		t.Fatalf("half-open breaker should only allow configured probes")
+	}
+	b.record(probe, 50*time.Millisecond, false, "")
+	if snap := b.snapshot(); snap.State != "closed" || snap.Trips != 1 {
+		t.Fatalf("expected closed breaker after successful probe, got %+v", snap)
+	}
+}
+
+func TestCircuitBreakerTripsOnSlowResponses(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newCircuitBreaker("hop:https://hk.example.com", BreakerSettings{
+		MinRequests: 2,
+		SlowLatency: time.Second,
+		SlowRate:    0.5,
+	}, clock.now)
+	b.record(false, 3*time.Second, false, "")
+	b.record(false, 3*time.Second, false, "")
+	if snap := b.snapshot(); snap.State != "open" {
+		t.Fatalf("expected slow upstream to open breaker, got %+v", snap)
+	}
+}
+
+func TestProxyHandlerFailsFastWhenCircuitOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	r := NewRegistrar(upstream.Client(), nil)
+	r.breakers = newBreakerRegistry(BreakerSettings{MinRequests: 2, ErrorRate: 0.5, OpenTimeout: time.Minute})
+	engine := gin.New()
+	r.Register(engine)
+
+	target := "/proxy/media?target=" + url.QueryEscape(upstream.URL+"/video.mp4")
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusBadGateway {
+			t.Fatalf("expected upstream status passthrough, got %d", w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 from open breaker, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected Retry-After header")
+	}
+	if hits != 2 {
+		t.Fatalf("open breaker should not reach upstream, hits=%d", hits)
+	}
+}
+
+func TestCanceledProbeDoesNotCloseBreaker(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	b := newCircuitBreaker("host:cdn.example.com", BreakerSettings{
+		MinRequests:    1,
+		ErrorRate:      0.5,
+		OpenTimeout:    10 * time.Second,
+		HalfOpenProbes: 1,
+	}, clock.now)
+	b.record(false, 100*time.Millisecond, true, "502 Bad Gateway")
+
+	clock.t = clock.t.Add(11 * time.Second)
+	ok, probe, _ := b.allow()
+	if !ok || !probe {
+		t.Fatalf("expected half-open probe after timeout")
+	}
+	ticket := &breakerTicket{breakers: []*circuitBreaker{b}, probes: []bool{probe}}
+	ticket.cancel()
+	if snap := b.snapshot(); snap.State != "half_open" {
+		t.Fatalf("canceled probe must not close the breaker, got %+v", snap)
+	}
+	if ok, probe, _ := b.allow(); !ok || !probe {
+		t.Fatalf("expected the released probe slot to be reused")
+	}
+}
+
+func TestProxyHandlerFailsOverToAlternateRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("direct"))
+	}))
+	defer upstream.Close()
+	hopHits := 0
+	hop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hopHits++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer hop.Close()
+
+	r := NewRegistrar(upstream.Client(), nil)
+	r.breakers = newBreakerRegistry(BreakerSettings{MinRequests: 1, ErrorRate: 0.5, OpenTimeout: time.Minute})
+	r.UpdateHops([]ChainHop{{Endpoint: hop.URL}}, []Route{{Name: "direct"}}, RouteProbeOptions{Static: true})
+	r.breakers.get(hopBreakerKey(hop.URL)).record(false, time.Millisecond, true, "502 Bad Gateway")
+	engine := gin.New()
+	r.Register(engine)
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/media?target="+url.QueryEscape(upstream.URL+"/video.mp4"), nil))
+	if w.Code != http.StatusOK || w.Body.String() != "direct" {
+		t.Fatalf("expected failover to the direct route, got %d %q", w.Code, w.Body.String())
+	}
+	if hopHits != 0 {
+		t.Fatalf("open first hop should not be contacted, hits=%d", hopHits)
+	}
+}
+
+func TestIfRangeRefetchGoesThroughBreaker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fullFetches := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		if req.Header.Get("Range") != "" {
+			w.Header().Set("Content-Range", "bytes 0-1/4")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write([]byte("ab"))
+			return
+		}
+		fullFetches++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	r := NewRegistrar(upstream.Client(), nil)
+	r.breakers = newBreakerRegistry(BreakerSettings{MinRequests: 2, ErrorRate: 0.5, OpenTimeout: time.Minute})
+	engine := gin.New()
+	r.Register(engine)
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/proxy/media?target="+url.QueryEscape(upstream.URL+"/video.mp4"), nil)
+		req.Header.Set("Range", "bytes=0-1")
+		req.Header.Set("If-Range", `"v1"`)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	if w := send(); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected refetch status passthrough, got %d", w.Code)
+	}
+	host := hostBreakerKey(strings.TrimPrefix(upstream.URL, "http://"))
+	if snap := r.breakers.get(host).snapshot(); snap.State != "open" {
+		t.Fatalf("refetch failure should count toward the breaker, got %+v", snap)
+	}
+	if w := send(); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once the breaker is open, got %d", w.Code)
+	}
+	if fullFetches != 1 {
+		t.Fatalf("open breaker should not reach upstream, full fetches=%d", fullFetches)
+	}
+}
+
+func TestBreakerRegistryEvictsIdleClosedBreakers(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	r := newBreakerRegistry(BreakerSettings{MinRequests: 1, ErrorRate: 0.5, OpenTimeout: time.Hour})
+	r.now = clock.now
+
+	r.get("host:idle.example.com")
+	failing := r.get("host:down.example.com")
+	failing.record(false, time.Millisecond, true, "502 Bad Gateway")
+
+	clock.t = clock.t.Add(breakerIdleTTL + time.Second)
+	r.get("host:new.example.com")
+	keys := map[string]bool{}
+	for _, snap := range r.snapshot() {
+		keys[snap.Key] = true
+	}
+	if keys["host:idle.example.com"] || !keys["host:down.example.com"] || !keys["host:new.example.com"] {
+		t.Fatalf("expected only the idle closed breaker to be evicted, got %v", keys)
+	}
+
+	for i := 0; len(r.items) < maxBreakers; i++ {
+		r.get(fmt.Sprintf("host:%d.example.com", i))
+	}
+	r.get("host:overflow.example.com")
+	if len(r.items) > maxBreakers || r.items["host:down.example.com"] == nil {
+		t.Fatalf("cap must bound the registry and keep open breakers, size=%d", len(r.items))
+	}
+}
+
+func TestAPIAndSubtitleIgnoreClientCancelAndSendRetryAfter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer upstream.Close()
+
+	r := NewRegistrar(upstream.Client(), nil)
+	r.breakers = newBreakerRegistry(BreakerSettings{MinRequests: 1, ErrorRate: 0.5, OpenTimeout: time.Minute})
+	engine := gin.New()
+	r.Register(engine)
+	host := hostBreakerKey(strings.TrimPrefix(upstream.URL, "http://"))
+
+	for _, path := range []string{"/proxy/api", "/proxy/subtitle"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest(http.MethodGet, path+"?target="+url.QueryEscape(upstream.URL+"/a.srt"), nil).WithContext(ctx)
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+		if snap := r.breakers.get(host).snapshot(); snap.Samples != 0 || snap.State != "closed" {
+			t.Fatalf("%s: client cancel must not count as an upstream failure, got %+v", path, snap)
+		}
+	}
+
+	r.breakers.get(host).record(false, time.Millisecond, true, "502 Bad Gateway")
+	for _, path := range []string{"/proxy/api", "/proxy/subtitle"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path+"?target="+url.QueryEscape(upstream.URL+"/a.srt"), nil))
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+			t.Fatalf("%s: expected 503 with Retry-After, got %d %v", path, w.Code, w.Header())
+		}
+	}
+}
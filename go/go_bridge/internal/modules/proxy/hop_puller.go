@@ -2,15 +2,17 @@ package proxy
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
 // hopMetricsPuller 定时向链路上游节点的 /proxy/metrics 拉取指标，用于前端分层展示。
 type hopMetricsPuller struct {
 	metrics   *Metrics
+	mu        sync.RWMutex
 	hops      []ChainHop
 	client    *http.Client
 	interval  time.Duration
@@ -42,10 +44,8 @@ func newHopMetricsPuller(
 	}
 }
 
+// start 启动轮询协程；hop 列表为空时每轮直接跳过，便于配置热更新后再加入节点。
 func (p *hopMetricsPuller) start() {
-	if len(p.hops) == 0 {
-		return
-	}
 	go func() {
 		ticker := time.NewTicker(p.interval)
 		defer ticker.Stop()
@@ -69,14 +69,28 @@ func (p *hopMetricsPuller) stop() {
 	}
 }
 
+// setHops 替换轮询的节点列表，下一轮拉取生效。
+func (p *hopMetricsPuller) setHops(hops []ChainHop) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hops = hops
+}
+
 // pull 拉取每个 hop 的 /proxy/metrics，失败时保留错误信息。
 func (p *hopMetricsPuller) pull() {
+	p.mu.RLock()
+	hops := p.hops
+	p.mu.RUnlock()
 	if p.metrics == nil {
 		return
 	}
-	hopSnapshots := make([]HopSnapshot, 0, len(p.hops))
+	if len(hops) == 0 {
+		p.metrics.AttachHops(nil)
+		return
+	}
+	hopSnapshots := make([]HopSnapshot, 0, len(hops))
 	rootLogged := false // 同一批次仅关注首个疑似瓶颈节点，避免全链路重复告警。
-	for _, hop := range p.hops {
+	for _, hop := range hops {
 		endpoint := strings.TrimRight(hop.Endpoint, "/")
 		if endpoint == "" {
 			continue
@@ -167,15 +181,14 @@ func (p *hopMetricsPuller) maybeWarnSlow(endpoint string, snap HopSnapshot) {
 		return
 	}
 	if p.isSlow(snap) {
-		log.Printf(
-			"proxy hop slow: endpoint=%s throughput=%.1f kbps p90=%.0fms p50=%.0fms success=%.2f status=%d error=%s",
-			endpoint,
-			snap.ThroughputKbps,
-			snap.P90,
-			snap.P50,
-			snap.Success,
-			snap.Status,
-			snap.Error,
+		slog.Warn("proxy hop slow",
+			"endpoint", endpoint,
+			"throughput_kbps", snap.ThroughputKbps,
+			"p90_ms", snap.P90,
+			"p50_ms", snap.P50,
+			"success", snap.Success,
+			"status", snap.Status,
+			"error", snap.Error,
 		)
 		p.lastWarn[endpoint] = time.Now()
 	}
@@ -0,0 +1,80 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// hopTransport 按请求目标的 host:port 选择 hop 专属的 Transport（携带客户端证书与 CA），
+// 其余请求走默认 Transport。配置热更新时可整体替换 hop 列表。
+type hopTransport struct {
+	fallback *http.Transport
+	mu       sync.RWMutex
+	byHost   map[string]*http.Transport
+}
+
+func (t *hopTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	rt, ok := t.byHost[hostPort(req.URL)]
+	t.mu.RUnlock()
+	if ok {
+		return rt.RoundTrip(req)
+	}
+	return t.fallback.RoundTrip(req)
+}
+
+// setHops 按新的 hop 列表重建专属 Transport，被替换的 Transport 在关闭空闲连接后释放，
+// 进行中的请求不受影响。
+func (t *hopTransport) setHops(hops []ChainHop) {
+	byHost := map[string]*http.Transport{}
+	for _, hop := range hops {
+		if hop.TLS == nil {
+			continue
+		}
+		parsed, err := url.Parse(hop.Endpoint)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		transport := t.fallback.Clone()
+		transport.TLSClientConfig = hop.TLS.Clone()
+		byHost[hostPort(parsed)] = transport
+	}
+	t.mu.Lock()
+	previous := t.byHost
+	t.byHost = byHost
+	t.mu.Unlock()
+	for _, transport := range previous {
+		transport.CloseIdleConnections()
+	}
+}
+
+// NewHopClient 构建按 hop 选择 TLS 设置的客户端：配置了 TLS 的 hop 使用各自的客户端证书与 CA，
+// 其余请求与默认客户端共用连接池。返回的客户端传给 NewRegistrar 后，UpdateHops 会同步替换 hop 设置。
+func NewHopClient(hops []ChainHop) *http.Client {
+	base, ok := defaultHTTPClient.Transport.(*http.Transport)
+	if !ok {
+		return nil
+	}
+	transport := &hopTransport{fallback: base}
+	transport.setHops(hops)
+	return &http.Client{
+		Transport: transport,
+		Timeout:   defaultHTTPClient.Timeout,
+	}
+}
+
+// hostPort 返回补全默认端口后的小写 host:port。
+func hostPort(u *url.URL) string {
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if strings.EqualFold(u.Scheme, "https") {
+			port = "443"
+		}
+	}
+	return net.JoinHostPort(host, port)
+}
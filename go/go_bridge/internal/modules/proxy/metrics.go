@@ -52,20 +52,24 @@ type MetricsSnapshot struct {
 	WindowDurationSec      float64       `json:"window_duration_sec"`
 	AvailableSampleSpanSec float64       `json:"available_sample_span_sec"`
 	Hops                   []HopSnapshot `json:"hops"`
+	// Breakers 由代理模块在输出时附加，反映各上游主机与 hop 的熔断状态。
+	Breakers []BreakerSnapshot `json:"breakers,omitempty"`
+	// Routing 在启用自适应选路时附加各并行链路的探测得分。
+	Routing *RoutingSnapshot `json:"routing,omitempty"`
 }
 
 // HopSnapshot 描述单个链路节点的指标，用于前端逐层呈现。
 type HopSnapshot struct {
-	Endpoint string  `json:"endpoint"`
-	Success  float64 `json:"success_rate"`
-	P50      float64 `json:"p50_latency_ms"`
-	P90      float64 `json:"p90_latency_ms"`
-	P99      float64 `json:"p99_latency_ms"`
-	RPM      float64 `json:"requests_per_minute"`
+	Endpoint       string  `json:"endpoint"`
+	Success        float64 `json:"success_rate"`
+	P50            float64 `json:"p50_latency_ms"`
+	P90            float64 `json:"p90_latency_ms"`
+	P99            float64 `json:"p99_latency_ms"`
+	RPM            float64 `json:"requests_per_minute"`
 	ThroughputKbps float64 `json:"avg_throughput_kbps"`
-	Error    string  `json:"last_error,omitempty"`
-	Status   int     `json:"last_status,omitempty"`
-	StaleSec float64 `json:"stale_seconds,omitempty"`
+	Error          string  `json:"last_error,omitempty"`
+	Status         int     `json:"last_status,omitempty"`
+	StaleSec       float64 `json:"stale_seconds,omitempty"`
 }
 
 // NewMetrics 创建定长窗口的内存指标，窗口大小受 bufferSize 控制。
@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"net/http"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/openapi"
+)
+
+// APIRoutes 描述代理接口；媒体与字幕为二进制或文本流，/proxy/api 原样返回上游 JSON。
+func (r *Registrar) APIRoutes() []openapi.Route {
+	target := openapi.QueryParam("target", "string", true, "上游 http/https 地址")
+	media := []openapi.Parameter{target}
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/proxy/media", Tag: "proxy", Summary: "代理媒体流，透传 Range", ContentType: "application/octet-stream", Query: media},
+		{Method: http.MethodHead, Path: "/proxy/media", Tag: "proxy", Summary: "获取媒体响应头", Query: media},
+		{
+			Method: http.MethodGet, Path: "/proxy/subtitle", Tag: "proxy", Summary: "拉取字幕并转为 UTF-8，可选转 WebVTT 与平移时间轴",
+			ContentType: "text/plain",
+			Query: []openapi.Parameter{
+				target,
+				openapi.QueryParam("charset", "string", false, "强制源编码，如 gbk"),
+				openapi.QueryParam("format", "string", false, "srt、ass 或 vtt"),
+				openapi.QueryParam("offset", "string", false, "时间轴偏移，如 1500ms、-2s"),
+			},
+		},
+		{Method: http.MethodGet, Path: "/proxy/api", Tag: "proxy", Summary: "代理云盘 JSON API", Response: &openapi.Schema{}, Query: media},
+		{Method: http.MethodPost, Path: "/proxy/api", Tag: "proxy", Summary: "代理云盘 JSON API，保留请求体", Request: &openapi.Schema{}, Response: &openapi.Schema{}, Query: media},
+		{Method: http.MethodGet, Path: "/proxy/metrics", Tag: "proxy", Summary: "代理质量、熔断与选路指标", Response: MetricsSnapshot{}},
+	}
+}
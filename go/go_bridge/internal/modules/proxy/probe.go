@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// probeMeasure 为一次 Range 探测的测量值：score 为探测字节数除以含首包等待在内的总耗时（KB/s），
+// 同时体现延迟与带宽；throughput 只计传输阶段（KB/s）。
+type probeMeasure struct {
+	status     int
+	ttfb       time.Duration
+	bytes      int64
+	throughput float64
+	score      float64
+}
+
+// probeChain 经 hops 读取 target 的前 probeBytes 字节，自适应选路与 `go_bridge proxy probe` 共用。
+func probeChain(ctx context.Context, client *http.Client, hops []ChainHop, target string, probeBytes int64) (probeMeasure, error) {
+	var m probeMeasure
+	forward, headers, err := buildChainedURL(hops, target, "/proxy/media")
+	if err != nil {
+		return m, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, forward, nil)
+	if err != nil {
+		return m, err
+	}
+	for key, values := range headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", probeBytes-1))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return m, err
+	}
+	defer resp.Body.Close()
+	m.ttfb = time.Since(start)
+	m.status = resp.StatusCode
+	if resp.StatusCode >= http.StatusBadRequest {
+		return m, fmt.Errorf("probe returned %s", resp.Status)
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, probeBytes))
+	m.bytes = n
+	if err != nil {
+		return m, err
+	}
+	if n == 0 {
+		return m, fmt.Errorf("probe returned empty body")
+	}
+	total := time.Since(start)
+	transfer := total - m.ttfb
+	if transfer <= 0 {
+		transfer = time.Microsecond
+	}
+	kb := float64(n) / 1024.0
+	m.throughput = kb / transfer.Seconds()
+	m.score = kb / total.Seconds()
+	return m, nil
+}
+
+// ProbeResult 为经单条链路探测目标地址的结果，字段含义与 /proxy/metrics 中的选路得分一致。
+type ProbeResult struct {
+	Route          string   `json:"route"`
+	Hops           []string `json:"hops"`
+	Status         int      `json:"status"`
+	TTFBMs         float64  `json:"ttfb_ms"`
+	Bytes          int64    `json:"bytes"`
+	ThroughputKbps float64  `json:"throughput_kbps"`
+	Score          float64  `json:"score"`
+	Error          string   `json:"error,omitempty"`
+}
+
+// Probe 经当前配置的链路读取 target 的前 probeBytes 字节：启用自适应选路时逐条探测全部并行链路，
+// 否则只探测静态 Chain（为空时直连）。不需要先调用 Register；timeout 为单条链路的超时，零值使用选路探测的默认值。
+func (r *Registrar) Probe(ctx context.Context, target string, probeBytes int64, timeout time.Duration) []ProbeResult {
+	defaults := RouteProbeOptions{ProbeBytes: probeBytes, Timeout: timeout}.withDefaults()
+	probeBytes, timeout = defaults.ProbeBytes, defaults.Timeout
+	client := r.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	var routes []Route
+	r.mu.RLock()
+	if r.selector != nil {
+		for _, state := range r.selector.routes {
+			routes = append(routes, state.route)
+		}
+	} else {
+		routes = []Route{{Name: "default", Hops: r.Chain}}
+	}
+	r.mu.RUnlock()
+
+	results := make([]ProbeResult, 0, len(routes))
+	for _, route := range routes {
+		result := ProbeResult{Route: route.Name, Hops: []string{}}
+		for _, hop := range route.Hops {
+			result.Hops = append(result.Hops, hop.Endpoint)
+		}
+		probeCtx, cancel := context.WithTimeout(ctx, timeout)
+		m, err := probeChain(probeCtx, client, route.Hops, target, probeBytes)
+		cancel()
+		result.Status = m.status
+		result.TTFBMs = float64(m.ttfb) / float64(time.Millisecond)
+		result.Bytes = m.bytes
+		result.ThroughputKbps = m.throughput
+		result.Score = m.score
+		if err != nil {
+			result.Error = upstreamError(err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
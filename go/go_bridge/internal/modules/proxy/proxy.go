@@ -2,17 +2,23 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"math"
+	"mime/multipart"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/logging"
 )
 
 // 默认代理客户端与缓冲池，跨端重用时可避免重复创建带来的性能损耗。
@@ -38,16 +44,34 @@ var (
 type Registrar struct {
 	Client *http.Client
 	Chain  []ChainHop
+	// Signers 按上游主机匹配云盘签名插件，仅在直连最终上游时生效。
+	Signers *SignerRegistry
 	// metrics 用于对代理质量进行持续埋点并对外暴露。
 	metrics *Metrics
 	// hopPuller 周期拉取上游节点的 metrics，便于前端逐 hop 展示。
 	hopPuller *hopMetricsPuller
+	// breakers 按上游主机与链路 hop 维护熔断状态，故障时快速失败。
+	breakers *breakerRegistry
+	// subtitles 短时缓存已转码的字幕原文，避免播放器重复拉取。
+	subtitles *subtitleCache
+	// streams 记录正在转发的媒体流，供 /admin/status 与管理界面展示。
+	streams *streamRegistry
+	// selector 在启用自适应选路时按探测得分为新会话挑选并行链路。
+	selector *routeSelector
+	// alternates 为静态模式下的备用链路，Chain 首跳熔断时按顺序尝试。
+	alternates []Route
+	// mu 保护 Chain、selector 与 alternates，配置热更新时整体替换。
+	mu sync.RWMutex
+	// started 表示 Register 已启动后台轮询，之后替换的 selector 需立即启动。
+	started bool
 }
 
 // ChainHop 描述一次代理下一跳的目标地址与访问令牌。
 type ChainHop struct {
 	Endpoint  string
 	AuthToken string
+	// TLS 为连接该 hop 时使用的客户端证书与 CA，仅对首跳生效（后续 hop 由各节点自行连接）。
+	TLS *tls.Config
 }
 
 // NewRegistrar 创建代理模块，允许调用侧注入自定义 HTTP 客户端（例如桌面端代理链）。
@@ -59,6 +83,7 @@ func NewRegistrar(client *http.Client, chain []ChainHop) *Registrar {
 	return &Registrar{
 		Client:  client,
 		Chain:   chain,
+		Signers: DefaultSigners(),
 		metrics: metrics,
 		hopPuller: newHopMetricsPuller(
 			metrics,
@@ -66,9 +91,85 @@ func NewRegistrar(client *http.Client, chain []ChainHop) *Registrar {
 			client,
 			time.Second*15,
 		),
+		breakers:  newBreakerRegistry(DefaultBreakerSettings()),
+		subtitles: newSubtitleCache(subtitleCacheTTL, subtitleCacheMax),
+		streams:   newStreamRegistry(),
 	}
 }
 
+// EnableAdaptiveRouting 启用延迟探测选路：周期性经每条链路探测 opts.CanaryURL，
+// 新的播放会话走得分最高的链路。需在 Register 之前调用；链路少于两条时保持静态 Chain。
+func (r *Registrar) EnableAdaptiveRouting(routes []Route, opts RouteProbeOptions) {
+	r.UpdateHops(r.Chain, routes, opts)
+}
+
+// UpdateHops 在配置热更新时原子替换静态链路、并行链路与上游指标轮询的节点列表，
+// 并同步 NewHopClient 构建的客户端中的 hop TLS 设置。进行中的播放流继续使用原链路；
+// 启用自适应选路时会以新链路重建选路器，会话绑定随之重置；opts.Static 时 routes 仅作为备用链路。
+func (r *Registrar) UpdateHops(chain []ChainHop, routes []Route, opts RouteProbeOptions) {
+	client := r.Client
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	all := append([]ChainHop{}, chain...)
+	for _, route := range routes {
+		all = append(all, route.Hops...)
+	}
+	if transport, ok := client.Transport.(*hopTransport); ok {
+		transport.setHops(all)
+	}
+
+	var selector *routeSelector
+	var alternates []Route
+	switch {
+	case opts.Static:
+		alternates = routes
+	case len(routes) == 1:
+		chain = routes[0].Hops
+	case len(routes) >= 2:
+		selector = newRouteSelector(r, client, routes, opts)
+	}
+
+	// 指标轮询覆盖所有链路中出现过的节点。
+	seen := map[string]bool{}
+	var hops []ChainHop
+	for _, hop := range all {
+		if hop.Endpoint == "" || seen[hop.Endpoint] {
+			continue
+		}
+		seen[hop.Endpoint] = true
+		hops = append(hops, hop)
+	}
+	r.hopPuller.setHops(hops)
+
+	r.mu.Lock()
+	previous := r.selector
+	r.Chain = chain
+	r.selector = selector
+	r.alternates = alternates
+	if selector != nil && r.started {
+		selector.start()
+	}
+	r.mu.Unlock()
+	if previous != nil {
+		previous.stop()
+	}
+}
+
+// Close 停止上游指标轮询与链路探测，供优雅退出时在连接排空后调用。
+func (r *Registrar) Close(context.Context) error {
+	if r.hopPuller != nil {
+		r.hopPuller.stop()
+	}
+	r.mu.RLock()
+	selector := r.selector
+	r.mu.RUnlock()
+	if selector != nil {
+		selector.stop()
+	}
+	return nil
+}
+
 // Register 绑定 /proxy/media，透传 Range/User-Agent 等头保证播放器兼容。
 func (r *Registrar) Register(engine *gin.Engine) {
 	if engine == nil {
@@ -78,29 +179,48 @@ func (r *Registrar) Register(engine *gin.Engine) {
 	if client == nil {
 		client = defaultHTTPClient
 	}
-
-	// OPTIONS 便于跨端播放器执行预检请求。
-	engine.OPTIONS("/proxy/media", func(c *gin.Context) {
-		setCORSHeaders(c)
-		c.Status(http.StatusNoContent)
-	})
+	if r.breakers == nil {
+		r.breakers = newBreakerRegistry(DefaultBreakerSettings())
+	}
+	if r.subtitles == nil {
+		r.subtitles = newSubtitleCache(subtitleCacheTTL, subtitleCacheMax)
+	}
+	if r.streams == nil {
+		r.streams = newStreamRegistry()
+	}
 
 	// 暴露代理质量监控数据，供 Flutter 端展示。
 	engine.GET("/proxy/metrics", func(c *gin.Context) {
-		c.JSON(http.StatusOK, r.metrics.Snapshot())
+		snap := r.metrics.Snapshot()
+		snap.Breakers = r.breakers.snapshot()
+		r.mu.RLock()
+		selector := r.selector
+		r.mu.RUnlock()
+		if selector != nil {
+			snap.Routing = selector.snapshot()
+		}
+		c.JSON(http.StatusOK, snap)
 	})
 
-	// 启动上游指标轮询。
+	// 启动上游指标轮询与链路探测。
 	r.hopPuller.start()
+	r.mu.Lock()
+	r.started = true
+	if r.selector != nil {
+		r.selector.start()
+	}
+	r.mu.Unlock()
 
 	engine.HEAD("/proxy/media", r.proxyHandler(client, http.MethodHead, false))
 	engine.GET("/proxy/media", r.proxyHandler(client, http.MethodGet, true))
+	engine.GET("/proxy/subtitle", r.subtitleHandler(client))
+	engine.GET("/proxy/api", r.apiHandler(client))
+	engine.POST("/proxy/api", r.apiHandler(client))
 }
 
 // proxyHandler 按方法代理媒体流，可复用 GET/HEAD。
 func (r *Registrar) proxyHandler(client *http.Client, method string, withBody bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		setCORSHeaders(c)
 		target := c.Query("target")
 		if strings.TrimSpace(target) == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "target is required"})
@@ -113,62 +233,96 @@ func (r *Registrar) proxyHandler(client *http.Client, method string, withBody bo
 			return
 		}
 
-		forwardTarget, hopHeaders, err := r.buildChainedTarget(parsed.String())
+		hops := r.selectHops(c, parsed.String())
+		req, status, err := r.newMediaRequest(c, method, hops, parsed)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.JSON(status, gin.H{"error": err.Error()})
 			return
 		}
 
-		req, err := http.NewRequestWithContext(
-			c.Request.Context(),
-			method,
-			forwardTarget,
-			nil,
-		)
+		// 上游主机或首跳处于熔断打开状态时直接返回 503，避免等待响应头超时；
+		// 静态模式下首跳熔断时先依次尝试备用链路。
+		ticket, rejection := r.breakers.acquire(breakerKeys(hops, parsed)...)
+		for _, alternate := range r.failoverRoutes(hops, rejection) {
+			altReq, _, err := r.newMediaRequest(c, method, alternate.Hops, parsed)
+			if err != nil {
+				continue
+			}
+			altTicket, altRejection := r.breakers.acquire(breakerKeys(alternate.Hops, parsed)...)
+			if altRejection != nil {
+				continue
+			}
+			hops, req, ticket, rejection = alternate.Hops, altReq, altTicket, nil
+			break
+		}
+		if rejection != nil {
+			r.metrics.Record(0, 0, false, http.StatusServiceUnavailable, rejection.Error())
+			rejectOpenCircuit(c, rejection)
+			return
+		}
+
+		start := time.Now()
+		resp, err := client.Do(req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("proxy request build failed: %v", err)})
+			reason := ticket.fail(time.Since(start), err)
+			r.metrics.Record(time.Since(start), 0, false, http.StatusBadGateway, reason)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "proxy request failed: " + reason})
 			return
 		}
-		for key, values := range hopHeaders {
-			for _, value := range values {
-				req.Header.Add(key, value)
-			}
+		defer resp.Body.Close()
+		ticket.done(time.Since(start), resp.StatusCode >= http.StatusInternalServerError, resp.Status)
+
+		// 带响应体的请求在转发期间登记为进行中的播放流，已转发字节实时可见。
+		var streamed *atomic.Int64
+		if withBody {
+			stream := r.streams.open(c, parsed, resp.StatusCode)
+			defer r.streams.close(stream)
+			streamed = &stream.bytes
 		}
 
-		forwardHeaders := []string{
-			"Range",
-			"User-Agent",
-			"Accept",
-			"Accept-Language",
-			"Accept-Encoding",
-			"Origin",
-			"Referer",
-			"Authorization",
-			"Cookie",
-			"If-None-Match",
-			"If-Modified-Since",
-			"Cache-Control",
-			"Pragma",
-			"X-Requested-With",
-			"X-Custom-Signature",
-			"X-Forwarded-For",
-			"X-Forwarded-Proto",
+		rangeHeader := c.GetHeader("Range")
+		ifRange := c.GetHeader("If-Range")
+		succeeded := resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent
+
+		// 条件请求在本地评估：上游即使忽略 If-None-Match/If-Modified-Since 也能返回 304。
+		if succeeded && notModified(c.Request.Header, resp.Header) {
+			writeNotModified(c.Writer.Header(), resp.Header)
+			c.Writer.WriteHeader(http.StatusNotModified)
+			r.metrics.Record(time.Since(start), 0, true, http.StatusNotModified, "")
+			return
 		}
-		for _, key := range forwardHeaders {
-			if value := c.GetHeader(key); value != "" {
-				req.Header.Set(key, value)
+
+		// If-Range 指向旧版本时必须忽略 Range，重新拉取完整内容。
+		if rangeHeader != "" && resp.StatusCode == http.StatusPartialContent && !ifRangeSatisfied(ifRange, resp.Header) {
+			_ = resp.Body.Close()
+			fullReq := req.Clone(c.Request.Context())
+			fullReq.Header.Del("Range")
+			// 重新拉取同样经过熔断器：打开时快速失败，结果计入熔断统计。
+			ticket, rejection := r.breakers.acquire(breakerKeys(hops, parsed)...)
+			if rejection != nil {
+				r.metrics.Record(time.Since(start), 0, false, http.StatusServiceUnavailable, rejection.Error())
+				rejectOpenCircuit(c, rejection)
+				return
 			}
+			refetchStart := time.Now()
+			resp, err = client.Do(fullReq)
+			if err != nil {
+				reason := ticket.fail(time.Since(refetchStart), err)
+				r.metrics.Record(time.Since(start), 0, false, http.StatusBadGateway, reason)
+				c.JSON(http.StatusBadGateway, gin.H{"error": "proxy request failed: " + reason})
+				return
+			}
+			defer resp.Body.Close()
+			ticket.done(time.Since(refetchStart), resp.StatusCode >= http.StatusInternalServerError, resp.Status)
+			rangeHeader = ""
 		}
-		stripHopByHop(req.Header)
 
-		start := time.Now()
-		resp, err := client.Do(req)
-		if err != nil {
-			r.metrics.Record(time.Since(start), 0, false, http.StatusBadGateway, err.Error())
-			c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("proxy request failed: %v", err)})
+		// 许多 CDN 对多区间请求直接返回 200 全量内容，此时由代理自行裁剪为 206。
+		if rangeHeader != "" && resp.StatusCode == http.StatusOK && canSynthesizeRanges(resp) &&
+			ifRangeSatisfied(ifRange, resp.Header) {
+			r.serveRanges(c, resp, rangeHeader, withBody, start, streamed)
 			return
 		}
-		defer resp.Body.Close()
 
 		copyResponseHeaders(c.Writer.Header(), resp.Header)
 		c.Writer.WriteHeader(resp.StatusCode)
@@ -178,49 +332,255 @@ func (r *Registrar) proxyHandler(client *http.Client, method string, withBody bo
 			return
 		}
 
-		buf := bufferPool.Get().([]byte)
-		defer bufferPool.Put(buf)
+		byteCount := pipeBody(c, resp.Body, streamed, func(dst io.Writer, buf []byte) error {
+			_, err := io.CopyBuffer(dst, resp.Body, buf)
+			return err
+		})
+
+		success := resp.StatusCode < http.StatusBadRequest
+		r.metrics.Record(time.Since(start), byteCount, success, resp.StatusCode, "")
+	}
+}
 
-		// MultiWriter 记录响应体大小，便于估算吞吐。
+// rejectOpenCircuit 以 503 与 Retry-After 回复被熔断器拒绝的请求，各代理接口共用同一格式。
+func rejectOpenCircuit(c *gin.Context, rejection *breakerRejection) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(rejection.RetryAfter.Seconds()))))
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"error":         rejection.Error(),
+		"upstream":      rejection.Key,
+		"retryAfterSec": math.Ceil(rejection.RetryAfter.Seconds()),
+	})
+}
+
+// newMediaRequest 按 hops 包装目标地址并构建转发请求，透传播放器相关请求头；
+// 失败时返回应回复给客户端的状态码。
+func (r *Registrar) newMediaRequest(c *gin.Context, method string, hops []ChainHop, target *url.URL) (*http.Request, int, error) {
+	forwardTarget, hopHeaders, err := buildChainedURL(hops, target.String(), "/proxy/media")
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+
+	req, err := http.NewRequestWithContext(
+		c.Request.Context(),
+		method,
+		forwardTarget,
+		nil,
+	)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("proxy request build failed: " + upstreamError(err))
+	}
+	for key, values := range hopHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	forwardHeaders := []string{
+		"Range",
+		"User-Agent",
+		"Accept",
+		"Accept-Language",
+		"Accept-Encoding",
+		"Origin",
+		"Referer",
+		"Authorization",
+		"Cookie",
+		"If-None-Match",
+		"If-Modified-Since",
+		"Cache-Control",
+		"Pragma",
+		"X-Requested-With",
+		"X-Custom-Signature",
+		"X-Forwarded-For",
+		"X-Forwarded-Proto",
+	}
+	for _, key := range forwardHeaders {
+		if value := c.GetHeader(key); value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	stripHopByHop(req.Header)
+	forwardRequestID(req, forwardTarget == target.String())
+	if _, err := r.applySigner(req, forwardTarget == target.String()); err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("sign request failed: %v", err)
+	}
+	return req, 0, nil
+}
+
+// forwardRequestID 经 hop 转发时携带本次请求 ID，下一跳沿用后两端日志可按 request_id 关联；
+// 直连最终上游时不发送。
+func forwardRequestID(req *http.Request, direct bool) {
+	if direct {
+		return
+	}
+	if id := logging.RequestID(req.Context()); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+}
+
+// failoverRoutes 在静态模式下因首跳熔断被拒绝时返回可替换的备用链路，
+// 与被拒绝首跳相同的链路会被跳过；上游主机本身熔断时换链路无济于事，返回空。
+func (r *Registrar) failoverRoutes(hops []ChainHop, rejection *breakerRejection) []Route {
+	if rejection == nil {
+		return nil
+	}
+	keys := breakerKeys(hops, nil)
+	if len(keys) == 0 || keys[0] != rejection.Key {
+		return nil
+	}
+	r.mu.RLock()
+	alternates := r.alternates
+	r.mu.RUnlock()
+	var result []Route
+	for _, route := range alternates {
+		if altKeys := breakerKeys(route.Hops, nil); len(altKeys) > 0 && altKeys[0] == rejection.Key {
+			continue
+		}
+		result = append(result, route)
+	}
+	return result
+}
+
+// serveRanges 基于上游返回的完整响应体合成 206/416 响应，多区间时输出 multipart/byteranges。
+func (r *Registrar) serveRanges(c *gin.Context, resp *http.Response, rangeHeader string, withBody bool, start time.Time, streamed *atomic.Int64) {
+	size := resp.ContentLength
+	ranges, err := parseRange(rangeHeader, size)
+	if errors.Is(err, errRangeUnsatisfiable) {
+		c.Writer.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		c.Writer.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		r.metrics.Record(time.Since(start), 0, false, http.StatusRequestedRangeNotSatisfiable, err.Error())
+		return
+	}
+
+	header := c.Writer.Header()
+	copyResponseHeaders(header, resp.Header)
+	if err != nil {
+		// 语法非法的 Range 按规范忽略，原样返回完整内容。
+		c.Writer.WriteHeader(resp.StatusCode)
 		var byteCount int64
-		counter := &writeCounter{target: c.Writer, countPtr: &byteCount}
-
-		// 若下游关闭连接，尽快中断上游读取，避免占用 goroutine。
-		stopCh := make(chan struct{})
-		go func() {
-			select {
-			case <-c.Request.Context().Done():
-				_ = resp.Body.Close()
-			case <-stopCh:
-			}
-		}()
+		if withBody {
+			byteCount = pipeBody(c, resp.Body, streamed, func(dst io.Writer, buf []byte) error {
+				_, err := io.CopyBuffer(dst, resp.Body, buf)
+				return err
+			})
+		}
+		r.metrics.Record(time.Since(start), byteCount, true, resp.StatusCode, "")
+		return
+	}
 
-		if _, err := io.CopyBuffer(counter, resp.Body, buf); err != nil {
-			if !errors.Is(err, context.Canceled) && !errors.Is(err, io.EOF) {
-				log.Printf("proxy stream interrupted: %v", err)
-			}
+	ranges = coalesceRanges(ranges)
+	contentType := resp.Header.Get("Content-Type")
+	boundary := ""
+	header.Set("Accept-Ranges", "bytes")
+	if len(ranges) == 1 {
+		header.Set("Content-Range", ranges[0].contentRange(size))
+		header.Set("Content-Length", strconv.FormatInt(ranges[0].length, 10))
+	} else {
+		boundary = multipart.NewWriter(io.Discard).Boundary()
+		header.Set("Content-Type", "multipart/byteranges; boundary="+boundary)
+		header.Set("Content-Length", strconv.FormatInt(multipartRangesSize(ranges, size, contentType, boundary), 10))
+	}
+	c.Writer.WriteHeader(http.StatusPartialContent)
+	if !withBody {
+		r.metrics.Record(time.Since(start), 0, true, http.StatusPartialContent, "")
+		return
+	}
+
+	byteCount := pipeBody(c, resp.Body, streamed, func(dst io.Writer, buf []byte) error {
+		return writeRangesFromBody(dst, resp.Body, ranges, size, contentType, boundary, buf)
+	})
+	r.metrics.Record(time.Since(start), byteCount, true, http.StatusPartialContent, "")
+}
+
+// canSynthesizeRanges 仅在已知长度且未压缩的完整响应上本地裁剪区间。
+func canSynthesizeRanges(resp *http.Response) bool {
+	if resp.ContentLength < 0 {
+		return false
+	}
+	encoding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	return encoding == "" || strings.EqualFold(encoding, "identity")
+}
+
+// pipeBody 使用共享缓冲区把上游响应写给客户端并统计字节数，count 非空时同时累加到该计数；
+// 若下游提前断开，会主动关闭上游响应体以释放连接。
+func pipeBody(c *gin.Context, body io.Closer, count *atomic.Int64, write func(dst io.Writer, buf []byte) error) int64 {
+	buf := bufferPool.Get().([]byte)
+	defer bufferPool.Put(buf)
+
+	// MultiWriter 记录响应体大小，便于估算吞吐。
+	var byteCount int64
+	counter := &writeCounter{target: c.Writer, countPtr: &byteCount, shared: count}
+
+	// 若下游关闭连接，尽快中断上游读取，避免占用 goroutine。
+	ctx := c.Request.Context()
+	stopCh := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = body.Close()
+		case <-stopCh:
 		}
-		close(stopCh)
+	}()
 
-		success := resp.StatusCode < http.StatusBadRequest
-		r.metrics.Record(time.Since(start), byteCount, success, resp.StatusCode, "")
+	if err := write(counter, buf); err != nil {
+		if !errors.Is(err, context.Canceled) && !errors.Is(err, io.EOF) {
+			logging.FromContext(ctx).Warn("proxy stream interrupted", "error", err)
+		}
+	}
+	close(stopCh)
+	return byteCount
+}
+
+// breakerKeys 返回一次代理请求需要经过的熔断器：所选链路的首跳 hop 与最终上游主机。
+func breakerKeys(hops []ChainHop, target *url.URL) []string {
+	keys := make([]string, 0, 2)
+	for _, hop := range hops {
+		endpoint := strings.TrimRight(strings.TrimSpace(hop.Endpoint), "/")
+		if endpoint == "" {
+			continue
+		}
+		keys = append(keys, hopBreakerKey(endpoint))
+		break
+	}
+	if target != nil && target.Host != "" {
+		keys = append(keys, hostBreakerKey(strings.ToLower(target.Host)))
+	}
+	return keys
+}
+
+// selectHops 返回本次请求使用的链路：启用自适应选路时按会话挑选，否则使用静态 Chain。
+func (r *Registrar) selectHops(c *gin.Context, target string) []ChainHop {
+	r.mu.RLock()
+	chain, selector := r.Chain, r.selector
+	r.mu.RUnlock()
+	if selector == nil {
+		return chain
 	}
+	return selector.pick(routeSessionKey(c.ClientIP(), target)).Hops
 }
 
 func (r *Registrar) buildChainedTarget(original string) (string, http.Header, error) {
-	if len(r.Chain) == 0 {
+	r.mu.RLock()
+	chain := r.Chain
+	r.mu.RUnlock()
+	return buildChainedURL(chain, original, "/proxy/media")
+}
+
+// buildChainedURL 将原始地址按 hops 逐跳包装为下一跳的 route 接口地址。
+func buildChainedURL(hops []ChainHop, original, route string) (string, http.Header, error) {
+	if len(hops) == 0 {
 		return original, http.Header{}, nil
 	}
 	current := original
 	headers := http.Header{}
-	for i := len(r.Chain) - 1; i >= 0; i-- {
-		hop := r.Chain[i]
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := hops[i]
 		endpoint := strings.TrimSpace(hop.Endpoint)
 		if endpoint == "" {
 			continue
 		}
 		endpoint = strings.TrimRight(endpoint, "/")
-		proxyURL, err := url.Parse(endpoint + "/proxy/media")
+		proxyURL, err := url.Parse(endpoint + route)
 		if err != nil {
 			return original, nil, fmt.Errorf("invalid chain endpoint: %s", hop.Endpoint)
 		}
@@ -239,17 +599,18 @@ func (r *Registrar) buildChainedTarget(original string) (string, http.Header, er
 	return current, headers, nil
 }
 
-// setCORSHeaders 允许跨端播放器发起预检与跨域访问。
-func setCORSHeaders(c *gin.Context) {
-	origin := c.GetHeader("Origin")
-	if origin == "" {
-		origin = "*"
-	}
-	headers := c.Writer.Header()
-	headers.Set("Access-Control-Allow-Origin", origin)
-	headers.Set("Vary", "Origin")
-	headers.Set("Access-Control-Allow-Headers", "*")
-	headers.Set("Access-Control-Allow-Methods", "GET,HEAD,OPTIONS")
+// upstreamError 返回可回显给调用方、写入指标与熔断状态的错误描述。*url.Error 携带完整请求地址，
+// 经代理链包装后其中含各 hop 的 access_token，因此只保留操作、主机与底层原因。
+func upstreamError(err error) string {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err.Error()
+	}
+	host := ""
+	if u, parseErr := url.Parse(urlErr.URL); parseErr == nil {
+		host = u.Host
+	}
+	return fmt.Sprintf("%s %s: %v", urlErr.Op, host, urlErr.Err)
 }
 
 // stripHopByHop 清理 hop-by-hop 头部，避免代理链出现连接升级问题。
@@ -280,6 +641,10 @@ func copyResponseHeaders(dst, src http.Header) {
 			"te", "trailer", "transfer-encoding", "upgrade":
 			continue
 		}
+		// 跨域头由路由层的 CORS 策略统一输出，避免与上游返回的值重复。
+		if strings.HasPrefix(upper, "access-control-") {
+			continue
+		}
 		for _, v := range values {
 			dst.Add(key, v)
 		}
@@ -290,6 +655,8 @@ func copyResponseHeaders(dst, src http.Header) {
 type writeCounter struct {
 	target   io.Writer
 	countPtr *int64
+	// shared 供其他 goroutine 并发读取的计数，如进行中播放流的已转发字节。
+	shared *atomic.Int64
 }
 
 func (w *writeCounter) Write(p []byte) (int, error) {
@@ -297,5 +664,8 @@ func (w *writeCounter) Write(p []byte) (int, error) {
 	if w.countPtr != nil {
 		*w.countPtr += int64(n)
 	}
+	if w.shared != nil {
+		w.shared.Add(int64(n))
+	}
 	return n, err
 }
@@ -1,6 +1,20 @@
 package proxy
 
-import "testing"
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/logging"
+)
 
 func TestBuildChainedTarget(t *testing.T) {
 	r := &Registrar{
@@ -39,3 +53,144 @@ func TestBuildChainedTargetEmptyChain(t *testing.T) {
 		t.Fatalf("expected empty headers")
 	}
 }
+
+func TestUpdateHopsSwapsChainAndPullerHops(t *testing.T) {
+	r := NewRegistrar(NewHopClient(nil), []ChainHop{{Endpoint: "https://hk.example.com", AuthToken: "abc"}})
+	defer r.Close(context.Background())
+
+	r.UpdateHops([]ChainHop{{Endpoint: "https://jp.example.com", AuthToken: "xyz"}}, nil, RouteProbeOptions{})
+	target, headers, err := r.buildChainedTarget("https://cdn.example.com/video.mp4")
+	if err != nil {
+		t.Fatalf("buildChainedTarget returned error: %v", err)
+	}
+	if headers.Get("Authorization") != "Bearer xyz" || target[:len("https://jp.example.com/")] != "https://jp.example.com/" {
+		t.Fatalf("expected reloaded hop, got %s", target)
+	}
+	if hops := r.hopPuller.hops; len(hops) != 1 || hops[0].Endpoint != "https://jp.example.com" {
+		t.Fatalf("puller hops not updated: %+v", hops)
+	}
+
+	r.UpdateHops(nil, nil, RouteProbeOptions{})
+	if target, _, _ := r.buildChainedTarget("https://cdn.example.com/a"); target != "https://cdn.example.com/a" {
+		t.Fatalf("expected direct target after removing chain, got %s", target)
+	}
+}
+
+func TestActiveStreamsReported(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	// 首块超过服务端写缓冲，确保响应头与部分数据在上游阻塞前已到达客户端。
+	chunk := make([]byte, 64<<10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Length", strconv.Itoa(2*len(chunk)))
+		_, _ = w.Write(chunk)
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = w.Write(chunk)
+	}))
+	defer upstream.Close()
+
+	r := NewRegistrar(upstream.Client(), nil)
+	defer r.Close(context.Background())
+	engine := gin.New()
+	r.Register(engine)
+	bridge := httptest.NewServer(engine)
+	defer bridge.Close()
+
+	resp, err := http.Get(bridge.URL + "/proxy/media?target=" + url.QueryEscape(upstream.URL+"/movie.mp4?sign=secret"))
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	defer resp.Body.Close()
+	if _, err := io.ReadFull(resp.Body, make([]byte, 1024)); err != nil {
+		t.Fatalf("read first chunk: %v", err)
+	}
+
+	status := r.Status(context.Background()).(proxyStatus)
+	if len(status.ActiveStreams) != 1 {
+		t.Fatalf("expected one active stream, got %+v", status.ActiveStreams)
+	}
+	stream := status.ActiveStreams[0]
+	if stream.Path != "/movie.mp4" || stream.Status != http.StatusOK || stream.Bytes == 0 {
+		t.Fatalf("unexpected stream: %+v", stream)
+	}
+
+	close(release)
+	_, _ = io.Copy(io.Discard, resp.Body)
+	deadline := time.Now().Add(2 * time.Second)
+	for len(r.Status(context.Background()).(proxyStatus).ActiveStreams) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("stream should be removed after the response completes")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestUpstreamErrorsDoNotLeakHopTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// 监听后立即关闭，得到一个拒绝连接的 hop 地址。
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	r := NewRegistrar(closed.Client(), []ChainHop{
+		{Endpoint: closed.URL, AuthToken: "hop1-secret"},
+		{Endpoint: "https://hop2.example.com", AuthToken: "hop2-secret"},
+	})
+	r.breakers = newBreakerRegistry(BreakerSettings{MinRequests: 1, ErrorRate: 0.5, OpenTimeout: time.Minute})
+	defer r.Close(context.Background())
+	engine := gin.New()
+	r.Register(engine)
+
+	target := "/proxy/media?target=" + url.QueryEscape("https://cdn.example.com/video.mp4")
+	var bodies []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		bodies = append(bodies, w.Body.String())
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/metrics", nil))
+	bodies = append(bodies, w.Body.String())
+
+	if !strings.Contains(bodies[0], "proxy request failed") || !strings.Contains(bodies[1], "circuit open") {
+		t.Fatalf("unexpected responses: %q", bodies)
+	}
+	for _, body := range bodies {
+		if strings.Contains(body, "secret") {
+			t.Fatalf("hop token leaked: %s", body)
+		}
+	}
+}
+
+func TestHopRequestsCarryRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	received := make(chan string, 1)
+	hop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get(logging.RequestIDHeader)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer hop.Close()
+
+	for _, tc := range []struct {
+		name  string
+		chain []ChainHop
+	}{
+		{"via hop", []ChainHop{{Endpoint: hop.URL}}},
+		{"direct", nil},
+	} {
+		engine := gin.New()
+		engine.Use(logging.Middleware())
+		NewRegistrar(hop.Client(), tc.chain).Register(engine)
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/media?target="+url.QueryEscape(hop.URL+"/video.mp4"), nil))
+		got, id := <-received, w.Header().Get(logging.RequestIDHeader)
+		if tc.chain != nil && (id == "" || got != id) {
+			t.Fatalf("%s: hop saw request id %q, want %q", tc.name, got, id)
+		}
+		if tc.chain == nil && got != "" {
+			t.Fatalf("%s: final upstream should not receive the request id, got %q", tc.name, got)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errRangeUnsatisfiable 表示所有区间都落在资源长度之外，需要返回 416。
+var errRangeUnsatisfiable = errors.New("range not satisfiable")
+
+// httpRange 描述一个已按资源长度裁剪过的字节区间。
+type httpRange struct {
+	start  int64
+	length int64
+}
+
+func (r httpRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange 解析 `bytes=0-99,200-` 形式的 Range 头，并按 size 裁剪区间；
+// 语法错误返回普通 error，全部区间不可满足时返回 errRangeUnsatisfiable。
+func parseRange(header string, size int64) ([]httpRange, error) {
+	const prefix = "bytes="
+	header = strings.TrimSpace(header)
+	if !strings.HasPrefix(header, prefix) {
+		return nil, errors.New("invalid range unit")
+	}
+	var (
+		ranges    []httpRange
+		noOverlap bool
+	)
+	for _, spec := range strings.Split(header[len(prefix):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid range: %s", spec)
+		}
+		startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+		var r httpRange
+		if startStr == "" {
+			// 后缀区间 `-500` 表示最后 500 字节。
+			n, err := strconv.ParseInt(endStr, 10, 64)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid range: %s", spec)
+			}
+			if n == 0 || size == 0 {
+				noOverlap = true
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			r.start = size - n
+			r.length = n
+		} else {
+			start, err := strconv.ParseInt(startStr, 10, 64)
+			if err != nil || start < 0 {
+				return nil, fmt.Errorf("invalid range: %s", spec)
+			}
+			if start >= size {
+				noOverlap = true
+				continue
+			}
+			r.start = start
+			if endStr == "" {
+				r.length = size - start
+			} else {
+				end, err := strconv.ParseInt(endStr, 10, 64)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("invalid range: %s", spec)
+				}
+				if end >= size {
+					end = size - 1
+				}
+				r.length = end - start + 1
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	if len(ranges) == 0 {
+		if noOverlap {
+			return nil, errRangeUnsatisfiable
+		}
+		return nil, errors.New("invalid range: empty set")
+	}
+	return ranges, nil
+}
+
+// coalesceRanges 按起点排序并合并重叠或相邻的区间，使其可以顺序读取一次响应体完成输出。
+func coalesceRanges(ranges []httpRange) []httpRange {
+	if len(ranges) <= 1 {
+		return ranges
+	}
+	sorted := append([]httpRange(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	merged := []httpRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		lastEnd := last.start + last.length
+		if r.start <= lastEnd {
+			if end := r.start + r.length; end > lastEnd {
+				last.length = end - last.start
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// multipartRangesSize 预先计算 multipart/byteranges 响应体的总长度，
+// 便于写出 Content-Length，播放器可据此判断进度。
+func multipartRangesSize(ranges []httpRange, size int64, contentType, boundary string) int64 {
+	var counter countingWriter
+	mw := multipart.NewWriter(&counter)
+	_ = mw.SetBoundary(boundary)
+	var total int64
+	for _, r := range ranges {
+		_, _ = mw.CreatePart(rangePartHeader(r, size, contentType))
+		total += r.length
+	}
+	_ = mw.Close()
+	return total + int64(counter)
+}
+
+func rangePartHeader(r httpRange, size int64, contentType string) textproto.MIMEHeader {
+	header := textproto.MIMEHeader{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	header.Set("Content-Range", r.contentRange(size))
+	return header
+}
+
+// writeRangesFromBody 从完整响应体中顺序截取已合并排序的区间；单区间直接写出，
+// 多区间包装为 multipart/byteranges，跳过的字节会被丢弃。
+func writeRangesFromBody(
+	dst io.Writer,
+	body io.Reader,
+	ranges []httpRange,
+	size int64,
+	contentType string,
+	boundary string,
+	buf []byte,
+) error {
+	var pos int64
+	skipTo := func(offset int64) error {
+		if offset <= pos {
+			return nil
+		}
+		_, err := io.CopyBuffer(io.Discard, io.LimitReader(body, offset-pos), buf)
+		pos = offset
+		return err
+	}
+
+	if len(ranges) == 1 {
+		if err := skipTo(ranges[0].start); err != nil {
+			return err
+		}
+		_, err := io.CopyBuffer(dst, io.LimitReader(body, ranges[0].length), buf)
+		return err
+	}
+
+	mw := multipart.NewWriter(dst)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return err
+	}
+	for _, r := range ranges {
+		if err := skipTo(r.start); err != nil {
+			return err
+		}
+		part, err := mw.CreatePart(rangePartHeader(r, size, contentType))
+		if err != nil {
+			return err
+		}
+		n, err := io.CopyBuffer(part, io.LimitReader(body, r.length), buf)
+		pos += n
+		if err != nil {
+			return err
+		}
+		if n < r.length {
+			return io.ErrUnexpectedEOF
+		}
+	}
+	return mw.Close()
+}
+
+// etagMatches 按 RFC 9110 比较 If-None-Match / If-Range 中的实体标签列表；
+// strong=true 时弱标签永不匹配。
+func etagMatches(header, etag string, strong bool) bool {
+	etag = strings.TrimSpace(etag)
+	if etag == "" {
+		return false
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "" {
+			continue
+		}
+		if candidate == "*" {
+			return true
+		}
+		if strong {
+			if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+				return true
+			}
+			continue
+		}
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified 依据客户端条件头与上游校验器判断能否直接返回 304；
+// If-None-Match 存在时优先生效，此时忽略 If-Modified-Since。
+func notModified(reqHeader, respHeader http.Header) bool {
+	if inm := reqHeader.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, respHeader.Get("ETag"), false)
+	}
+	ims := reqHeader.Get("If-Modified-Since")
+	if ims == "" {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(respHeader.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// ifRangeSatisfied 判断 If-Range 是否仍指向同一版本资源：实体标签需强匹配，
+// 日期需与 Last-Modified 完全一致，不满足时 Range 应被忽略并返回完整内容。
+func ifRangeSatisfied(ifRange string, respHeader http.Header) bool {
+	ifRange = strings.TrimSpace(ifRange)
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etagMatches(ifRange, respHeader.Get("ETag"), true)
+	}
+	want, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(respHeader.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return modified.Equal(want)
+}
+
+// writeNotModified 仅回写 304 允许携带的缓存相关头部。
+func writeNotModified(dst, src http.Header) {
+	for _, key := range []string{"Cache-Control", "Content-Location", "Date", "ETag", "Expires", "Last-Modified", "Vary"} {
+		if value := src.Get(key); value != "" {
+			dst.Set(key, value)
+		}
+	}
+}
+
+// countingWriter 仅统计写入长度，用于计算 multipart 包装开销。
+type countingWriter int64
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	*w += countingWriter(len(p))
+	return len(p), nil
+}
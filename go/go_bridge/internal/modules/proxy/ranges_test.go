@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseRange(t *testing.T) {
+	ranges, err := parseRange("bytes=0-9, 20-, -5", 100)
+	if err != nil {
+		t.Fatalf("parseRange: %v", err)
+	}
+	want := []httpRange{{0, 10}, {20, 80}, {95, 5}}
+	if len(ranges) != len(want) {
+		t.Fatalf("unexpected ranges: %+v", ranges)
+	}
+	for i := range want {
+		if ranges[i] != want[i] {
+			t.Fatalf("range %d: want %+v got %+v", i, want[i], ranges[i])
+		}
+	}
+
+	if _, err := parseRange("bytes=200-300", 100); err != errRangeUnsatisfiable {
+		t.Fatalf("expected unsatisfiable range, got %v", err)
+	}
+	if _, err := parseRange("items=0-1", 100); err == nil || err == errRangeUnsatisfiable {
+		t.Fatalf("expected syntax error for unknown unit, got %v", err)
+	}
+}
+
+func TestCoalesceRanges(t *testing.T) {
+	merged := coalesceRanges([]httpRange{{50, 10}, {0, 10}, {5, 10}, {60, 5}})
+	want := []httpRange{{0, 15}, {50, 15}}
+	if len(merged) != len(want) || merged[0] != want[0] || merged[1] != want[1] {
+		t.Fatalf("unexpected merged ranges: %+v", merged)
+	}
+}
+
+// newFullBodyUpstream 模拟忽略 Range 的 CDN：总是返回 200 与完整内容。
+func newFullBodyUpstream(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		_, _ = io.WriteString(w, body)
+	}))
+}
+
+func serveProxy(t *testing.T, upstreamURL string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewRegistrar(nil, nil).Register(engine)
+	req := httptest.NewRequest(http.MethodGet, "/proxy/media?target="+url.QueryEscape(upstreamURL), nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
+func TestProxySynthesizesMultipartByteranges(t *testing.T) {
+	body := "0123456789abcdefghijklmnopqrstuvwxyz"
+	upstream := newFullBodyUpstream(body)
+	defer upstream.Close()
+
+	w := serveProxy(t, upstream.URL, map[string]string{"Range": "bytes=30-35,0-3"})
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", w.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("unexpected content type %q: %v", w.Header().Get("Content-Type"), err)
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(w.Body.Len()) {
+		t.Fatalf("content length %s does not match body %d", got, w.Body.Len())
+	}
+
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	wantParts := []struct{ contentRange, data string }{
+		{"bytes 0-3/36", "0123"},
+		{"bytes 30-35/36", "uvwxyz"},
+	}
+	for _, want := range wantParts {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		if got := part.Header.Get("Content-Range"); got != want.contentRange {
+			t.Fatalf("want Content-Range %s got %s", want.contentRange, got)
+		}
+		data, _ := io.ReadAll(part)
+		if string(data) != want.data {
+			t.Fatalf("want part %q got %q", want.data, data)
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Fatalf("expected exactly two parts, got %v", err)
+	}
+}
+
+func TestProxySynthesizesSingleRangeAnd416(t *testing.T) {
+	body := "0123456789"
+	upstream := newFullBodyUpstream(body)
+	defer upstream.Close()
+
+	w := serveProxy(t, upstream.URL, map[string]string{"Range": "bytes=-3"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "789" {
+		t.Fatalf("expected 206 with suffix bytes, got %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 7-9/10" {
+		t.Fatalf("unexpected Content-Range %s", got)
+	}
+
+	w = serveProxy(t, upstream.URL, map[string]string{"Range": "bytes=50-60"})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes */10" {
+		t.Fatalf("unexpected Content-Range %s", got)
+	}
+}
+
+func TestProxyEvaluatesConditionalHeaders(t *testing.T) {
+	body := "0123456789"
+	upstream := newFullBodyUpstream(body)
+	defer upstream.Close()
+
+	w := serveProxy(t, upstream.URL, map[string]string{"If-None-Match": `W/"v1"`})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("expected 304 for matching etag, got %d", w.Code)
+	}
+
+	w = serveProxy(t, upstream.URL, map[string]string{"If-Modified-Since": "Tue, 03 Jan 2006 00:00:00 GMT"})
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for unmodified resource, got %d", w.Code)
+	}
+
+	w = serveProxy(t, upstream.URL, map[string]string{"Range": "bytes=0-1", "If-Range": `"v0"`})
+	if w.Code != http.StatusOK || w.Body.String() != body {
+		t.Fatalf("stale If-Range should return full body, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = serveProxy(t, upstream.URL, map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "01" {
+		t.Fatalf("matching If-Range should return range, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestProxyRefetchesFullBodyWhenIfRangeStale(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		if strings.HasPrefix(r.Header.Get("Range"), "bytes=") {
+			w.Header().Set("Content-Range", "bytes 0-1/4")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = io.WriteString(w, "ab")
+			return
+		}
+		_, _ = io.WriteString(w, "abcd")
+	}))
+	defer upstream.Close()
+
+	w := serveProxy(t, upstream.URL, map[string]string{"Range": "bytes=0-1", "If-Range": `"v1"`})
+	if w.Code != http.StatusOK || w.Body.String() != "abcd" {
+		t.Fatalf("expected full refetch, got %d %q", w.Code, w.Body.String())
+	}
+}
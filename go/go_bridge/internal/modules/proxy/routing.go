@@ -0,0 +1,359 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Route 描述一条可供选路的并行链路；Hops 为空表示直连上游。
+type Route struct {
+	Name string
+	Hops []ChainHop
+}
+
+// RouteProbeOptions 控制自适应选路的探测节奏与切换阈值，零值字段使用默认值。
+type RouteProbeOptions struct {
+	// CanaryURL 为探测使用的金丝雀资源，建议指向与播放源同一 CDN 的小文件或大文件头部。
+	CanaryURL string
+	// Interval 为两轮探测的间隔。
+	Interval time.Duration
+	// ProbeBytes 为每次探测通过 Range 读取的字节数。
+	ProbeBytes int64
+	// Timeout 为单次探测的超时时间。
+	Timeout time.Duration
+	// Hysteresis 为切换所需的相对优势（0.2 表示新链路得分需高出 20%），用于抑制抖动。
+	Hysteresis float64
+	// SessionTTL 为同一播放会话固定在已选链路上的时长。
+	SessionTTL time.Duration
+	// Static 表示静态模式：routes 不参与探测选路，仅在 Chain 的首跳熔断时依次作为备用链路。
+	Static bool
+}
+
+func (o RouteProbeOptions) withDefaults() RouteProbeOptions {
+	if o.Interval <= 0 {
+		o.Interval = 60 * time.Second
+	}
+	if o.ProbeBytes <= 0 {
+		o.ProbeBytes = 256 << 10
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.Hysteresis <= 0 {
+		o.Hysteresis = 0.2
+	}
+	if o.SessionTTL <= 0 {
+		o.SessionTTL = 10 * time.Minute
+	}
+	return o
+}
+
+const (
+	// routeScoreAlpha 为探测得分的指数平滑系数，越大越灵敏。
+	routeScoreAlpha = 0.3
+	// routeMaxFailures 为连续失败多少次后判定链路不可用。
+	routeMaxFailures = 3
+)
+
+// routeState 保存单条链路的平滑后探测结果。
+type routeState struct {
+	route          Route
+	ttfbMs         float64
+	throughputKbps float64
+	score          float64
+	probes         int64
+	failures       int64
+	consecutive    int
+	lastProbe      time.Time
+	lastError      string
+}
+
+func (s *routeState) healthy() bool {
+	return s.probes > 0 && s.consecutive < routeMaxFailures
+}
+
+// routeSession 记录一次播放会话绑定的链路，避免同一视频的分段请求在链路间跳动。
+type routeSession struct {
+	route    string
+	lastSeen time.Time
+}
+
+// RouteSnapshot 对外暴露单条链路的探测得分，随 /proxy/metrics 返回。
+type RouteSnapshot struct {
+	Name           string    `json:"name"`
+	Hops           []string  `json:"hops"`
+	Active         bool      `json:"active"`
+	Healthy        bool      `json:"healthy"`
+	Score          float64   `json:"score"`
+	TTFBMs         float64   `json:"ttfb_ms"`
+	ThroughputKbps float64   `json:"throughput_kbps"`
+	Probes         int64     `json:"probes"`
+	Failures       int64     `json:"failures"`
+	LastProbe      time.Time `json:"last_probe,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+}
+
+// RoutingSnapshot 汇总自适应选路状态。
+type RoutingSnapshot struct {
+	Mode        string          `json:"mode"`
+	Active      string          `json:"active"`
+	CanaryURL   string          `json:"canary_url,omitempty"`
+	Sessions    int             `json:"sessions"`
+	LastSwitch  time.Time       `json:"last_switch,omitempty"`
+	Switches    int64           `json:"switches"`
+	Routes      []RouteSnapshot `json:"routes"`
+	IntervalSec float64         `json:"interval_sec"`
+}
+
+// routeSelector 周期性经每条链路向金丝雀地址发送小 Range 探测，按首包耗时与吞吐打分，
+// 新会话路由到得分最高且未熔断的链路；当前链路仅在被明显超越或失效时才切换。
+type routeSelector struct {
+	mu     sync.Mutex
+	owner  *Registrar
+	client *http.Client
+	opts   RouteProbeOptions
+	routes []*routeState
+	active int
+	// initialized 表示已完成首轮探测并据此选定过链路。
+	initialized bool
+	lastSwitch  time.Time
+	switches    int64
+	sessions    map[string]routeSession
+	stopCh      chan struct{}
+	stopOnce    sync.Once
+}
+
+func newRouteSelector(owner *Registrar, client *http.Client, routes []Route, opts RouteProbeOptions) *routeSelector {
+	states := make([]*routeState, 0, len(routes))
+	for i, route := range routes {
+		if strings.TrimSpace(route.Name) == "" {
+			route.Name = fmt.Sprintf("route-%d", i+1)
+		}
+		states = append(states, &routeState{route: route})
+	}
+	return &routeSelector{
+		owner:    owner,
+		client:   client,
+		opts:     opts.withDefaults(),
+		routes:   states,
+		sessions: map[string]routeSession{},
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (s *routeSelector) start() {
+	if len(s.routes) < 2 || s.opts.CanaryURL == "" {
+		return
+	}
+	go func() {
+		s.probeAll()
+		ticker := time.NewTicker(s.opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.probeAll()
+				s.pruneSessions()
+			case <-s.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+func (s *routeSelector) stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+// probeAll 并发探测全部链路，完成后重新评估当前链路。
+func (s *routeSelector) probeAll() {
+	var wg sync.WaitGroup
+	for i := range s.routes {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			ttfb, throughput, score, err := s.probe(s.routes[idx].route)
+			s.record(idx, ttfb, throughput, score, err)
+		}(i)
+	}
+	wg.Wait()
+	s.reselect()
+}
+
+// probe 经指定链路读取金丝雀资源的前 ProbeBytes 字节，返回首包耗时、传输阶段吞吐（KB/s）与得分。
+func (s *routeSelector) probe(route Route) (time.Duration, float64, float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.Timeout)
+	defer cancel()
+	m, err := probeChain(ctx, s.client, route.Hops, s.opts.CanaryURL, s.opts.ProbeBytes)
+	return m.ttfb, m.throughput, m.score, err
+}
+
+// record 以指数平滑方式更新链路的首包耗时、吞吐与得分。
+func (s *routeSelector) record(idx int, ttfb time.Duration, throughput, score float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := s.routes[idx]
+	state.probes++
+	state.lastProbe = time.Now()
+	if err != nil {
+		state.failures++
+		state.consecutive++
+		state.lastError = upstreamError(err)
+		state.score *= 0.5
+		return
+	}
+	state.consecutive = 0
+	state.lastError = ""
+	ttfbMs := float64(ttfb.Milliseconds())
+	if state.probes == 1 || state.score == 0 {
+		state.ttfbMs, state.throughputKbps, state.score = ttfbMs, throughput, score
+		return
+	}
+	state.ttfbMs = routeScoreAlpha*ttfbMs + (1-routeScoreAlpha)*state.ttfbMs
+	state.throughputKbps = routeScoreAlpha*throughput + (1-routeScoreAlpha)*state.throughputKbps
+	state.score = routeScoreAlpha*score + (1-routeScoreAlpha)*state.score
+}
+
+// usable 判断链路是否可承接新会话：未连续失败且首跳未处于熔断打开状态。
+func (s *routeSelector) usable(state *routeState) bool {
+	if state.probes > 0 && !state.healthy() {
+		return false
+	}
+	if len(state.route.Hops) > 0 && s.owner != nil && s.owner.breakers != nil {
+		endpoint := strings.TrimRight(strings.TrimSpace(state.route.Hops[0].Endpoint), "/")
+		if s.owner.breakers.isOpen(hopBreakerKey(endpoint)) {
+			return false
+		}
+	}
+	return true
+}
+
+// reselect 应用滞回规则：仅当当前链路失效，或最佳链路得分超出 (1+Hysteresis) 倍时才切换。
+func (s *routeSelector) reselect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	best := -1
+	for i, state := range s.routes {
+		if !s.usable(state) {
+			continue
+		}
+		if best < 0 || state.score > s.routes[best].score {
+			best = i
+		}
+	}
+	if best < 0 {
+		return
+	}
+	if !s.initialized {
+		// 首轮探测完成后直接采用最佳链路，不计入切换。
+		s.active = best
+		s.initialized = true
+		return
+	}
+	if best == s.active {
+		return
+	}
+	current := s.routes[s.active]
+	if s.usable(current) && s.routes[best].score <= current.score*(1+s.opts.Hysteresis) {
+		return
+	}
+	slog.Info("proxy route switch",
+		"from", current.route.Name,
+		"from_score", current.score,
+		"to", s.routes[best].route.Name,
+		"to_score", s.routes[best].score,
+	)
+	s.active = best
+	s.lastSwitch = time.Now()
+	s.switches++
+}
+
+// pick 为会话返回链路：已绑定且仍可用的会话沿用原链路，否则绑定当前最佳链路。
+func (s *routeSelector) pick(sessionKey string) Route {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if sess, ok := s.sessions[sessionKey]; ok && now.Sub(sess.lastSeen) < s.opts.SessionTTL {
+		for _, state := range s.routes {
+			if state.route.Name == sess.route && s.usable(state) {
+				s.sessions[sessionKey] = routeSession{route: sess.route, lastSeen: now}
+				return state.route
+			}
+		}
+	}
+
+	chosen := s.routes[s.active]
+	if !s.usable(chosen) {
+		// 当前链路熔断或失效时按得分降序挑选备用链路。
+		ordered := append([]*routeState(nil), s.routes...)
+		sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].score > ordered[j].score })
+		for _, state := range ordered {
+			if s.usable(state) {
+				chosen = state
+				break
+			}
+		}
+	}
+	s.sessions[sessionKey] = routeSession{route: chosen.route.Name, lastSeen: now}
+	return chosen.route
+}
+
+func (s *routeSelector) pruneSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for key, sess := range s.sessions {
+		if now.Sub(sess.lastSeen) >= s.opts.SessionTTL {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+func (s *routeSelector) snapshot() *RoutingSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := &RoutingSnapshot{
+		Mode:        "adaptive",
+		CanaryURL:   s.opts.CanaryURL,
+		Sessions:    len(s.sessions),
+		LastSwitch:  s.lastSwitch,
+		Switches:    s.switches,
+		IntervalSec: s.opts.Interval.Seconds(),
+		Routes:      make([]RouteSnapshot, 0, len(s.routes)),
+	}
+	for i, state := range s.routes {
+		hops := make([]string, 0, len(state.route.Hops))
+		for _, hop := range state.route.Hops {
+			hops = append(hops, hop.Endpoint)
+		}
+		if i == s.active {
+			snap.Active = state.route.Name
+		}
+		snap.Routes = append(snap.Routes, RouteSnapshot{
+			Name:           state.route.Name,
+			Hops:           hops,
+			Active:         i == s.active,
+			Healthy:        s.usable(state),
+			Score:          state.score,
+			TTFBMs:         state.ttfbMs,
+			ThroughputKbps: state.throughputKbps,
+			Probes:         state.probes,
+			Failures:       state.failures,
+			LastProbe:      state.lastProbe,
+			LastError:      state.lastError,
+		})
+	}
+	return snap
+}
+
+// routeSessionKey 以客户端 IP 与目标地址标识一次播放会话。
+func routeSessionKey(clientIP, target string) string {
+	return clientIP + "|" + target
+}
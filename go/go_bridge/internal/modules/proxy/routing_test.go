@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRouteSelectorHysteresis(t *testing.T) {
+	s := newRouteSelector(nil, http.DefaultClient, []Route{
+		{Name: "tokyo", Hops: []ChainHop{{Endpoint: "https://tokyo.example.com"}}},
+		{Name: "hk", Hops: []ChainHop{{Endpoint: "https://hk.example.com"}}},
+	}, RouteProbeOptions{Hysteresis: 0.2})
+
+	s.record(0, 100*time.Millisecond, 1000, 1000, nil)
+	s.record(1, 100*time.Millisecond, 900, 900, nil)
+	s.reselect()
+	if s.snapshot().Active != "tokyo" {
+		t.Fatalf("first round should pick the best route")
+	}
+
+	s.record(1, 100*time.Millisecond, 1500, 1500, nil)
+	s.reselect()
+	if snap := s.snapshot(); snap.Active != "tokyo" || snap.Switches != 0 {
+		t.Fatalf("small advantage should not trigger a switch, got %+v", snap)
+	}
+
+	s.record(1, 100*time.Millisecond, 4000, 4000, nil)
+	s.reselect()
+	if snap := s.snapshot(); snap.Active != "hk" || snap.Switches != 1 {
+		t.Fatalf("expected switch to hk, got %+v", snap)
+	}
+}
+
+func TestRouteSelectorFailsOverUnhealthyRoute(t *testing.T) {
+	s := newRouteSelector(nil, http.DefaultClient, []Route{
+		{Name: "tokyo", Hops: []ChainHop{{Endpoint: "https://tokyo.example.com"}}},
+		{Name: "direct"},
+	}, RouteProbeOptions{})
+	s.record(0, 50*time.Millisecond, 5000, 5000, nil)
+	s.record(1, 500*time.Millisecond, 200, 200, nil)
+	s.reselect()
+
+	if route := s.pick("1.2.3.4|https://cdn/a.mp4"); route.Name != "tokyo" {
+		t.Fatalf("expected best route tokyo, got %s", route.Name)
+	}
+	for i := 0; i < routeMaxFailures; i++ {
+		s.record(0, 0, 0, 0, errors.New("timeout"))
+	}
+	s.reselect()
+	if route := s.pick("1.2.3.4|https://cdn/a.mp4"); route.Name != "direct" {
+		t.Fatalf("sticky session should fail over when route is unhealthy, got %s", route.Name)
+	}
+}
+
+func TestRouteSelectorProbesThroughChains(t *testing.T) {
+	payload := strings.Repeat("x", 64<<10)
+	canary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer canary.Close()
+
+	var slowHits int
+	slowHop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slowHits++
+		if r.URL.Path != "/proxy/media" || r.URL.Query().Get("target") != canary.URL {
+			http.Error(w, "bad probe", http.StatusBadRequest)
+			return
+		}
+		time.Sleep(150 * time.Millisecond)
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer slowHop.Close()
+
+	s := newRouteSelector(nil, http.DefaultClient, []Route{
+		{Name: "via-slow", Hops: []ChainHop{{Endpoint: slowHop.URL}}},
+		{Name: "direct"},
+	}, RouteProbeOptions{CanaryURL: canary.URL, ProbeBytes: 32 << 10})
+	s.probeAll()
+
+	snap := s.snapshot()
+	if slowHits != 1 {
+		t.Fatalf("expected one probe through hop, got %d", slowHits)
+	}
+	if snap.Active != "direct" {
+		t.Fatalf("expected direct route to win, got %+v", snap)
+	}
+	for _, route := range snap.Routes {
+		if route.Probes != 1 || !route.Healthy || route.Score <= 0 {
+			t.Fatalf("unexpected route snapshot %+v", route)
+		}
+	}
+}
+
+func TestRegistrarProbeReportsEachRoute(t *testing.T) {
+	payload := strings.Repeat("x", 32<<10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(payload))
+	}))
+	defer upstream.Close()
+	deadHop := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "hop down", http.StatusBadGateway)
+	}))
+	defer deadHop.Close()
+
+	r := NewRegistrar(http.DefaultClient, nil)
+	defer r.Close(context.Background())
+	results := r.Probe(context.Background(), upstream.URL+"/movie.mp4", 16<<10, 0)
+	if len(results) != 1 || results[0].Route != "default" || results[0].Error != "" ||
+		results[0].Bytes != 16<<10 || results[0].Status != http.StatusOK || results[0].Score <= 0 {
+		t.Fatalf("unexpected direct probe: %+v", results)
+	}
+	if missing := r.Probe(context.Background(), upstream.URL+"/missing", 0, 0); missing[0].Status != http.StatusNotFound || missing[0].Error == "" {
+		t.Fatalf("expected 404 to be reported, got %+v", missing)
+	}
+
+	r.UpdateHops(nil, []Route{
+		{Name: "via-dead", Hops: []ChainHop{{Endpoint: deadHop.URL}}},
+		{Name: "direct"},
+	}, RouteProbeOptions{CanaryURL: upstream.URL})
+	results = r.Probe(context.Background(), upstream.URL+"/movie.mp4", 1024, time.Second)
+	if len(results) != 2 || results[0].Route != "via-dead" || results[0].Status != http.StatusBadGateway ||
+		len(results[0].Hops) != 1 || results[1].Route != "direct" || results[1].Error != "" {
+		t.Fatalf("expected one result per adaptive route, got %+v", results)
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Signer 在请求发往最终上游之前运行，可按云盘协议改写 URL、头部与请求体，
+// 例如追加签名参数或整体加密 JSON 请求体。
+type Signer interface {
+	// Name 返回插件名称，用于日志与响应头标识。
+	Name() string
+	// Sign 就地修改即将发出的请求；返回错误时代理会以 502 终止本次请求。
+	Sign(req *http.Request) error
+}
+
+// ResponseDecrypter 为可选接口：实现后 /proxy/api 会在返回客户端前调用它还原响应体。
+type ResponseDecrypter interface {
+	DecryptResponse(resp *http.Response) error
+}
+
+type signerRule struct {
+	pattern string
+	signer  Signer
+}
+
+// SignerRegistry 以主机名通配模式（path.Match 语法，如 `*.yun.139.com`）登记签名插件，
+// 按注册顺序匹配，首个命中的插件生效。
+type SignerRegistry struct {
+	mu    sync.RWMutex
+	rules []signerRule
+}
+
+// NewSignerRegistry 创建空的签名插件表。
+func NewSignerRegistry() *SignerRegistry {
+	return &SignerRegistry{}
+}
+
+// DefaultSigners 返回内置的云盘签名插件集合，目前包含 139 云盘。
+func DefaultSigners() *SignerRegistry {
+	registry := NewSignerRegistry()
+	yun139 := NewYun139Signer()
+	registry.Register("yun.139.com", yun139)
+	registry.Register("*.yun.139.com", yun139)
+	return registry
+}
+
+// Register 为主机模式登记签名插件；模式统一按小写比较。
+func (s *SignerRegistry) Register(hostPattern string, signer Signer) {
+	hostPattern = strings.ToLower(strings.TrimSpace(hostPattern))
+	if hostPattern == "" || signer == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, signerRule{pattern: hostPattern, signer: signer})
+}
+
+// Match 返回与主机名匹配的签名插件，未命中时返回 nil。
+func (s *SignerRegistry) Match(host string) Signer {
+	if s == nil {
+		return nil
+	}
+	host = strings.ToLower(host)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, rule := range s.rules {
+		if ok, err := path.Match(rule.pattern, host); err == nil && ok {
+			return rule.signer
+		}
+	}
+	return nil
+}
+
+// applySigner 仅在请求直连最终上游（未经代理链）时运行签名插件，
+// 经链路转发时由最后一跳节点负责签名，避免重复加密。
+func (r *Registrar) applySigner(req *http.Request, direct bool) (Signer, error) {
+	if !direct {
+		return nil, nil
+	}
+	signer := r.Signers.Match(req.URL.Hostname())
+	if signer == nil {
+		return nil, nil
+	}
+	if err := signer.Sign(req); err != nil {
+		return signer, err
+	}
+	return signer, nil
+}
+
+// replaceRequestBody 替换请求体并同步 Content-Length 与 GetBody，供签名插件复用。
+func replaceRequestBody(req *http.Request, body []byte) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	} else {
+		req.Header.Del("Content-Length")
+	}
+}
+
+// replaceResponseBody 替换响应体并修正长度相关头部，供解密插件复用。
+func replaceResponseBody(resp *http.Response, body []byte) {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	resp.Header.Del("Content-Encoding")
+}
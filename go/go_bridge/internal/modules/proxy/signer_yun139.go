@@ -0,0 +1,139 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// yun139KeyWords 与 Flutter 端 Yun139Crypto._keyWords 保持一致，按大端拼成 16 字节 AES 密钥。
+var yun139KeyWords = [4]uint32{1347831620, 2003657590, 1718825333, 1446208561}
+
+// Yun139Signer 移植自 lib/utils/yun_139_decrypt.dart：
+// 请求体使用 AES-128-CBC/PKCS7 加密，随机 IV 与密文拼接后 Base64 编码；响应按相同格式解密。
+type Yun139Signer struct {
+	key []byte
+	// random 用于生成 IV，测试可注入固定来源。
+	random io.Reader
+}
+
+// NewYun139Signer 创建 139 云盘签名插件。
+func NewYun139Signer() *Yun139Signer {
+	key := make([]byte, 16)
+	for i, word := range yun139KeyWords {
+		binary.BigEndian.PutUint32(key[i*4:], word)
+	}
+	return &Yun139Signer{key: key, random: rand.Reader}
+}
+
+func (s *Yun139Signer) Name() string {
+	return "yun139"
+}
+
+// Sign 加密非空请求体；GET 等无请求体的调用保持原样。
+func (s *Yun139Signer) Sign(req *http.Request) error {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	plain, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return fmt.Errorf("yun139 read body: %w", err)
+	}
+	if len(plain) == 0 {
+		replaceRequestBody(req, plain)
+		return nil
+	}
+	encrypted, err := s.Encrypt(plain)
+	if err != nil {
+		return err
+	}
+	replaceRequestBody(req, []byte(encrypted))
+	return nil
+}
+
+// DecryptResponse 尝试解密 Base64 形式的响应体；明文 JSON 等无法解密的内容原样保留。
+func (s *Yun139Signer) DecryptResponse(resp *http.Response) error {
+	raw, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("yun139 read response: %w", err)
+	}
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] == '{' || trimmed[0] == '[' {
+		replaceResponseBody(resp, raw)
+		return nil
+	}
+	plain, err := s.Decrypt(string(trimmed))
+	if err != nil {
+		replaceResponseBody(resp, raw)
+		return nil
+	}
+	replaceResponseBody(resp, plain)
+	resp.Header.Set("Content-Type", "application/json;charset=UTF-8")
+	return nil
+}
+
+// Encrypt 对应 Dart 版 Yun139Crypto.encrypt。
+func (s *Yun139Signer) Encrypt(plain []byte) (string, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return "", fmt.Errorf("yun139 cipher: %w", err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(s.random, iv); err != nil {
+		return "", fmt.Errorf("yun139 iv: %w", err)
+	}
+	padded := pkcs7Pad(plain, aes.BlockSize)
+	out := make([]byte, aes.BlockSize+len(padded))
+	copy(out, iv)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(out[aes.BlockSize:], padded)
+	return base64.StdEncoding.EncodeToString(out), nil
+}
+
+// Decrypt 对应 Dart 版 Yun139Crypto.decrypt：前 16 字节为 IV，其后为密文。
+func (s *Yun139Signer) Decrypt(encoded string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("yun139 base64: %w", err)
+	}
+	if len(raw) < 2*aes.BlockSize || len(raw)%aes.BlockSize != 0 {
+		return nil, errors.New("yun139 ciphertext has invalid length")
+	}
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, fmt.Errorf("yun139 cipher: %w", err)
+	}
+	plain := make([]byte, len(raw)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, raw[:aes.BlockSize]).CryptBlocks(plain, raw[aes.BlockSize:])
+	return pkcs7Unpad(plain, aes.BlockSize)
+}
+
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padding := blockSize - len(data)%blockSize
+	return append(append([]byte(nil), data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+}
+
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, errors.New("pkcs7: invalid data length")
+	}
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > blockSize || padding > len(data) {
+		return nil, errors.New("pkcs7: invalid padding")
+	}
+	for _, b := range data[len(data)-padding:] {
+		if int(b) != padding {
+			return nil, errors.New("pkcs7: invalid padding")
+		}
+	}
+	return data[:len(data)-padding], nil
+}
@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+// activeStream 为一次正在转发响应体的媒体请求，bytes 在转发过程中持续累加。
+type activeStream struct {
+	id       uint64
+	identity string
+	clientIP string
+	host     string
+	path     string
+	rng      string
+	status   int
+	started  time.Time
+	bytes    atomic.Int64
+}
+
+// StreamSnapshot 为进行中的播放流，供管理界面展示；只包含上游主机与路径，不含查询参数中的签名。
+type StreamSnapshot struct {
+	ID             uint64    `json:"id"`
+	Identity       string    `json:"identity"`
+	ClientIP       string    `json:"client_ip"`
+	Host           string    `json:"host"`
+	Path           string    `json:"path"`
+	Range          string    `json:"range,omitempty"`
+	Status         int       `json:"status"`
+	StartedAt      time.Time `json:"started_at"`
+	DurationSec    float64   `json:"duration_sec"`
+	Bytes          int64     `json:"bytes"`
+	ThroughputKbps float64   `json:"throughput_kbps"`
+}
+
+// streamRegistry 记录正在转发的媒体流，请求结束时移除。
+type streamRegistry struct {
+	mu      sync.Mutex
+	nextID  uint64
+	streams map[uint64]*activeStream
+}
+
+func newStreamRegistry() *streamRegistry {
+	return &streamRegistry{streams: map[uint64]*activeStream{}}
+}
+
+func (s *streamRegistry) open(c *gin.Context, target *url.URL, status int) *activeStream {
+	stream := &activeStream{
+		identity: identity.Name(c),
+		clientIP: c.ClientIP(),
+		host:     target.Host,
+		path:     target.Path,
+		rng:      c.GetHeader("Range"),
+		status:   status,
+		started:  time.Now(),
+	}
+	s.mu.Lock()
+	s.nextID++
+	stream.id = s.nextID
+	s.streams[stream.id] = stream
+	s.mu.Unlock()
+	return stream
+}
+
+func (s *streamRegistry) close(stream *activeStream) {
+	s.mu.Lock()
+	delete(s.streams, stream.id)
+	s.mu.Unlock()
+}
+
+// snapshot 按开始时间输出进行中的流。
+func (s *streamRegistry) snapshot() []StreamSnapshot {
+	s.mu.Lock()
+	streams := make([]*activeStream, 0, len(s.streams))
+	for _, stream := range s.streams {
+		streams = append(streams, stream)
+	}
+	s.mu.Unlock()
+	sort.Slice(streams, func(i, j int) bool { return streams[i].id < streams[j].id })
+
+	now := time.Now()
+	out := make([]StreamSnapshot, 0, len(streams))
+	for _, stream := range streams {
+		elapsed := now.Sub(stream.started).Seconds()
+		bytes := stream.bytes.Load()
+		snap := StreamSnapshot{
+			ID:          stream.id,
+			Identity:    stream.identity,
+			ClientIP:    stream.clientIP,
+			Host:        stream.host,
+			Path:        stream.path,
+			Range:       stream.rng,
+			Status:      stream.status,
+			StartedAt:   stream.started.UTC(),
+			DurationSec: elapsed,
+			Bytes:       bytes,
+		}
+		if elapsed > 0 {
+			snap.ThroughputKbps = float64(bytes) * 8 / 1000 / elapsed
+		}
+		out = append(out, snap)
+	}
+	return out
+}
+
+// proxyStatus 为代理模块在 /admin/status 中的运行状态。
+type proxyStatus struct {
+	ActiveStreams []StreamSnapshot `json:"active_streams"`
+	// RouteSessions 为自适应选路中仍在有效期内的会话数，未启用时为 0。
+	RouteSessions int `json:"route_sessions"`
+}
+
+// Status 输出进行中的播放流与选路会话数。
+func (r *Registrar) Status(context.Context) any {
+	status := proxyStatus{ActiveStreams: r.streams.snapshot()}
+	r.mu.RLock()
+	selector := r.selector
+	r.mu.RUnlock()
+	if selector != nil {
+		status.RouteSessions = selector.snapshot().Sessions
+	}
+	return status
+}
@@ -0,0 +1,241 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxSubtitleBytes 限制单个字幕文件大小，防止误把视频当字幕拉取。
+	maxSubtitleBytes = 16 << 20
+	subtitleCacheTTL = 5 * time.Minute
+	subtitleCacheMax = 128
+)
+
+// subtitleEntry 缓存已解码为 UTF-8 的字幕原文，偏移与格式转换在命中后按需计算。
+type subtitleEntry struct {
+	text      string
+	charset   string
+	fetchedAt time.Time
+}
+
+// subtitleCache 是按 target URL 与字符集提示索引的短时内存缓存。
+type subtitleCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	max   int
+	items map[string]subtitleEntry
+}
+
+func newSubtitleCache(ttl time.Duration, max int) *subtitleCache {
+	return &subtitleCache{ttl: ttl, max: max, items: map[string]subtitleEntry{}}
+}
+
+func (s *subtitleCache) get(key string) (subtitleEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.items[key]
+	if !ok {
+		return subtitleEntry{}, false
+	}
+	if time.Since(entry.fetchedAt) > s.ttl {
+		delete(s.items, key)
+		return subtitleEntry{}, false
+	}
+	return entry, true
+}
+
+func (s *subtitleCache) put(key string, entry subtitleEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.items) >= s.max {
+		// 先淘汰过期项，仍然超限时淘汰最早写入的一项。
+		var oldestKey string
+		var oldest time.Time
+		for k, v := range s.items {
+			if time.Since(v.fetchedAt) > s.ttl {
+				delete(s.items, k)
+				continue
+			}
+			if oldestKey == "" || v.fetchedAt.Before(oldest) {
+				oldestKey, oldest = k, v.fetchedAt
+			}
+		}
+		if len(s.items) >= s.max && oldestKey != "" {
+			delete(s.items, oldestKey)
+		}
+	}
+	s.items[key] = entry
+}
+
+// subtitleHandler 经代理链拉取字幕，转码为 UTF-8，并可选转换为 WebVTT、平移时间轴。
+// 查询参数：target（必填）、charset（强制源编码）、format（srt/ass/vtt）、offset（如 1500ms、-2s 或毫秒数）。
+func (r *Registrar) subtitleHandler(client *http.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		target := strings.TrimSpace(c.Query("target"))
+		if target == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target is required"})
+			return
+		}
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid target url"})
+			return
+		}
+
+		format := strings.ToLower(strings.TrimSpace(c.Query("format")))
+		switch format {
+		case "", subtitleFormatSRT, subtitleFormatASS, subtitleFormatVTT:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be srt, ass or vtt"})
+			return
+		}
+		offset, err := parseSubtitleOffset(c.Query("offset"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		charsetHint := strings.TrimSpace(c.Query("charset"))
+
+		cacheKey := subtitleCacheKey(c, parsed, charsetHint)
+		entry, hit := r.subtitles.get(cacheKey)
+		if !hit {
+			raw, status, err := r.fetchSubtitle(c, client, parsed)
+			var rejection *breakerRejection
+			if errors.As(err, &rejection) {
+				rejectOpenCircuit(c, rejection)
+				return
+			}
+			if err != nil {
+				c.JSON(status, gin.H{"error": err.Error()})
+				return
+			}
+			text, charset, err := decodeSubtitle(raw, charsetHint)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+				return
+			}
+			entry = subtitleEntry{text: text, charset: charset, fetchedAt: time.Now()}
+			r.subtitles.put(cacheKey, entry)
+		}
+
+		source := detectSubtitleFormat(entry.text, parsed.Path)
+		output, err := convertSubtitle(entry.text, source, format, offset)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if format == "" {
+			format = source
+		}
+
+		c.Header("X-Subtitle-Charset", entry.charset)
+		c.Header("X-Subtitle-Source-Format", source)
+		c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(subtitleCacheTTL.Seconds())))
+		if hit {
+			c.Header("X-Cache", "HIT")
+		} else {
+			c.Header("X-Cache", "MISS")
+		}
+		c.Data(http.StatusOK, subtitleContentType(format), []byte(output))
+	}
+}
+
+// subtitleCacheKey 由目标地址、字符集提示与转发给上游的凭据组成：带 Authorization/Cookie
+// 拉取的私有字幕只有持相同凭据的调用方才能命中缓存。
+func subtitleCacheKey(c *gin.Context, target *url.URL, charsetHint string) string {
+	key := target.String() + "|" + strings.ToLower(charsetHint)
+	auth, cookie := c.GetHeader("Authorization"), c.GetHeader("Cookie")
+	if auth == "" && cookie == "" {
+		return key
+	}
+	sum := sha256.Sum256([]byte(auth + "\x00" + cookie))
+	return key + "|" + hex.EncodeToString(sum[:])
+}
+
+// fetchSubtitle 复用媒体代理的链路与熔断逻辑拉取字幕原始字节。
+func (r *Registrar) fetchSubtitle(c *gin.Context, client *http.Client, target *url.URL) ([]byte, int, error) {
+	hops := r.selectHops(c, target.String())
+	forwardTarget, hopHeaders, err := buildChainedURL(hops, target.String(), "/proxy/media")
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodGet, forwardTarget, nil)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New("proxy request build failed: " + upstreamError(err))
+	}
+	for key, values := range hopHeaders {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	for _, key := range []string{"User-Agent", "Referer", "Authorization", "Cookie"} {
+		if value := c.GetHeader(key); value != "" {
+			req.Header.Set(key, value)
+		}
+	}
+	forwardRequestID(req, forwardTarget == target.String())
+
+	ticket, rejection := r.breakers.acquire(breakerKeys(hops, target)...)
+	if rejection != nil {
+		return nil, http.StatusServiceUnavailable, rejection
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		reason := ticket.fail(time.Since(start), err)
+		return nil, http.StatusBadGateway, errors.New("proxy request failed: " + reason)
+	}
+	defer resp.Body.Close()
+	ticket.done(time.Since(start), resp.StatusCode >= http.StatusInternalServerError, resp.Status)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, http.StatusBadGateway, fmt.Errorf("upstream returned %s", resp.Status)
+	}
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, maxSubtitleBytes+1))
+	if err != nil {
+		return nil, http.StatusBadGateway, fmt.Errorf("read subtitle: %v", err)
+	}
+	if len(raw) > maxSubtitleBytes {
+		return nil, http.StatusRequestEntityTooLarge, errors.New("subtitle exceeds 16MB limit")
+	}
+	return raw, http.StatusOK, nil
+}
+
+// parseSubtitleOffset 支持 Go duration（`-1.5s`、`800ms`）或纯毫秒整数。
+func parseSubtitleOffset(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, errors.New("invalid offset, use milliseconds or a duration like -1.5s")
+	}
+	return d, nil
+}
+
+func subtitleContentType(format string) string {
+	switch format {
+	case subtitleFormatVTT:
+		return "text/vtt; charset=utf-8"
+	case subtitleFormatASS:
+		return "text/x-ssa; charset=utf-8"
+	default:
+		return "application/x-subrip; charset=utf-8"
+	}
+}
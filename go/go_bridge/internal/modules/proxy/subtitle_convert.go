@@ -0,0 +1,401 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// 字幕格式标识，同时用作 format 查询参数的取值。
+const (
+	subtitleFormatSRT = "srt"
+	subtitleFormatASS = "ass"
+	subtitleFormatVTT = "vtt"
+)
+
+var (
+	srtTimingPattern = regexp.MustCompile(
+		`^\s*(\d+:\d{1,2}:\d{1,2}[,.]\d{1,3})\s*-->\s*(\d+:\d{1,2}:\d{1,2}[,.]\d{1,3})(.*)$`,
+	)
+	vttTimingPattern = regexp.MustCompile(
+		`^\s*((?:\d+:)?\d{1,2}:\d{1,2}\.\d{1,3})\s*-->\s*((?:\d+:)?\d{1,2}:\d{1,2}\.\d{1,3})(.*)$`,
+	)
+	assOverridePattern = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// decodeSubtitle 将字幕字节转换为 UTF-8 文本，并返回实际使用的字符集名称。
+// charsetHint 非空时强制使用指定编码；否则依次检查 BOM、UTF-8 合法性，
+// 最后在 GB18030 与 Big5 之间按双字节分布打分选择。
+func decodeSubtitle(raw []byte, charsetHint string) (string, string, error) {
+	if hint := strings.TrimSpace(charsetHint); hint != "" {
+		enc, err := htmlindex.Get(hint)
+		if err != nil {
+			return "", "", fmt.Errorf("unsupported charset: %s", hint)
+		}
+		name, _ := htmlindex.Name(enc)
+		text, err := decodeWith(enc, raw)
+		return text, name, err
+	}
+
+	switch {
+	case bytes.HasPrefix(raw, []byte{0xEF, 0xBB, 0xBF}):
+		return string(raw[3:]), "utf-8", nil
+	case bytes.HasPrefix(raw, []byte{0xFF, 0xFE}):
+		text, err := decodeWith(unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), raw)
+		return text, "utf-16le", err
+	case bytes.HasPrefix(raw, []byte{0xFE, 0xFF}):
+		text, err := decodeWith(unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), raw)
+		return text, "utf-16be", err
+	}
+	if utf8.Valid(raw) {
+		return string(raw), "utf-8", nil
+	}
+
+	if big5Score(raw) > gbScore(raw) {
+		text, err := decodeWith(traditionalchinese.Big5, raw)
+		return text, "big5", err
+	}
+	text, err := decodeWith(simplifiedchinese.GB18030, raw)
+	return text, "gb18030", err
+}
+
+func decodeWith(enc encoding.Encoding, raw []byte) (string, error) {
+	out, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return "", fmt.Errorf("decode subtitle: %w", err)
+	}
+	return strings.TrimPrefix(string(out), "\ufeff"), nil
+}
+
+// gbScore 统计按 GBK 切分时落在 GB2312 常用汉字区（高字节 B0-F7、低字节 A1-FE）的双字节比例。
+func gbScore(raw []byte) float64 {
+	var total, hits int
+	for i := 0; i < len(raw); i++ {
+		b := raw[i]
+		if b < 0x80 {
+			continue
+		}
+		if i+1 >= len(raw) {
+			break
+		}
+		trail := raw[i+1]
+		total++
+		if b >= 0xB0 && b <= 0xF7 && trail >= 0xA1 && trail <= 0xFE {
+			hits++
+		}
+		i++
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// big5Score 统计按 Big5 切分时落在常用字区（高字节 A4-C6、低字节 40-7E/A1-FE）的双字节比例。
+// Big5 约一半字符的低字节位于 40-7E，这在 GB2312 中不合法，两者据此可以区分。
+func big5Score(raw []byte) float64 {
+	var total, hits int
+	for i := 0; i < len(raw); i++ {
+		b := raw[i]
+		if b < 0x80 {
+			continue
+		}
+		if i+1 >= len(raw) {
+			break
+		}
+		trail := raw[i+1]
+		total++
+		validTrail := (trail >= 0x40 && trail <= 0x7E) || (trail >= 0xA1 && trail <= 0xFE)
+		if b >= 0xA4 && b <= 0xC6 && validTrail {
+			hits++
+		}
+		i++
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// detectSubtitleFormat 优先依据内容特征判断格式，其次参考文件扩展名。
+func detectSubtitleFormat(text, path string) string {
+	trimmed := strings.TrimSpace(text)
+	switch {
+	case strings.HasPrefix(trimmed, "WEBVTT"):
+		return subtitleFormatVTT
+	case strings.Contains(text, "[Script Info]") || strings.Contains(text, "[Events]"):
+		return subtitleFormatASS
+	}
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".ass"), strings.HasSuffix(lower, ".ssa"):
+		return subtitleFormatASS
+	case strings.HasSuffix(lower, ".vtt"):
+		return subtitleFormatVTT
+	}
+	return subtitleFormatSRT
+}
+
+// convertSubtitle 将文本按 offset 平移时间轴，并在 target 与源格式不同时转换格式。
+// 目前支持任意格式原样平移，以及 SRT/ASS 转 WebVTT。
+func convertSubtitle(text, source, target string, offset time.Duration) (string, error) {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+	if target == "" {
+		target = source
+	}
+	switch {
+	case source == target && source == subtitleFormatASS:
+		return shiftASS(text, offset), nil
+	case source == target:
+		return shiftCueTimings(text, source, offset), nil
+	case target == subtitleFormatVTT && source == subtitleFormatSRT:
+		return srtToVTT(text, offset), nil
+	case target == subtitleFormatVTT && source == subtitleFormatASS:
+		return assToVTT(text, offset)
+	}
+	return "", fmt.Errorf("unsupported conversion: %s -> %s", source, target)
+}
+
+// shiftCueTimings 平移 SRT/VTT 的时间轴行，其余行保持不变。
+func shiftCueTimings(text, format string, offset time.Duration) string {
+	if offset == 0 {
+		return text
+	}
+	pattern := srtTimingPattern
+	if format == subtitleFormatVTT {
+		pattern = vttTimingPattern
+	}
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		m := pattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		start, errStart := parseCueTime(m[1])
+		end, errEnd := parseCueTime(m[2])
+		if errStart != nil || errEnd != nil {
+			continue
+		}
+		sep := ","
+		if format == subtitleFormatVTT {
+			sep = "."
+		}
+		lines[i] = formatCueTime(start+offset, sep) + " --> " + formatCueTime(end+offset, sep) + m[3]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// srtToVTT 将 SRT 转为 WebVTT：补充文件头并把毫秒分隔符改为点号。
+func srtToVTT(text string, offset time.Duration) string {
+	var out strings.Builder
+	out.WriteString("WEBVTT\n\n")
+	for _, line := range strings.Split(strings.TrimPrefix(text, "\ufeff"), "\n") {
+		if m := srtTimingPattern.FindStringSubmatch(line); m != nil {
+			start, errStart := parseCueTime(m[1])
+			end, errEnd := parseCueTime(m[2])
+			if errStart == nil && errEnd == nil {
+				line = formatCueTime(start+offset, ".") + " --> " + formatCueTime(end+offset, ".")
+			}
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	return out.String()
+}
+
+// assToVTT 读取 [Events] 段的 Dialogue 行，去除样式覆盖标签后输出 WebVTT。
+func assToVTT(text string, offset time.Duration) (string, error) {
+	events, err := parseASSEvents(text)
+	if err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	out.WriteString("WEBVTT\n\n")
+	for _, ev := range events {
+		body := assOverridePattern.ReplaceAllString(ev.text, "")
+		body = strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ").Replace(body)
+		body = strings.TrimSpace(body)
+		if body == "" {
+			continue
+		}
+		fmt.Fprintf(&out, "%s --> %s\n%s\n\n",
+			formatCueTime(ev.start+offset, "."),
+			formatCueTime(ev.end+offset, "."),
+			body,
+		)
+	}
+	return out.String(), nil
+}
+
+type assEvent struct {
+	start time.Duration
+	end   time.Duration
+	text  string
+}
+
+// parseASSEvents 依据 Format 行确定 Start/End/Text 列位置，Text 列可包含逗号。
+func parseASSEvents(text string) ([]assEvent, error) {
+	inEvents := false
+	startIdx, endIdx, textIdx, fieldCount := -1, -1, -1, 0
+	var events []assEvent
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Format":
+			fields := strings.Split(value, ",")
+			fieldCount = len(fields)
+			for i, f := range fields {
+				switch strings.ToLower(strings.TrimSpace(f)) {
+				case "start":
+					startIdx = i
+				case "end":
+					endIdx = i
+				case "text":
+					textIdx = i
+				}
+			}
+		case "Dialogue":
+			if startIdx < 0 || endIdx < 0 || textIdx < 0 {
+				return nil, errors.New("ass events missing Format line")
+			}
+			fields := strings.SplitN(strings.TrimSpace(value), ",", fieldCount)
+			if len(fields) <= textIdx {
+				continue
+			}
+			start, errStart := parseCueTime(fields[startIdx])
+			end, errEnd := parseCueTime(fields[endIdx])
+			if errStart != nil || errEnd != nil {
+				continue
+			}
+			events = append(events, assEvent{start: start, end: end, text: fields[textIdx]})
+		}
+	}
+	return events, nil
+}
+
+// shiftASS 平移 ASS Dialogue 行的 Start/End 列，保留其它字段原样输出。
+func shiftASS(text string, offset time.Duration) string {
+	if offset == 0 {
+		return text
+	}
+	inEvents := false
+	startIdx, endIdx, fieldCount := -1, -1, 0
+	lines := strings.Split(text, "\n")
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Format":
+			fields := strings.Split(value, ",")
+			fieldCount = len(fields)
+			for idx, f := range fields {
+				switch strings.ToLower(strings.TrimSpace(f)) {
+				case "start":
+					startIdx = idx
+				case "end":
+					endIdx = idx
+				}
+			}
+		case "Dialogue", "Comment":
+			if startIdx < 0 || endIdx < 0 {
+				continue
+			}
+			fields := strings.SplitN(strings.TrimSpace(value), ",", fieldCount)
+			if len(fields) <= startIdx || len(fields) <= endIdx {
+				continue
+			}
+			start, errStart := parseCueTime(fields[startIdx])
+			end, errEnd := parseCueTime(fields[endIdx])
+			if errStart != nil || errEnd != nil {
+				continue
+			}
+			fields[startIdx] = formatASSTime(start + offset)
+			fields[endIdx] = formatASSTime(end + offset)
+			lines[i] = strings.TrimSpace(key) + ": " + strings.Join(fields, ",")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseCueTime 解析 `HH:MM:SS,mmm`、`MM:SS.mmm` 与 ASS 的 `H:MM:SS.cc` 时间戳。
+func parseCueTime(value string) (time.Duration, error) {
+	value = strings.TrimSpace(strings.Replace(value, ",", ".", 1))
+	clock, frac, _ := strings.Cut(value, ".")
+	parts := strings.Split(clock, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp: %s", value)
+	}
+	var total time.Duration
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid timestamp: %s", value)
+		}
+		total = total*60 + time.Duration(n)
+	}
+	total *= time.Second
+	if frac != "" {
+		if len(frac) > 3 {
+			frac = frac[:3]
+		}
+		n, err := strconv.Atoi(frac)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp: %s", value)
+		}
+		for i := len(frac); i < 3; i++ {
+			n *= 10
+		}
+		total += time.Duration(n) * time.Millisecond
+	}
+	return total, nil
+}
+
+// formatCueTime 输出 `HH:MM:SS<sep>mmm`，负数时间被钳制为 0。
+func formatCueTime(d time.Duration, sep string) string {
+	if d < 0 {
+		d = 0
+	}
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", ms/3600000, ms/60000%60, ms/1000%60, sep, ms%1000)
+}
+
+// formatASSTime 输出 ASS 使用的 `H:MM:SS.cc`（百分之一秒）格式。
+func formatASSTime(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	cs := d.Milliseconds() / 10
+	return fmt.Sprintf("%d:%02d:%02d.%02d", cs/360000, cs/6000%60, cs/100%60, cs%100)
+}
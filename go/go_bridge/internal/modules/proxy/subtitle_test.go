@@ -0,0 +1,157 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+)
+
+const sampleSRT = "1\r\n00:00:01,000 --> 00:00:02,500\r\n你好，世界\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\n再见\r\n"
+
+func TestDecodeSubtitleDetectsChineseCharsets(t *testing.T) {
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(sampleSRT)
+	if err != nil {
+		t.Fatalf("encode gbk: %v", err)
+	}
+	text, charset, err := decodeSubtitle([]byte(gbk), "")
+	if err != nil || charset != "gb18030" || text != sampleSRT {
+		t.Fatalf("gbk detection failed: charset=%s err=%v", charset, err)
+	}
+
+	traditional := "1\n00:00:01,000 --> 00:00:02,000\n這是一個繁體中文字幕測試，請確認顯示正常。\n"
+	big5, err := traditionalchinese.Big5.NewEncoder().String(traditional)
+	if err != nil {
+		t.Fatalf("encode big5: %v", err)
+	}
+	text, charset, err = decodeSubtitle([]byte(big5), "")
+	if err != nil || charset != "big5" || text != traditional {
+		t.Fatalf("big5 detection failed: charset=%s text=%q err=%v", charset, text, err)
+	}
+
+	text, charset, err = decodeSubtitle([]byte(gbk), "gbk")
+	if err != nil || charset != "gbk" || text != sampleSRT {
+		t.Fatalf("charset hint ignored: charset=%s err=%v", charset, err)
+	}
+}
+
+func TestConvertSubtitleSRTToVTTWithOffset(t *testing.T) {
+	out, err := convertSubtitle(sampleSRT, subtitleFormatSRT, subtitleFormatVTT, -1500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if !strings.HasPrefix(out, "WEBVTT\n\n") {
+		t.Fatalf("missing WEBVTT header: %q", out)
+	}
+	if !strings.Contains(out, "00:00:00.000 --> 00:00:01.000") {
+		t.Fatalf("negative offset should clamp to zero: %q", out)
+	}
+	if !strings.Contains(out, "00:00:01.500 --> 00:00:02.500") {
+		t.Fatalf("unexpected second cue timing: %q", out)
+	}
+}
+
+func TestConvertSubtitleASS(t *testing.T) {
+	ass := strings.Join([]string{
+		"[Script Info]",
+		"Title: demo",
+		"",
+		"[Events]",
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text",
+		`Dialogue: 0,0:00:01.20,0:00:03.00,Default,,0,0,0,,{\b1}第一行\N第二行, 带逗号`,
+	}, "\n")
+
+	vtt, err := convertSubtitle(ass, subtitleFormatASS, subtitleFormatVTT, time.Second)
+	if err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	if !strings.Contains(vtt, "00:00:02.200 --> 00:00:04.000\n第一行\n第二行, 带逗号") {
+		t.Fatalf("unexpected vtt: %q", vtt)
+	}
+
+	shifted, err := convertSubtitle(ass, subtitleFormatASS, "", time.Second)
+	if err != nil {
+		t.Fatalf("shift: %v", err)
+	}
+	if !strings.Contains(shifted, `Dialogue: 0,0:00:02.20,0:00:04.00,Default,,0,0,0,,{\b1}第一行\N第二行, 带逗号`) {
+		t.Fatalf("unexpected shifted ass: %q", shifted)
+	}
+}
+
+func TestSubtitleHandlerTranscodesAndCaches(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(sampleSRT)
+	hits := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		_, _ = w.Write([]byte(gbk))
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewRegistrar(nil, nil).Register(engine)
+
+	path := "/proxy/subtitle?format=vtt&offset=500&target=" + url.QueryEscape(upstream.URL+"/movie.srt")
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); ct != "text/vtt; charset=utf-8" {
+			t.Fatalf("unexpected content type %s", ct)
+		}
+		if !strings.Contains(w.Body.String(), "00:00:01.500 --> 00:00:03.000\n你好，世界") {
+			t.Fatalf("unexpected body %q", w.Body.String())
+		}
+	}
+	if hits != 1 {
+		t.Fatalf("expected cached subtitle to skip upstream, hits=%d", hits)
+	}
+}
+
+func TestSubtitleCacheIsPerCredential(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer alice":
+			_, _ = w.Write([]byte("1\n00:00:01,000 --> 00:00:02,000\nalice only\n"))
+		case "Bearer bob":
+			_, _ = w.Write([]byte("1\n00:00:01,000 --> 00:00:02,000\nbob only\n"))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer upstream.Close()
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	NewRegistrar(nil, nil).Register(engine)
+
+	fetch := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/proxy/subtitle?target="+url.QueryEscape(upstream.URL+"/private.srt"), nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	if w := fetch("Bearer alice"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "alice only") {
+		t.Fatalf("unexpected first fetch %d %q", w.Code, w.Body.String())
+	}
+	if w := fetch(""); w.Code == http.StatusOK {
+		t.Fatalf("anonymous caller must not get the cached private subtitle: %q", w.Body.String())
+	}
+	if w := fetch("Bearer bob"); !strings.Contains(w.Body.String(), "bob only") {
+		t.Fatalf("another identity must fetch its own subtitle, got %d %q", w.Code, w.Body.String())
+	}
+	if w := fetch("Bearer alice"); w.Header().Get("X-Cache") != "HIT" {
+		t.Fatalf("same credentials should still hit the cache")
+	}
+}
@@ -17,7 +17,9 @@ import (
 	"github.com/jmoiron/sqlx"
 
 	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/audit"
 	"github.com/zhouquan/webdav_video/go_bridge/internal/server/httpjson"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
 )
 
 // Registrar 负责截图上传与目录同步相关的 REST 接口。
@@ -65,9 +67,25 @@ func (r *Registrar) Register(engine *gin.Engine) {
 			return
 		}
 
+		// 审计只记录元数据，不保存图片内容。
+		entry := audit.Entry{Args: map[string]any{
+			"videoSha1": sha1,
+			"userId":    req.UserID,
+			"videoName": req.VideoName,
+			"isJpeg":    req.IsJpeg,
+			"bytes":     len(imageBytes),
+		}}
+		if !callerOwns(c, req.UserID) {
+			entry.Outcome, entry.Error = audit.OutcomeDenied, "userId does not match caller"
+			audit.Record(c, entry)
+			return
+		}
+
 		userDir := fmt.Sprintf("%d", req.UserID)
 		destDir := filepath.Join(r.Config.ScreenshotDir, userDir)
 		if err := os.MkdirAll(destDir, 0o755); err != nil {
+			entry.Outcome, entry.Error = audit.OutcomeError, fmt.Sprintf("mkdir: %v", err)
+			audit.Record(c, entry)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("mkdir: %v", err)})
 			return
 		}
@@ -79,15 +97,16 @@ func (r *Registrar) Register(engine *gin.Engine) {
 		}
 		fileName := fmt.Sprintf("%s_%s.%s", userDir, sha1, ext)
 		destPath := filepath.Join(destDir, fileName)
+		entry.Args["file"] = fileName
 		if err := os.WriteFile(destPath, imageBytes, 0o644); err != nil {
+			entry.Outcome, entry.Error = audit.OutcomeError, fmt.Sprintf("write file: %v", err)
+			audit.Record(c, entry)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("write file: %v", err)})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"status":      "ok",
-			"contentType": contentType,
-			"file":        fileName,
-		})
+		entry.Outcome, entry.AffectedRows = audit.OutcomeOK, 1
+		audit.Record(c, entry)
+		c.JSON(http.StatusOK, uploadResponse{Status: "ok", ContentType: contentType, File: fileName})
 	})
 
 	engine.GET("/history/screenshot", func(c *gin.Context) {
@@ -112,6 +131,9 @@ func (r *Registrar) Register(engine *gin.Engine) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid userId"})
 			return
 		}
+		if !callerOwns(c, userID) {
+			return
+		}
 		userDir := fmt.Sprintf("%d", userID)
 		targetDir := filepath.Join(r.Config.ScreenshotDir, userDir)
 
@@ -139,6 +161,12 @@ func (r *Registrar) Register(engine *gin.Engine) {
 	})
 
 	engine.POST("/history/screenshot/sync", func(c *gin.Context) {
+		// 同步会清理所有用户的截图，绑定了用户的非管理员调用方无权执行。
+		if _, restricted := identity.RestrictedUser(c); restricted {
+			audit.Record(c, audit.Entry{Outcome: audit.OutcomeDenied, Error: "restricted identity"})
+			c.JSON(http.StatusForbidden, gin.H{"error": "screenshot sync requires an admin or unbound identity"})
+			return
+		}
 		var bodyReq screenshotSyncRequest
 		hasBody := c.Request.ContentLength != 0
 		if hasBody {
@@ -176,26 +204,51 @@ func (r *Registrar) Register(engine *gin.Engine) {
 		defer cancel()
 
 		result, err := syncScreenshotsAndPrune(ctx, r.DB, r.Config.ScreenshotDir, dryRun, previewLimit)
+		// 影响行数记录实际删除的文件数（出错时为已删除的部分），dryRun 时为 0。
+		entry := audit.Entry{
+			Args:         map[string]any{"dryRun": dryRun, "previewLimit": previewLimit},
+			AffectedRows: int64(result.DeletedFiles),
+		}
 		if err != nil {
+			entry.Outcome, entry.Error = audit.OutcomeError, err.Error()
+			audit.Record(c, entry)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
+		entry.Outcome = audit.OutcomeOK
+		entry.Args["orphanCandidates"] = result.OrphanCandidates
+		audit.Record(c, entry)
 		c.JSON(http.StatusOK, result)
 	})
 }
 
+// callerOwns 校验请求中的 userId 与调用方绑定的用户一致，不一致时直接返回 403。
+func callerOwns(c *gin.Context, userID int64) bool {
+	if bound, restricted := identity.RestrictedUser(c); restricted && bound != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "userId does not match caller"})
+		return false
+	}
+	return true
+}
+
 type screenshotUploadRequest struct {
 	VideoSha1   string `json:"videoSha1"`
 	UserID      int64  `json:"userId"`
-	VideoName   string `json:"videoName"`
-	VideoPath   string `json:"videoPath"`
-	IsJpeg      bool   `json:"isJpeg"`
+	VideoName   string `json:"videoName" openapi:"optional"`
+	VideoPath   string `json:"videoPath" openapi:"optional"`
+	IsJpeg      bool   `json:"isJpeg" openapi:"optional"`
 	ImageBase64 string `json:"imageBase64"`
 }
 
+type uploadResponse struct {
+	Status      string `json:"status"`
+	ContentType string `json:"contentType"`
+	File        string `json:"file"`
+}
+
 type screenshotSyncRequest struct {
-	DryRun       bool `json:"dryRun"`
-	PreviewLimit int  `json:"previewLimit"`
+	DryRun       bool `json:"dryRun" openapi:"optional"`
+	PreviewLimit int  `json:"previewLimit" openapi:"optional"`
 }
 
 // sanitizeIdentifier 仅在截图模块内部复制一份，避免直接导出 SQL 层实现。
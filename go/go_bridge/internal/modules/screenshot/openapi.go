@@ -0,0 +1,37 @@
+package screenshot
+
+import (
+	"net/http"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/openapi"
+)
+
+// APIRoutes 描述截图上传、下载与目录同步接口。
+func (r *Registrar) APIRoutes() []openapi.Route {
+	if r.DB == nil {
+		return nil
+	}
+	return []openapi.Route{
+		{
+			Method: http.MethodPost, Path: "/history/screenshot", Tag: "screenshot",
+			Summary: "上传历史截图（Base64）", Request: screenshotUploadRequest{}, Response: uploadResponse{},
+		},
+		{
+			Method: http.MethodGet, Path: "/history/screenshot", Tag: "screenshot",
+			Summary: "下载历史截图，优先返回 jpg", ContentType: "image/*",
+			Query: []openapi.Parameter{
+				openapi.QueryParam("videoSha1", "string", true, "视频 sha1"),
+				openapi.QueryParam("userId", "integer", true, "用户 ID"),
+			},
+		},
+		{
+			Method: http.MethodPost, Path: "/history/screenshot/sync", Tag: "screenshot",
+			Summary: "清理数据库中已不存在的截图，默认 dryRun=true 仅预览",
+			Request: screenshotSyncRequest{}, RequestOptional: true, Response: screenshotCleanupResult{},
+			Query: []openapi.Parameter{
+				openapi.QueryParam("dryRun", "boolean", false, "覆盖请求体中的 dryRun"),
+				openapi.QueryParam("previewLimit", "integer", false, "返回的孤儿截图明细条数（1-2000）"),
+			},
+		},
+	}
+}
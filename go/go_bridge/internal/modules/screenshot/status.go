@@ -0,0 +1,54 @@
+package screenshot
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+)
+
+// storageStatus 为截图目录的磁盘占用，供 /admin/status 展示。
+type storageStatus struct {
+	Dir   string `json:"dir"`
+	Users int    `json:"users"`
+	Files int    `json:"files"`
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+// Status 遍历截图目录统计用户子目录数、文件数与总字节数；请求取消时返回已统计的部分。
+func (r *Registrar) Status(ctx context.Context) any {
+	if r.DB == nil {
+		return nil
+	}
+	return screenshotStorage(ctx, r.Config.ScreenshotDir)
+}
+
+func screenshotStorage(ctx context.Context, root string) storageStatus {
+	status := storageStatus{Dir: root}
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if entry.IsDir() {
+			if path != root && filepath.Dir(path) == root {
+				status.Users++
+			}
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		status.Files++
+		status.Bytes += info.Size()
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		status.Error = err.Error()
+	}
+	return status
+}
@@ -246,6 +246,15 @@ func pruneEmptyUserDirs(dirPath string) error {
 	return os.Remove(dirPath)
 }
 
+// SyncResult 为一次孤立截图同步的结果，与 /history/screenshot/sync 的响应一致。
+type SyncResult = screenshotCleanupResult
+
+// SyncOrphans 与 /history/screenshot/sync 执行相同的同步与空目录清理，供 `go_bridge screenshots sync` 离线调用；
+// dryRun 为 true 时只预览，previewLimit 为 0 时使用默认预览条数。
+func SyncOrphans(ctx context.Context, db *sqlx.DB, screenshotDir string, dryRun bool, previewLimit int) (SyncResult, error) {
+	return syncScreenshotsAndPrune(ctx, db, screenshotDir, dryRun, normalizePreviewLimit(previewLimit))
+}
+
 // syncScreenshotsAndPrune 封装一次完整的同步 + 目录清理流程，避免
 // 在 handler 中展开过多逻辑，便于后续复用（例如定时任务）。
 func syncScreenshotsAndPrune(
@@ -0,0 +1,402 @@
+package sqlapi
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// userParam 为行级隔离注入的命名参数，始终由服务端赋值，客户端同名参数会被覆盖。
+const userParam = "bridge_user_id"
+
+var (
+	errIsolation = errors.New("statement not allowed for user-scoped caller")
+
+	identPattern       = `([A-Za-z0-9_.]+)`
+	tableRefPattern    = regexp.MustCompile(`(?i)\b(FROM|JOIN)(\s+)` + identPattern + `(\s+(?:AS\s+)?([A-Za-z_][A-Za-z0-9_]*))?`)
+	deleteHeadPattern  = regexp.MustCompile(`(?i)^\s*DELETE\s+FROM\s+` + identPattern)
+	updateHeadPattern  = regexp.MustCompile(`(?i)^\s*UPDATE\s+` + identPattern)
+	insertHeadPattern  = regexp.MustCompile(`(?i)^\s*INSERT\s+INTO\s+` + identPattern + `\s*\(([^()]*)\)\s*VALUES\s*\(`)
+	insertTablePattern = regexp.MustCompile(`(?i)^\s*INSERT\s+INTO\s+` + identPattern)
+	leadingWordPattern = regexp.MustCompile(`^\s*\(*\s*([A-Za-z]+)`)
+	tupleAssignPattern = regexp.MustCompile(`\(([^()]*)\)\s*=`)
+
+	// identQuoteReplacer 去掉 PostgreSQL/Oracle、MySQL 与 SQL Server 风格的标识符引号。
+	identQuoteReplacer = strings.NewReplacer(`"`, " ", "`", " ", "[", " ", "]", " ")
+)
+
+// aliasStopWords 为紧跟在表名之后、不能视为别名的关键字。
+var aliasStopWords = map[string]bool{
+	"where": true, "on": true, "join": true, "left": true, "right": true, "inner": true,
+	"outer": true, "full": true, "cross": true, "natural": true, "group": true, "order": true,
+	"limit": true, "offset": true, "union": true, "except": true, "intersect": true, "having": true,
+	"window": true, "fetch": true, "for": true, "using": true, "returning": true, "set": true,
+	"values": true, "as": true,
+}
+
+// userScope 把 SQL 改写为只能访问指定用户行的形式；无法安全改写的语句一律拒绝。
+type userScope struct {
+	tables map[string]bool
+	column string
+	userID int64
+}
+
+func newUserScope(tables []string, column string, userID int64) userScope {
+	set := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		set[strings.ToLower(t)] = true
+	}
+	return userScope{tables: set, column: column, userID: userID}
+}
+
+// isUserTable 判断表名（可带 schema 前缀）是否为按用户隔离的表。
+func (s userScope) isUserTable(name string) bool {
+	name = strings.ToLower(name)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return s.tables[name]
+}
+
+// predicate 返回注入的用户过滤条件。
+func (s userScope) predicate() string {
+	return fmt.Sprintf("%s = :%s", s.column, userParam)
+}
+
+// bind 把用户 id 写入命名参数。
+func (s userScope) bind(params map[string]any) map[string]any {
+	if params == nil {
+		params = map[string]any{}
+	}
+	params[userParam] = s.userID
+	return params
+}
+
+// rewrite 按语句类型改写 /sql/query 收到的原始 SQL（已完成 @ 占位符转换）：
+//   - SELECT/WITH：将每个隔离表引用替换为带用户过滤的子查询；
+//   - UPDATE/DELETE：追加用户过滤条件，并禁止 UPDATE 改写用户列；
+//   - INSERT：单行 VALUES 中强制用户列为调用方；
+//
+// 改写完成后会核对隔离表出现次数，任何未被识别的引用（如逗号连接、注释穿插）都会被拒绝。
+func (s userScope) rewrite(sqlText string) (string, error) {
+	leading := leadingWordPattern.FindStringSubmatch(sqlText)
+	if leading == nil {
+		return "", errIsolation
+	}
+	expected := s.countReferences(sqlText)
+
+	var (
+		out     string
+		handled int
+		err     error
+	)
+	switch strings.ToUpper(leading[1]) {
+	case "SELECT", "WITH":
+		out, handled = s.scopeReferences(sqlText)
+	case "DELETE":
+		out, handled, err = s.rewriteFiltered(sqlText, deleteHeadPattern, false)
+	case "UPDATE":
+		out, handled, err = s.rewriteFiltered(sqlText, updateHeadPattern, true)
+	case "INSERT":
+		out, handled, err = s.rewriteInsert(sqlText)
+	default:
+		out = sqlText
+	}
+	if err != nil {
+		return "", err
+	}
+	if handled != expected {
+		return "", fmt.Errorf("%w: unsupported reference to a user-scoped table", errIsolation)
+	}
+	return out, nil
+}
+
+// countReferences 统计隔离表名作为独立标识符出现的次数（排除 `表名.列名` 形式的列限定）。
+func (s userScope) countReferences(sqlText string) int {
+	count := 0
+	lower := asciiLower(sqlText)
+	for table := range s.tables {
+		for offset := 0; ; {
+			i := strings.Index(lower[offset:], table)
+			if i < 0 {
+				break
+			}
+			start := offset + i
+			end := start + len(table)
+			offset = end
+			if start > 0 && isIdentByte(lower[start-1]) {
+				continue
+			}
+			if end < len(lower) && (isIdentByte(lower[end]) || lower[end] == '.') {
+				continue
+			}
+			count++
+		}
+	}
+	return count
+}
+
+// scopeReferences 将 FROM/JOIN 后的隔离表替换为 `(SELECT * FROM t WHERE user_id = :bridge_user_id) 别名`。
+func (s userScope) scopeReferences(sqlText string) (string, int) {
+	handled := 0
+	out := tableRefPattern.ReplaceAllStringFunc(sqlText, func(match string) string {
+		groups := tableRefPattern.FindStringSubmatch(match)
+		keyword, space, table, aliasPart, alias := groups[1], groups[2], groups[3], groups[4], groups[5]
+		if !s.isUserTable(table) {
+			return match
+		}
+		handled++
+		suffix := ""
+		if alias == "" || aliasStopWords[strings.ToLower(alias)] {
+			suffix = aliasPart
+			alias = table
+			if i := strings.LastIndex(alias, "."); i >= 0 {
+				alias = alias[i+1:]
+			}
+		}
+		return fmt.Sprintf("%s%s(SELECT * FROM %s WHERE %s) %s%s", keyword, space, table, s.predicate(), alias, suffix)
+	})
+	return out, handled
+}
+
+// rewriteFiltered 处理 UPDATE/DELETE：目标表为隔离表时在顶层 WHERE 中追加用户条件。
+func (s userScope) rewriteFiltered(sqlText string, head *regexp.Regexp, isUpdate bool) (string, int, error) {
+	loc := head.FindStringSubmatchIndex(sqlText)
+	if loc == nil {
+		return "", 0, errIsolation
+	}
+	table := sqlText[loc[2]:loc[3]]
+	rest, handled := s.scopeReferences(sqlText[loc[1]:])
+	if !s.isUserTable(table) {
+		return sqlText[:loc[1]] + rest, handled, nil
+	}
+	handled++
+
+	if isUpdate {
+		setAt := topLevelKeyword(rest, "set")
+		if setAt < 0 {
+			return "", 0, errIsolation
+		}
+		setEnd := topLevelKeyword(rest, "where")
+		if setEnd < 0 {
+			setEnd = len(rest)
+		}
+		if assignsColumn(rest[setAt:setEnd], s.column) {
+			return "", 0, fmt.Errorf("%w: %s cannot be reassigned", errIsolation, s.column)
+		}
+	}
+	return sqlText[:loc[1]] + s.appendPredicate(rest), handled, nil
+}
+
+// appendPredicate 在顶层 WHERE 条件后追加用户条件，没有 WHERE 时补齐。
+func (s userScope) appendPredicate(rest string) string {
+	rest = strings.TrimRight(strings.TrimSpace(rest), ";")
+	tailAt := len(rest)
+	for _, kw := range []string{"returning", "order", "limit"} {
+		if i := topLevelKeyword(rest, kw); i >= 0 && i < tailAt {
+			tailAt = i
+		}
+	}
+	body, tail := strings.TrimSpace(rest[:tailAt]), rest[tailAt:]
+	if whereAt := topLevelKeyword(body, "where"); whereAt >= 0 {
+		cond := strings.TrimSpace(body[whereAt+len("where"):])
+		body = fmt.Sprintf("%s WHERE (%s) AND %s", strings.TrimSpace(body[:whereAt]), cond, s.predicate())
+	} else {
+		body = fmt.Sprintf("%s WHERE %s", body, s.predicate())
+	}
+	if tail != "" {
+		body += " " + tail
+	}
+	return " " + strings.TrimSpace(body)
+}
+
+// rewriteInsert 处理 INSERT：隔离表仅支持单行 `INSERT INTO t (cols) VALUES (...)`，并强制用户列取值。
+func (s userScope) rewriteInsert(sqlText string) (string, int, error) {
+	tableLoc := insertTablePattern.FindStringSubmatchIndex(sqlText)
+	if tableLoc == nil {
+		return "", 0, errIsolation
+	}
+	if !s.isUserTable(sqlText[tableLoc[2]:tableLoc[3]]) {
+		rest, handled := s.scopeReferences(sqlText[tableLoc[1]:])
+		return sqlText[:tableLoc[1]] + rest, handled, nil
+	}
+
+	loc := insertHeadPattern.FindStringSubmatchIndex(sqlText)
+	if loc == nil {
+		return "", 0, fmt.Errorf("%w: inserts into user-scoped tables need an explicit column list and VALUES", errIsolation)
+	}
+	table := sqlText[loc[2]:loc[3]]
+	columns := splitTopLevel(sqlText[loc[4]:loc[5]])
+	valuesEnd := matchingParen(sqlText, loc[1]-1)
+	if valuesEnd < 0 {
+		return "", 0, errIsolation
+	}
+	values := splitTopLevel(sqlText[loc[1]:valuesEnd])
+	if len(values) != len(columns) {
+		return "", 0, fmt.Errorf("%w: column and value counts differ", errIsolation)
+	}
+	tail := sqlText[valuesEnd+1:]
+	if strings.HasPrefix(strings.TrimSpace(tail), ",") {
+		return "", 0, fmt.Errorf("%w: multi-row inserts are not supported", errIsolation)
+	}
+	// ON CONFLICT ... DO UPDATE SET 与 MySQL 的 ON DUPLICATE KEY UPDATE 同样不能改写用户列。
+	for _, kw := range []string{"set", "update"} {
+		if i := topLevelKeyword(tail, kw); i >= 0 && assignsColumn(tail[i:], s.column) {
+			return "", 0, fmt.Errorf("%w: %s cannot be reassigned", errIsolation, s.column)
+		}
+	}
+	rest, handled := s.scopeReferences(tail)
+
+	found := false
+	for i, col := range columns {
+		if sameColumn(col, s.column) {
+			values[i] = " :" + userParam
+			found = true
+		}
+	}
+	if !found {
+		columns = append(columns, " "+s.column)
+		values = append(values, " :"+userParam)
+	}
+	out := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)%s",
+		table, strings.TrimSpace(strings.Join(columns, ",")), strings.TrimSpace(strings.Join(values, ",")), rest)
+	return out, handled + 1, nil
+}
+
+// scopeCondition 改写 /sql/update、/sql/delete 的 where 子句中对隔离表的子查询引用，
+// 并在条件末尾追加用户过滤。
+func (s userScope) scopeCondition(where string, targetScoped bool) (string, error) {
+	scoped, handled := s.scopeReferences(where)
+	if handled != s.countReferences(where) {
+		return "", fmt.Errorf("%w: unsupported reference to a user-scoped table", errIsolation)
+	}
+	if !targetScoped {
+		return scoped, nil
+	}
+	if strings.TrimSpace(scoped) == "" {
+		return s.predicate(), nil
+	}
+	return fmt.Sprintf("(%s) AND %s", scoped, s.predicate()), nil
+}
+
+// assignsColumn 判断 SET 子句是否给指定列赋值：先去掉 "col"、`col`、[col] 形式的标识符引号，
+// 再检查 col = 与 (a, col) = (...) 两种写法。
+func assignsColumn(setClause, column string) bool {
+	clause := identQuoteReplacer.Replace(setClause)
+	pattern := regexp.MustCompile(`(?i)(^|[^A-Za-z0-9_])` + regexp.QuoteMeta(column) + `\s*=`)
+	if pattern.MatchString(clause) {
+		return true
+	}
+	for _, m := range tupleAssignPattern.FindAllStringSubmatch(clause, -1) {
+		for _, item := range strings.Split(m[1], ",") {
+			if sameColumn(item, column) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameColumn 比较可能带引号或表名前缀的列名。
+func sameColumn(ident, column string) bool {
+	ident = strings.TrimSpace(identQuoteReplacer.Replace(ident))
+	if i := strings.LastIndex(ident, "."); i >= 0 {
+		ident = ident[i+1:]
+	}
+	return strings.EqualFold(strings.TrimSpace(ident), column)
+}
+
+// topLevelKeyword 返回关键字在括号与引号之外首次出现的位置，未找到返回 -1。
+func topLevelKeyword(s, keyword string) int {
+	lower := asciiLower(s)
+	depth := 0
+	var quote byte
+	for i := 0; i < len(lower); i++ {
+		ch := lower[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case depth == 0 && strings.HasPrefix(lower[i:], keyword):
+			end := i + len(keyword)
+			if (i == 0 || !isIdentByte(lower[i-1])) && (end == len(lower) || !isIdentByte(lower[end])) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitTopLevel 按括号与引号之外的逗号切分。
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+		case ch == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// matchingParen 返回 open 位置左括号对应的右括号位置。
+func matchingParen(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '(':
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// asciiLower 仅转换 ASCII 字母，保证与原文的字节偏移一致。
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, ch := range b {
+		if ch >= 'A' && ch <= 'Z' {
+			b[i] = ch + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,87 @@
+package sqlapi
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func testScope() userScope {
+	return newUserScope([]string{"t_historical_records", "t_favorite_directories"}, "user_id", 7)
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+func squash(s string) string {
+	return strings.TrimSpace(spaces.ReplaceAllString(s, " "))
+}
+
+func TestUserScopeRewrite(t *testing.T) {
+	cases := []struct {
+		name, in, want string
+	}{
+		{
+			name: "select",
+			in:   "SELECT * FROM t_historical_records WHERE video_sha1 = :sha1",
+			want: "SELECT * FROM (SELECT * FROM t_historical_records WHERE user_id = :bridge_user_id) t_historical_records WHERE video_sha1 = :sha1",
+		},
+		{
+			name: "select with alias and join",
+			in:   "SELECT h.video_name FROM t_historical_records AS h JOIN t_favorite_directories f ON f.path = h.video_path",
+			want: "SELECT h.video_name FROM (SELECT * FROM t_historical_records WHERE user_id = :bridge_user_id) h JOIN (SELECT * FROM t_favorite_directories WHERE user_id = :bridge_user_id) f ON f.path = h.video_path",
+		},
+		{
+			name: "delete",
+			in:   "DELETE FROM t_historical_records WHERE user_id = :userId",
+			want: "DELETE FROM t_historical_records WHERE (user_id = :userId) AND user_id = :bridge_user_id",
+		},
+		{
+			name: "update without where",
+			in:   "UPDATE t_favorite_directories SET name = :name",
+			want: "UPDATE t_favorite_directories SET name = :name WHERE user_id = :bridge_user_id",
+		},
+		{
+			name: "upsert pins user column",
+			in: `INSERT INTO t_historical_records (video_sha1, user_id, video_seek) VALUES (:sha1, :userId, :seek)
+				ON CONFLICT (video_sha1, user_id) DO UPDATE SET video_seek = :seek`,
+			want: "INSERT INTO t_historical_records (video_sha1, user_id, video_seek) VALUES (:sha1, :bridge_user_id, :seek) ON CONFLICT (video_sha1, user_id) DO UPDATE SET video_seek = :seek",
+		},
+		{
+			name: "unrelated table untouched",
+			in:   "SELECT COUNT(*) FROM t_other",
+			want: "SELECT COUNT(*) FROM t_other",
+		},
+	}
+	for _, tc := range cases {
+		got, err := testScope().rewrite(tc.in)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tc.name, err)
+		}
+		if squash(got) != tc.want {
+			t.Fatalf("%s:\n got %s\nwant %s", tc.name, squash(got), tc.want)
+		}
+	}
+}
+
+func TestUserScopeRejectsUnsafeStatements(t *testing.T) {
+	for _, in := range []string{
+		"SELECT * FROM t_other, t_historical_records",
+		"SELECT * FROM/**/t_historical_records",
+		"UPDATE t_historical_records SET user_id = :other WHERE user_id = :mine",
+		`UPDATE t_historical_records SET "user_id" = 2 WHERE id = 1`,
+		"UPDATE t_historical_records SET `user_id` = 2 WHERE id = 1",
+		"UPDATE t_historical_records SET [user_id] = 2 WHERE id = 1",
+		"UPDATE t_historical_records SET (user_id, title) = (2, 'x') WHERE id = 1",
+		`UPDATE t_historical_records SET (title, "user_id") = (SELECT 'x', 2) WHERE id = 1`,
+		`INSERT INTO t_historical_records (video_sha1) VALUES (:a) ON CONFLICT (video_sha1) DO UPDATE SET "user_id" = 2`,
+		"INSERT INTO t_historical_records (video_sha1) VALUES (:a) ON DUPLICATE KEY UPDATE `user_id` = 2",
+		"INSERT INTO t_historical_records (video_sha1, user_id) VALUES (:a, 1), (:b, 2)",
+		"INSERT INTO t_historical_records SELECT * FROM t_backup",
+		"DROP TABLE t_historical_records",
+	} {
+		if _, err := testScope().rewrite(in); !errors.Is(err, errIsolation) {
+			t.Fatalf("expected rejection for %q, got %v", in, err)
+		}
+	}
+}
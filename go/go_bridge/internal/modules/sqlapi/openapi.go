@@ -0,0 +1,21 @@
+package sqlapi
+
+import (
+	"net/http"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/openapi"
+)
+
+// APIRoutes 描述 SQL API，与 Register 挂载的路由保持一致。
+func (r *Registrar) APIRoutes() []openapi.Route {
+	if r.DB == nil {
+		return nil
+	}
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/health", Tag: "sql", Summary: "数据库连通性检查，失败时返回 503", Response: healthResponse{}},
+		{Method: http.MethodPost, Path: "/sql/query", Tag: "sql", Summary: "执行查询，参数使用 @name 占位符；非只读语句需要 sql:write", Request: sqlRequest{}, Response: queryResponse{}},
+		{Method: http.MethodPost, Path: "/sql/insert", Tag: "sql", Summary: "插入单行", Request: insertRequest{}, Response: insertResponse{}},
+		{Method: http.MethodPost, Path: "/sql/update", Tag: "sql", Summary: "按条件更新", Request: updateRequest{}, Response: affectedResponse{}},
+		{Method: http.MethodPost, Path: "/sql/delete", Tag: "sql", Summary: "按条件删除", Request: deleteRequest{}, Response: affectedResponse{}},
+	}
+}
@@ -2,6 +2,7 @@ package sqlapi
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
@@ -12,18 +13,33 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/audit"
 	"github.com/zhouquan/webdav_video/go_bridge/internal/server/httpjson"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
 )
 
 var placeholderPattern = regexp.MustCompile(`@([a-zA-Z0-9_]+)`)
 
 // Registrar 提供 /health 与 SQL CRUD 接口，供完整模式复用。
 type Registrar struct {
-	DB *sqlx.DB
+	DB        *sqlx.DB
+	Isolation appconfig.Isolation
+	// redact 去除驱动错误中可能回显的 DSN 与密码。
+	redact func(string) string
 }
 
-func NewRegistrar(db *sqlx.DB) *Registrar {
-	return &Registrar{DB: db}
+func NewRegistrar(db *sqlx.DB, cfg appconfig.Config) *Registrar {
+	return &Registrar{DB: db, Isolation: cfg.Isolation, redact: cfg.Redact}
+}
+
+// userScope 返回当前调用方的行级隔离规则；管理员或未绑定用户的身份返回 ok=false。
+func (r *Registrar) userScope(c *gin.Context) (userScope, bool) {
+	userID, ok := identity.RestrictedUser(c)
+	if !ok {
+		return userScope{}, false
+	}
+	return newUserScope(r.Isolation.UserTables, r.Isolation.UserColumn, userID), true
 }
 
 // Register 挂载 SQL API，需要数据库句柄才能工作。
@@ -36,10 +52,14 @@ func (r *Registrar) Register(engine *gin.Engine) {
 		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
 		defer cancel()
 		if err := r.DB.PingContext(ctx); err != nil {
-			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "error", "details": err.Error()})
+			details := err.Error()
+			if r.redact != nil {
+				details = r.redact(details)
+			}
+			c.JSON(http.StatusServiceUnavailable, healthResponse{Status: "error", Details: details})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		c.JSON(http.StatusOK, healthResponse{Status: "ok"})
 	})
 
 	engine.POST("/sql/query", func(c *gin.Context) {
@@ -52,7 +72,29 @@ func (r *Registrar) Register(engine *gin.Engine) {
 			return
 		}
 		normalizedSQL, _ := convertPlaceholders(req.SQL, "")
-		rows, err := r.DB.NamedQueryContext(c.Request.Context(), normalizedSQL, req.Parameters)
+		params := req.Parameters
+		// 非只读语句同样可能修改数据：按写操作审计（where 字段记录完整语句），且要求调用方具备 sql:write。
+		var entry *audit.Entry
+		if !readOnlyStatement(normalizedSQL) {
+			entry = &audit.Entry{Where: req.SQL, Args: req.Parameters}
+			if id, ok := identity.From(c); ok && !id.HasScope(appconfig.ScopeSQLWrite) {
+				r.recordDenied(c, entry, errWriteScope)
+				c.JSON(http.StatusForbidden, gin.H{"error": errWriteScope.Error()})
+				return
+			}
+		}
+		if scope, ok := r.userScope(c); ok {
+			rewritten, err := scope.rewrite(normalizedSQL)
+			if err != nil {
+				r.recordDenied(c, entry, err)
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			normalizedSQL = rewritten
+			params = scope.bind(params)
+		}
+		rows, err := r.DB.NamedQueryContext(c.Request.Context(), normalizedSQL, params)
+		r.recordExec(c, entry, nil, err)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
@@ -72,7 +114,7 @@ func (r *Registrar) Register(engine *gin.Engine) {
 			}
 			result = append(result, row)
 		}
-		c.JSON(http.StatusOK, gin.H{"rows": result})
+		c.JSON(http.StatusOK, queryResponse{Rows: result})
 	})
 
 	engine.POST("/sql/insert", func(c *gin.Context) {
@@ -89,6 +131,15 @@ func (r *Registrar) Register(engine *gin.Engine) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "values is required"})
 			return
 		}
+		if scope, ok := r.userScope(c); ok && scope.isUserTable(table) {
+			// 隔离表的用户列始终取调用方身份，忽略客户端传入的值。
+			for k := range req.Values {
+				if strings.EqualFold(strings.TrimSpace(k), scope.column) {
+					delete(req.Values, k)
+				}
+			}
+			req.Values[scope.column] = scope.userID
+		}
 		columns := make([]string, 0, len(req.Values))
 		placeholders := make([]string, 0, len(req.Values))
 		namedValues := make(map[string]any, len(req.Values))
@@ -105,13 +156,13 @@ func (r *Registrar) Register(engine *gin.Engine) {
 		}
 		query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ","), strings.Join(placeholders, ","))
 		res, err := r.DB.NamedExecContext(c.Request.Context(), query, namedValues)
+		rowsAffected := r.recordExec(c, &audit.Entry{Table: table, Args: namedValues}, res, err)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 		lastID, _ := res.LastInsertId()
-		rowsAffected, _ := res.RowsAffected()
-		c.JSON(http.StatusOK, gin.H{"lastInsertId": lastID, "rowsAffected": rowsAffected})
+		c.JSON(http.StatusOK, insertResponse{LastInsertID: lastID, RowsAffected: rowsAffected})
 	})
 
 	engine.POST("/sql/update", func(c *gin.Context) {
@@ -148,14 +199,29 @@ func (r *Registrar) Register(engine *gin.Engine) {
 		for k, v := range convertedArgs {
 			params[k] = v
 		}
+		entry := &audit.Entry{Table: table, Where: req.Where, Args: params}
+		if scope, ok := r.userScope(c); ok {
+			if scope.isUserTable(table) && assignsValue(req.Values, scope.column) {
+				r.recordDenied(c, entry, errors.New(scope.column+" cannot be reassigned"))
+				c.JSON(http.StatusForbidden, gin.H{"error": scope.column + " cannot be reassigned"})
+				return
+			}
+			if whereClause, err = scope.scopeCondition(whereClause, scope.isUserTable(table)); err != nil {
+				r.recordDenied(c, entry, err)
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			scope.bind(params)
+			entry.Where = whereClause
+		}
 		query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", table, strings.Join(setParts, ","), whereClause)
 		res, err := r.DB.NamedExecContext(c.Request.Context(), query, params)
+		rowsAffected := r.recordExec(c, entry, res, err)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		rowsAffected, _ := res.RowsAffected()
-		c.JSON(http.StatusOK, gin.H{"affectedRows": rowsAffected})
+		c.JSON(http.StatusOK, affectedResponse{AffectedRows: rowsAffected})
 	})
 
 	engine.POST("/sql/delete", func(c *gin.Context) {
@@ -173,20 +239,117 @@ func (r *Registrar) Register(engine *gin.Engine) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		entry := &audit.Entry{Table: table, Where: req.Where, Args: convertedArgs}
+		if scope, ok := r.userScope(c); ok {
+			if whereClause, err = scope.scopeCondition(whereClause, scope.isUserTable(table)); err != nil {
+				r.recordDenied(c, entry, err)
+				c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+				return
+			}
+			convertedArgs = scope.bind(convertedArgs)
+			entry.Where, entry.Args = whereClause, convertedArgs
+		}
 		query := fmt.Sprintf("DELETE FROM %s WHERE %s", table, whereClause)
 		res, err := r.DB.NamedExecContext(c.Request.Context(), query, convertedArgs)
+		rowsAffected := r.recordExec(c, entry, res, err)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		rowsAffected, _ := res.RowsAffected()
-		c.JSON(http.StatusOK, gin.H{"affectedRows": rowsAffected})
+		c.JSON(http.StatusOK, affectedResponse{AffectedRows: rowsAffected})
 	})
 }
 
+// recordExec 记录写操作的执行结果并返回影响行数；entry 为 nil 表示该请求无需审计。
+func (r *Registrar) recordExec(c *gin.Context, entry *audit.Entry, res sql.Result, err error) int64 {
+	var affected int64
+	if err == nil && res != nil {
+		affected, _ = res.RowsAffected()
+	}
+	if entry == nil {
+		return affected
+	}
+	entry.AffectedRows = affected
+	entry.Outcome = audit.OutcomeOK
+	if err != nil {
+		entry.Outcome = audit.OutcomeError
+		entry.Error = r.redactError(err)
+	}
+	audit.Record(c, *entry)
+	return affected
+}
+
+// recordDenied 记录被行级隔离规则拒绝的写操作。
+func (r *Registrar) recordDenied(c *gin.Context, entry *audit.Entry, err error) {
+	if entry == nil {
+		return
+	}
+	entry.Outcome = audit.OutcomeDenied
+	entry.Error = r.redactError(err)
+	audit.Record(c, *entry)
+}
+
+func (r *Registrar) redactError(err error) string {
+	if r.redact == nil {
+		return err.Error()
+	}
+	return r.redact(err.Error())
+}
+
+// errWriteScope 为仅有 sql:read 的调用方通过 /sql/query 提交非只读语句时的错误。
+var errWriteScope = errors.New("token lacks scope " + appconfig.ScopeSQLWrite + " required for non-SELECT statements")
+
+// writeKeywordPattern 匹配会修改数据或结构的关键字，用于识别 `WITH ... DELETE`、`SELECT ... INTO`、`FOR UPDATE` 等写法。
+var writeKeywordPattern = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE|UPSERT|INTO|DROP|ALTER|CREATE|TRUNCATE|RENAME|GRANT|REVOKE|CALL|EXEC|EXECUTE|COPY|LOCK|SET)\b`)
+
+// readOnlyStatement 判断 /sql/query 的语句是否为只读查询：以 SELECT/WITH 开头、只有一条语句，
+// 且在字符串与注释之外不含写操作关键字。判断从严，宁可将少数只读语句按写操作处理。
+func readOnlyStatement(sqlText string) bool {
+	leading := leadingWordPattern.FindStringSubmatch(sqlText)
+	if leading == nil {
+		return false
+	}
+	switch strings.ToUpper(leading[1]) {
+	case "SELECT", "WITH":
+	default:
+		return false
+	}
+	code := strings.TrimSpace(stripLiterals(sqlText))
+	code = strings.TrimSpace(strings.TrimSuffix(code, ";"))
+	return !strings.Contains(code, ";") && !writeKeywordPattern.MatchString(code)
+}
+
+// stripLiterals 将引号内的字符串、标识符与注释替换为空格，只保留语句结构。
+func stripLiterals(sqlText string) string {
+	out := []byte(sqlText)
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '\'' || out[i] == '"' || out[i] == '`':
+			quote := out[i]
+			for i++; i < len(out) && out[i] != quote; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '-' && i+1 < len(out) && out[i+1] == '-':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := strings.Index(string(out[i+2:]), "*/")
+			if end < 0 {
+				end = len(out) - i - 2
+			}
+			for j := i; j < i+2+end && j < len(out); j++ {
+				out[j] = ' '
+			}
+			i += 2 + end
+		}
+	}
+	return string(out)
+}
+
 type sqlRequest struct {
 	SQL        string         `json:"sql"`
-	Parameters map[string]any `json:"parameters"`
+	Parameters map[string]any `json:"parameters" openapi:"optional"`
 }
 
 type insertRequest struct {
@@ -198,13 +361,31 @@ type updateRequest struct {
 	Table    string         `json:"table"`
 	Values   map[string]any `json:"values"`
 	Where    string         `json:"where"`
-	WhereArg map[string]any `json:"whereArgs"`
+	WhereArg map[string]any `json:"whereArgs" openapi:"optional"`
 }
 
 type deleteRequest struct {
 	Table    string         `json:"table"`
 	Where    string         `json:"where"`
-	WhereArg map[string]any `json:"whereArgs"`
+	WhereArg map[string]any `json:"whereArgs" openapi:"optional"`
+}
+
+type healthResponse struct {
+	Status  string `json:"status"`
+	Details string `json:"details,omitempty"`
+}
+
+type queryResponse struct {
+	Rows []map[string]any `json:"rows"`
+}
+
+type insertResponse struct {
+	LastInsertID int64 `json:"lastInsertId"`
+	RowsAffected int64 `json:"rowsAffected"`
+}
+
+type affectedResponse struct {
+	AffectedRows int64 `json:"affectedRows"`
 }
 
 func convertPlaceholders(sqlText, prefix string) (string, []string) {
@@ -232,6 +413,16 @@ func convertNamed(whereClause string, prefix string, args map[string]any) (strin
 	return converted, convertedArgs, nil
 }
 
+// assignsValue 判断写入的列集合中是否包含指定列（忽略大小写）。
+func assignsValue(values map[string]any, column string) bool {
+	for k := range values {
+		if strings.EqualFold(strings.TrimSpace(k), column) {
+			return true
+		}
+	}
+	return false
+}
+
 func sanitizeIdentifier(value string) (string, error) {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
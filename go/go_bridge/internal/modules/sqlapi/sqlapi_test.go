@@ -0,0 +1,83 @@
+package sqlapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+func TestReadOnlyStatement(t *testing.T) {
+	for sqlText, want := range map[string]bool{
+		"SELECT * FROM t_user WHERE name = 'DELETE; x'":                  true,
+		"select id from t_user -- update later\n":                        true,
+		"WITH recent AS (SELECT * FROM t_user) SELECT * FROM recent;":    true,
+		"SELECT REPLACE(name, 'a', 'b') FROM t_user ORDER BY 1 OFFSET 2": true,
+		"DELETE FROM t_user":          false,
+		"SELECT 1; DROP TABLE t_user": false,
+		"WITH gone AS (DELETE FROM t_user RETURNING id) SELECT * FROM gone": false,
+		"SELECT * INTO t_copy FROM t_user":                                  false,
+		"SELECT * FROM t_user FOR UPDATE":                                   false,
+		"/* SELECT */ UPDATE t_user SET name = 'x'":                         false,
+	} {
+		if got := readOnlyStatement(sqlText); got != want {
+			t.Errorf("readOnlyStatement(%q) = %v, want %v", sqlText, got, want)
+		}
+	}
+}
+
+func TestQueryRequiresWriteScopeForNonSelect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	t.Cleanup(func() { _ = sqlxDB.Close() })
+
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		scopes := strings.Split(c.GetHeader("X-Test-Scopes"), ",")
+		identity.Set(c, identity.Identity{Name: "caller", Scopes: scopes})
+	})
+	NewRegistrar(sqlxDB, appconfig.Config{}).Register(engine)
+	send := func(scopes, sqlText string) *httptest.ResponseRecorder {
+		body := `{"sql":` + strings.ReplaceAll(`"`+sqlText+`"`, "\n", `\n`) + `}`
+		req := httptest.NewRequest(http.MethodPost, "/sql/query", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Scopes", scopes)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, stmt := range []string{
+		"DELETE FROM t_user",
+		"SELECT 1; DROP TABLE t_user",
+		"WITH gone AS (DELETE FROM t_user RETURNING id) SELECT * FROM gone",
+	} {
+		w := send(appconfig.ScopeSQLRead, stmt)
+		if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), appconfig.ScopeSQLWrite) {
+			t.Fatalf("read-only token ran %q: %d %s", stmt, w.Code, w.Body.String())
+		}
+	}
+
+	mock.ExpectQuery(`SELECT id FROM t_user`).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	if w := send(appconfig.ScopeSQLRead, "SELECT id FROM t_user"); w.Code != http.StatusOK {
+		t.Fatalf("read-only token select: %d %s", w.Code, w.Body.String())
+	}
+	mock.ExpectQuery(`DELETE FROM t_user`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	if w := send(appconfig.ScopeSQLRead+","+appconfig.ScopeSQLWrite, "DELETE FROM t_user"); w.Code != http.StatusOK {
+		t.Fatalf("write token delete: %d %s", w.Code, w.Body.String())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unexpected queries: %v", err)
+	}
+}
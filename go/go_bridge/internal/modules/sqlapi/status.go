@@ -0,0 +1,37 @@
+package sqlapi
+
+import "context"
+
+// dbStatus 为 /admin/status 中的数据库驱动与连接池计数（database/sql 的 DBStats）。
+type dbStatus struct {
+	Driver             string `json:"driver"`
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDurationMs     int64  `json:"waitDurationMs"`
+	MaxIdleClosed      int64  `json:"maxIdleClosed"`
+	MaxIdleTimeClosed  int64  `json:"maxIdleTimeClosed"`
+	MaxLifetimeClosed  int64  `json:"maxLifetimeClosed"`
+}
+
+// Status 返回数据库驱动与连接池计数，不发起查询。
+func (r *Registrar) Status(context.Context) any {
+	if r.DB == nil {
+		return nil
+	}
+	stats := r.DB.Stats()
+	return dbStatus{
+		Driver:             r.DB.DriverName(),
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDurationMs:     stats.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
@@ -0,0 +1,199 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+// Registrar 负责代理流量计量、月度配额拦截与 /admin/usage 查询接口。
+type Registrar struct {
+	Tracker *Tracker
+}
+
+// NewRegistrar 创建计量模块；db 为 nil 时（仅代理模式）统计只保存在内存中。
+func NewRegistrar(db *sqlx.DB, cfg appconfig.Config) (*Registrar, error) {
+	quotas, err := quotasFromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var st *store
+	if db != nil {
+		if st, err = newStore(ctx, db); err != nil {
+			return nil, err
+		}
+	}
+	tracker := newTracker(st, quotas, cfg.Usage.FlushIntervalDuration())
+	if err := tracker.load(ctx); err != nil {
+		return nil, err
+	}
+	tracker.start()
+	return &Registrar{Tracker: tracker}, nil
+}
+
+// Reload 在配置热更新后替换月度配额，已累计的用量保持不变。
+func (r *Registrar) Reload(cfg appconfig.Config) error {
+	quotas, err := quotasFromConfig(cfg)
+	if err != nil {
+		return err
+	}
+	r.Tracker.setQuotas(quotas)
+	return nil
+}
+
+func quotasFromConfig(cfg appconfig.Config) (map[string]Quota, error) {
+	quotas := map[string]Quota{}
+	for _, q := range cfg.Usage.Quotas {
+		bytes, err := appconfig.ParseByteSize(q.MonthlyBytes)
+		if err != nil {
+			return nil, fmt.Errorf("usage quota %s: %w", q.Identity, err)
+		}
+		quotas[q.Identity] = Quota{MonthlyBytes: bytes, MonthlyRequests: q.MonthlyRequests}
+	}
+	return quotas, nil
+}
+
+// Close 停止后台落库并写入剩余增量。
+func (r *Registrar) Close(ctx context.Context) error {
+	if r == nil || r.Tracker == nil {
+		return nil
+	}
+	return r.Tracker.Close(ctx)
+}
+
+// Middleware 统计 /proxy/ 下各接口的响应字节与请求次数，并在超出月度配额时返回 429。
+func (r *Registrar) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !metered(c.Request) {
+			c.Next()
+			return
+		}
+		name := identity.Name(c)
+		if exceeded, reason := r.Tracker.Exceeded(name); exceeded {
+			c.Header("Retry-After", strconv.Itoa(secondsUntilNextMonth(r.Tracker.now())))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": reason})
+			return
+		}
+		host := targetHost(c.Query("target"))
+		c.Next()
+		r.Tracker.Record(name, host, int64(c.Writer.Size()))
+	}
+}
+
+// Register 挂载 /admin/usage，支持 period=day|month 与 date（2006-01-02 或 2006-01）参数。
+func (r *Registrar) Register(engine *gin.Engine) {
+	if engine == nil || r.Tracker == nil {
+		return
+	}
+	engine.GET("/admin/usage", func(c *gin.Context) {
+		periodType := strings.ToLower(strings.TrimSpace(c.DefaultQuery("period", periodMonth)))
+		now := r.Tracker.now().UTC()
+		var layout string
+		switch periodType {
+		case periodDay:
+			layout = dayLayout
+		case periodMonth:
+			layout = monthLayout
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "period must be day or month"})
+			return
+		}
+		period := strings.TrimSpace(c.Query("date"))
+		if period == "" {
+			period = now.Format(layout)
+		} else if _, err := time.Parse(layout, period); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("date must match %s", layout)})
+			return
+		}
+
+		rows, err := r.Tracker.Query(c.Request.Context(), periodType, period)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if rows == nil {
+			rows = []Row{}
+		}
+
+		identities := map[string]*identitySummary{}
+		for _, row := range rows {
+			summary, ok := identities[row.Identity]
+			if !ok {
+				summary = &identitySummary{}
+				if quota, ok := r.Tracker.quotaFor(row.Identity); ok {
+					summary.Quota = &quotaSummary{
+						MonthlyBytes:    quota.MonthlyBytes,
+						MonthlyRequests: quota.MonthlyRequests,
+						Month:           r.Tracker.monthTotal(row.Identity),
+					}
+				}
+				identities[row.Identity] = summary
+			}
+			summary.add(row.Counter)
+		}
+
+		c.JSON(http.StatusOK, usageResponse{
+			Period:     periodType,
+			Date:       period,
+			Identities: identities,
+			Rows:       rows,
+		})
+	})
+}
+
+// usageResponse 为 /admin/usage 的响应：按身份汇总的用量与明细行。
+type usageResponse struct {
+	Period     string                      `json:"period"`
+	Date       string                      `json:"date"`
+	Identities map[string]*identitySummary `json:"identities"`
+	Rows       []Row                       `json:"rows"`
+}
+
+// identitySummary 为单个身份在查询周期内的合计，配置了配额时附带本月累计。
+type identitySummary struct {
+	Counter
+	Quota *quotaSummary `json:"quota,omitempty"`
+}
+
+type quotaSummary struct {
+	MonthlyBytes    int64   `json:"monthlyBytes"`
+	MonthlyRequests int64   `json:"monthlyRequests"`
+	Month           Counter `json:"month"`
+}
+
+// metered 判断请求是否计入流量：仅统计代理接口，排除预检与指标查询。
+func metered(req *http.Request) bool {
+	if req.Method == http.MethodOptions {
+		return false
+	}
+	path := req.URL.Path
+	return strings.HasPrefix(path, "/proxy/") && path != "/proxy/metrics"
+}
+
+// targetHost 从 target 参数中提取目标主机，无法解析时归为 unknown。
+func targetHost(target string) string {
+	parsed, err := url.Parse(strings.TrimSpace(target))
+	if err != nil || parsed.Hostname() == "" {
+		return "unknown"
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+func secondsUntilNextMonth(now time.Time) int {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return int(next.Sub(now).Seconds()) + 1
+}
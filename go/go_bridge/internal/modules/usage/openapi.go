@@ -0,0 +1,22 @@
+package usage
+
+import (
+	"net/http"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/openapi"
+)
+
+// APIRoutes 描述 /admin/usage 查询接口。
+func (r *Registrar) APIRoutes() []openapi.Route {
+	if r.Tracker == nil {
+		return nil
+	}
+	return []openapi.Route{{
+		Method: http.MethodGet, Path: "/admin/usage", Tag: "admin",
+		Summary: "按身份与目标主机汇总代理流量", Response: usageResponse{},
+		Query: []openapi.Parameter{
+			openapi.QueryParam("period", "string", false, "day 或 month，默认 month"),
+			openapi.QueryParam("date", "string", false, "2006-01-02 或 2006-01，默认当前周期"),
+		},
+	}}
+}
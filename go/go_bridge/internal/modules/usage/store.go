@@ -0,0 +1,134 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/database"
+)
+
+const usageTable = "t_bridge_usage"
+
+// usageDDL 为三种驱动的建表语句，与 db/migrations/init.sql 中的 t_bridge_usage 保持一致。
+var usageDDL = database.TableDDL{
+	"postgres": {
+		`CREATE TABLE IF NOT EXISTS t_bridge_usage (
+			identity VARCHAR(128) NOT NULL,
+			target_host VARCHAR(255) NOT NULL,
+			period_type VARCHAR(8) NOT NULL,
+			period VARCHAR(16) NOT NULL,
+			bytes BIGINT NOT NULL DEFAULT 0,
+			requests BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (identity, target_host, period_type, period)
+		)`,
+	},
+	"mysql": {
+		`CREATE TABLE IF NOT EXISTS t_bridge_usage (
+			identity VARCHAR(128) NOT NULL,
+			target_host VARCHAR(255) NOT NULL,
+			period_type VARCHAR(8) NOT NULL,
+			period VARCHAR(16) NOT NULL,
+			bytes BIGINT NOT NULL DEFAULT 0,
+			requests BIGINT NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (identity, target_host, period_type, period)
+		)`,
+	},
+	"oracle": {
+		`CREATE TABLE t_bridge_usage (
+			identity VARCHAR2(128) NOT NULL,
+			target_host VARCHAR2(255) NOT NULL,
+			period_type VARCHAR2(8) NOT NULL,
+			period VARCHAR2(16) NOT NULL,
+			bytes NUMBER(19) DEFAULT 0 NOT NULL,
+			requests NUMBER(19) DEFAULT 0 NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+			CONSTRAINT pk_bridge_usage PRIMARY KEY (identity, target_host, period_type, period)
+		)`,
+	},
+}
+
+// store 负责 t_bridge_usage 的增量累加与查询，SQL 仅使用三种驱动共同支持的语法。
+type store struct {
+	db *sqlx.DB
+}
+
+func newStore(ctx context.Context, db *sqlx.DB) (*store, error) {
+	if err := Migrate(ctx, db); err != nil {
+		return nil, err
+	}
+	return &store{db: db}, nil
+}
+
+// Migrate 在流量计量表不存在时按驱动建表，供启动与 `go_bridge migrate` 共用。
+func Migrate(ctx context.Context, db *sqlx.DB) error {
+	return database.EnsureTable(ctx, db, usageTable, usageDDL)
+}
+
+// add 先尝试累加已有行，未命中时插入新行。
+func (s *store) add(ctx context.Context, key usageKey, delta Counter) error {
+	now := time.Now().UTC()
+	res, err := s.db.ExecContext(ctx, s.db.Rebind(`UPDATE t_bridge_usage
+		SET bytes = bytes + ?, requests = requests + ?, updated_at = ?
+		WHERE identity = ? AND target_host = ? AND period_type = ? AND period = ?`),
+		delta.Bytes, delta.Requests, now, key.Identity, key.Host, key.PeriodType, key.Period)
+	if err != nil {
+		return fmt.Errorf("update usage: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected > 0 {
+		return nil
+	}
+	_, err = s.db.ExecContext(ctx, s.db.Rebind(`INSERT INTO t_bridge_usage
+		(identity, target_host, period_type, period, bytes, requests, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		key.Identity, key.Host, key.PeriodType, key.Period, delta.Bytes, delta.Requests, now)
+	if err != nil {
+		return fmt.Errorf("insert usage: %w", err)
+	}
+	return nil
+}
+
+// monthTotals 汇总指定月份各身份的用量，用于启动时恢复配额状态。
+func (s *store) monthTotals(ctx context.Context, month string) (map[string]Counter, error) {
+	rows, err := s.db.QueryxContext(ctx, s.db.Rebind(`SELECT identity, SUM(bytes), SUM(requests)
+		FROM t_bridge_usage WHERE period_type = ? AND period = ? GROUP BY identity`), periodMonth, month)
+	if err != nil {
+		return nil, fmt.Errorf("query usage totals: %w", err)
+	}
+	defer rows.Close()
+
+	totals := map[string]Counter{}
+	for rows.Next() {
+		var identity string
+		var counter Counter
+		if err := rows.Scan(&identity, &counter.Bytes, &counter.Requests); err != nil {
+			return nil, fmt.Errorf("scan usage totals: %w", err)
+		}
+		totals[identity] = counter
+	}
+	return totals, rows.Err()
+}
+
+// query 返回指定周期内按身份与目标主机拆分的用量。
+func (s *store) query(ctx context.Context, periodType, period string) ([]Row, error) {
+	rows, err := s.db.QueryxContext(ctx, s.db.Rebind(`SELECT identity, target_host, bytes, requests
+		FROM t_bridge_usage WHERE period_type = ? AND period = ?`), periodType, period)
+	if err != nil {
+		return nil, fmt.Errorf("query usage: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Row
+	for rows.Next() {
+		row := Row{PeriodType: periodType, Period: period}
+		if err := rows.Scan(&row.Identity, &row.Host, &row.Bytes, &row.Requests); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
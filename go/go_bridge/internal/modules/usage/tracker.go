@@ -0,0 +1,316 @@
+package usage
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	periodDay   = "day"
+	periodMonth = "month"
+
+	dayLayout   = "2006-01-02"
+	monthLayout = "2006-01"
+)
+
+// Quota 描述单个身份的月度限额，零值表示不限制。
+type Quota struct {
+	MonthlyBytes    int64
+	MonthlyRequests int64
+}
+
+// usageKey 唯一标识一条计量记录：身份 + 目标主机 + 周期。
+type usageKey struct {
+	Identity   string
+	Host       string
+	PeriodType string
+	Period     string
+}
+
+// Counter 为一条计量记录的累计值。
+type Counter struct {
+	Bytes    int64 `json:"bytes"`
+	Requests int64 `json:"requests"`
+}
+
+func (c *Counter) add(other Counter) {
+	c.Bytes += other.Bytes
+	c.Requests += other.Requests
+}
+
+// Row 为 /admin/usage 返回的单行统计。
+type Row struct {
+	Identity   string `json:"identity"`
+	Host       string `json:"host"`
+	PeriodType string `json:"periodType"`
+	Period     string `json:"period"`
+	Counter
+}
+
+// Tracker 在内存中汇总各身份的代理流量，并按 flushInterval 将增量写入数据库；
+// 未配置数据库（仅代理模式）时只在内存中保留当日与当月统计，重启后清零。
+type Tracker struct {
+	mu       sync.Mutex
+	store    *store
+	quotas   map[string]Quota
+	interval time.Duration
+	now      func() time.Time
+
+	// pending 为尚未落库的增量；memory 为仅内存模式下的全量统计。
+	pending map[usageKey]*Counter
+	memory  map[usageKey]*Counter
+	// month 与 monthTotals 用于配额判断：当前自然月各身份的累计用量。
+	month       string
+	monthTotals map[string]*Counter
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	doneCh   chan struct{}
+}
+
+// newTracker 创建流量计量器；st 为 nil 时仅使用内存。
+func newTracker(st *store, quotas map[string]Quota, interval time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	if quotas == nil {
+		quotas = map[string]Quota{}
+	}
+	return &Tracker{
+		store:       st,
+		quotas:      quotas,
+		interval:    interval,
+		now:         time.Now,
+		pending:     map[usageKey]*Counter{},
+		memory:      map[usageKey]*Counter{},
+		monthTotals: map[string]*Counter{},
+		stopCh:      make(chan struct{}),
+		doneCh:      make(chan struct{}),
+	}
+}
+
+// load 从数据库恢复本月各身份累计值，保证重启后配额依旧生效。
+func (t *Tracker) load(ctx context.Context) error {
+	month := t.now().UTC().Format(monthLayout)
+	t.mu.Lock()
+	t.month = month
+	t.mu.Unlock()
+	if t.store == nil {
+		return nil
+	}
+	totals, err := t.store.monthTotals(ctx, month)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for identity, counter := range totals {
+		c := counter
+		t.monthTotals[identity] = &c
+	}
+	return nil
+}
+
+// Record 记录一次代理请求的字节数与次数。
+func (t *Tracker) Record(identity, host string, bytes int64) {
+	if bytes < 0 {
+		bytes = 0
+	}
+	now := t.now().UTC()
+	delta := Counter{Bytes: bytes, Requests: 1}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover(now)
+	for _, key := range []usageKey{
+		{Identity: identity, Host: host, PeriodType: periodDay, Period: now.Format(dayLayout)},
+		{Identity: identity, Host: host, PeriodType: periodMonth, Period: now.Format(monthLayout)},
+	} {
+		target := t.pending
+		if t.store == nil {
+			target = t.memory
+		}
+		counter, ok := target[key]
+		if !ok {
+			counter = &Counter{}
+			target[key] = counter
+		}
+		counter.add(delta)
+	}
+	total, ok := t.monthTotals[identity]
+	if !ok {
+		total = &Counter{}
+		t.monthTotals[identity] = total
+	}
+	total.add(delta)
+}
+
+// rollover 在跨月时清空配额累计值，调用方需持有锁。
+func (t *Tracker) rollover(now time.Time) {
+	month := now.Format(monthLayout)
+	if t.month == month {
+		return
+	}
+	t.month = month
+	t.monthTotals = map[string]*Counter{}
+}
+
+// Exceeded 判断身份本月用量是否已超出配额，返回超出的维度说明。
+func (t *Tracker) Exceeded(identity string) (bool, string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	quota, ok := t.quotas[identity]
+	if !ok {
+		return false, ""
+	}
+	t.rollover(t.now().UTC())
+	total := t.monthTotals[identity]
+	if total == nil {
+		return false, ""
+	}
+	if quota.MonthlyBytes > 0 && total.Bytes >= quota.MonthlyBytes {
+		return true, "monthly byte quota exceeded"
+	}
+	if quota.MonthlyRequests > 0 && total.Requests >= quota.MonthlyRequests {
+		return true, "monthly request quota exceeded"
+	}
+	return false, ""
+}
+
+// start 启动周期落库协程；仅内存模式下同一周期用于清理过期统计。
+func (t *Tracker) start() {
+	go func() {
+		defer close(t.doneCh)
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Flush(context.Background()); err != nil {
+					slog.Warn("usage flush failed", "error", err)
+				}
+			case <-t.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Close 停止周期落库并写入剩余增量，供优雅退出时调用。
+func (t *Tracker) Close(ctx context.Context) error {
+	t.stopOnce.Do(func() { close(t.stopCh) })
+	<-t.doneCh
+	return t.Flush(ctx)
+}
+
+// Flush 将待落库增量写入数据库；失败的增量会合并回 pending 等待下次重试。
+// 仅内存模式下没有数据库可写，改为丢弃当日与当月以外的统计，避免内存随运行时间增长。
+func (t *Tracker) Flush(ctx context.Context) error {
+	if t.store == nil {
+		t.pruneMemory(t.now().UTC())
+		return nil
+	}
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = map[usageKey]*Counter{}
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	var firstErr error
+	for key, counter := range batch {
+		if err := t.store.add(ctx, key, *counter); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			t.mu.Lock()
+			existing, ok := t.pending[key]
+			if !ok {
+				existing = &Counter{}
+				t.pending[key] = existing
+			}
+			existing.add(*counter)
+			t.mu.Unlock()
+		}
+	}
+	return firstErr
+}
+
+// pruneMemory 删除仅内存模式下早于当日（按日）与当月（按月）的统计。
+func (t *Tracker) pruneMemory(now time.Time) {
+	day, month := now.Format(dayLayout), now.Format(monthLayout)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key := range t.memory {
+		if (key.PeriodType == periodDay && key.Period != day) || (key.PeriodType == periodMonth && key.Period != month) {
+			delete(t.memory, key)
+		}
+	}
+}
+
+// Query 返回指定周期的统计行；数据库模式下会先落库再读取，保证结果包含最新增量。
+func (t *Tracker) Query(ctx context.Context, periodType, period string) ([]Row, error) {
+	var rows []Row
+	if t.store != nil {
+		if err := t.Flush(ctx); err != nil {
+			return nil, err
+		}
+		stored, err := t.store.query(ctx, periodType, period)
+		if err != nil {
+			return nil, err
+		}
+		rows = stored
+	} else {
+		t.mu.Lock()
+		for key, counter := range t.memory {
+			if key.PeriodType != periodType || key.Period != period {
+				continue
+			}
+			rows = append(rows, Row{
+				Identity:   key.Identity,
+				Host:       key.Host,
+				PeriodType: key.PeriodType,
+				Period:     key.Period,
+				Counter:    *counter,
+			})
+		}
+		t.mu.Unlock()
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Identity != rows[j].Identity {
+			return rows[i].Identity < rows[j].Identity
+		}
+		return rows[i].Bytes > rows[j].Bytes
+	})
+	return rows, nil
+}
+
+// setQuotas 整体替换配额表。
+func (t *Tracker) setQuotas(quotas map[string]Quota) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quotas = quotas
+}
+
+// quotaFor 返回身份的配额配置。
+func (t *Tracker) quotaFor(identity string) (Quota, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	q, ok := t.quotas[identity]
+	return q, ok
+}
+
+// monthTotal 返回身份本月累计用量。
+func (t *Tracker) monthTotal(identity string) Counter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rollover(t.now().UTC())
+	if c := t.monthTotals[identity]; c != nil {
+		return *c
+	}
+	return Counter{}
+}
@@ -0,0 +1,119 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+func newTestEngine(r *Registrar, name string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		identity.Set(c, identity.Identity{Name: name})
+		c.Next()
+	})
+	engine.Use(r.Middleware())
+	engine.GET("/proxy/media", func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Repeat("x", 1000))
+	})
+	r.Register(engine)
+	return engine
+}
+
+func TestMiddlewareEnforcesMonthlyQuota(t *testing.T) {
+	tracker := newTracker(nil, map[string]Quota{"alice": {MonthlyBytes: 1500}}, 0)
+	tracker.start()
+	engine := newTestEngine(&Registrar{Tracker: tracker}, "alice")
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/proxy/media?target=https://cdn.example.com/a.mp4", nil))
+		if w.Code != want {
+			t.Fatalf("request %d: expected %d, got %d", i, want, w.Code)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Fatalf("missing Retry-After on quota rejection")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/usage?period=month", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin usage: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Rows []Row `json:"rows"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Rows) != 1 || resp.Rows[0].Host != "cdn.example.com" || resp.Rows[0].Bytes != 2000 || resp.Rows[0].Requests != 2 {
+		t.Fatalf("unexpected usage rows %+v", resp.Rows)
+	}
+}
+
+func TestTrackerFlushAccumulatesIntoDatabase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+
+	tracker := newTracker(&store{db: sqlxDB}, nil, time.Hour)
+	tracker.now = func() time.Time { return time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC) }
+	tracker.Record("default", "cdn.example.com", 512)
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectExec(`UPDATE t_bridge_usage`).
+		WithArgs(int64(512), int64(1), sqlmock.AnyArg(), "default", "cdn.example.com", periodDay, "2024-05-03").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE t_bridge_usage`).
+		WithArgs(int64(512), int64(1), sqlmock.AnyArg(), "default", "cdn.example.com", periodMonth, "2024-05").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO t_bridge_usage`).
+		WithArgs("default", "cdn.example.com", periodMonth, "2024-05", int64(512), int64(1), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := tracker.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+func TestMemoryTrackerDropsPastPeriods(t *testing.T) {
+	now := time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)
+	tracker := newTracker(nil, nil, 0)
+	tracker.now = func() time.Time { return now }
+	tracker.Record("alice", "cdn.example.com", 100)
+
+	now = now.Add(2 * time.Hour)
+	tracker.Record("alice", "cdn.example.com", 50)
+	if err := tracker.Flush(context.Background()); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	if len(tracker.memory) != 2 {
+		t.Fatalf("expected only the current day and month to remain, got %+v", tracker.memory)
+	}
+	for _, period := range []struct{ typ, value string }{{periodDay, "2026-01-31"}, {periodMonth, "2026-01"}} {
+		if rows, _ := tracker.Query(context.Background(), period.typ, period.value); len(rows) != 0 {
+			t.Fatalf("past %s %s should be pruned, got %+v", period.typ, period.value, rows)
+		}
+	}
+	if rows, _ := tracker.Query(context.Background(), periodMonth, "2026-02"); len(rows) != 1 || rows[0].Bytes != 50 {
+		t.Fatalf("unexpected current month rows %+v", rows)
+	}
+}
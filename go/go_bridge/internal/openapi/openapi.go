@@ -0,0 +1,190 @@
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Version 为生成文档遵循的 OpenAPI 规范版本。
+const Version = "3.0.3"
+
+// Route 描述单个接口，由各模块按路由注册时的实际行为填写。
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	// Tag 为接口分组，通常是模块名。
+	Tag   string
+	Query []Parameter
+	// Request 为 JSON 请求体的零值（如 insertRequest{}），nil 表示无请求体。
+	Request any
+	// RequestOptional 表示请求体可以省略。
+	RequestOptional bool
+	// Response 为成功时 JSON 响应体的零值，也可直接传入 *Schema（如透传上游的任意 JSON）；
+	// nil 时按 ContentType 描述非 JSON 响应。
+	Response any
+	// ContentType 为非 JSON 响应的媒体类型，例如 video/* 或 image/jpeg。
+	ContentType string
+	// Status 为成功状态码，默认 200。
+	Status int
+}
+
+// QueryParam 构造查询参数描述，typ 为 string、integer、number 或 boolean。
+func QueryParam(name, typ string, required bool, description string) Parameter {
+	return Parameter{Name: name, In: "query", Required: required, Description: description, Schema: &Schema{Type: typ}}
+}
+
+// Document 为 OpenAPI 文档根对象。
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security,omitempty"`
+}
+
+// Info 为文档基本信息。
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components 保存共享的安全方案。
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme 描述令牌的传递方式。
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// Operation 为单个方法的接口描述；x-required-scope 标注访问所需的令牌权限。
+type Operation struct {
+	Summary       string              `json:"summary,omitempty"`
+	OperationID   string              `json:"operationId"`
+	Tags          []string            `json:"tags,omitempty"`
+	Parameters    []Parameter         `json:"parameters,omitempty"`
+	RequestBody   *RequestBody        `json:"requestBody,omitempty"`
+	Responses     map[string]Response `json:"responses"`
+	RequiredScope string              `json:"x-required-scope,omitempty"`
+}
+
+// Parameter 为查询参数描述。
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody 为请求体描述。
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response 为单个状态码的响应描述。
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType 关联媒体类型与结构。
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// errorResponse 为各模块统一的错误响应 `{"error": "..."}`。
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Build 汇总各模块的接口生成文档；scopeFor 返回路径所需的令牌权限（可为 nil）。
+func Build(info Info, routes []Route, scopeFor func(path string) string) Document {
+	doc := Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{SecuritySchemes: map[string]SecurityScheme{
+			"bearerToken": {Type: "http", Scheme: "bearer"},
+			"accessToken": {Type: "apiKey", In: "query", Name: "access_token"},
+		}},
+		Security: []map[string][]string{{"bearerToken": {}}, {"accessToken": {}}},
+	}
+	errorContent := map[string]MediaType{"application/json": {Schema: SchemaOf(errorResponse{})}}
+
+	sorted := append([]Route(nil), routes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	for _, route := range sorted {
+		method := strings.ToLower(route.Method)
+		op := &Operation{
+			Summary:     route.Summary,
+			OperationID: method + operationSuffix(route.Path),
+			Parameters:  route.Query,
+			Responses:   map[string]Response{"default": {Description: "error", Content: errorContent}},
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		if scopeFor != nil {
+			op.RequiredScope = scopeFor(route.Path)
+		}
+		if route.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: !route.RequestOptional,
+				Content:  map[string]MediaType{"application/json": {Schema: SchemaOf(route.Request)}},
+			}
+		}
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := Response{Description: http.StatusText(status)}
+		switch {
+		case route.Response != nil:
+			success.Content = map[string]MediaType{"application/json": {Schema: SchemaOf(route.Response)}}
+		case route.ContentType != "":
+			success.Content = map[string]MediaType{route.ContentType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+
+		item, ok := doc.Paths[route.Path]
+		if !ok {
+			item = map[string]*Operation{}
+			doc.Paths[route.Path] = item
+		}
+		item[method] = op
+	}
+	return doc
+}
+
+// ResponseSchema 返回指定接口成功响应的 JSON 结构，供契约测试校验实际响应。
+func (d Document) ResponseSchema(method, path string, status int) (*Schema, bool) {
+	op, ok := d.Paths[path][strings.ToLower(method)]
+	if !ok {
+		return nil, false
+	}
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		return nil, false
+	}
+	media, ok := resp.Content["application/json"]
+	return media.Schema, ok
+}
+
+// operationSuffix 将 /history/screenshot/sync 转为 HistoryScreenshotSync。
+func operationSuffix(path string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '.' || r == '-' || r == '_' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,183 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Schema 为 OpenAPI 3.0 Schema Object 的子集，覆盖桥接接口用到的类型。
+type Schema struct {
+	Type     string   `json:"type,omitempty"`
+	Format   string   `json:"format,omitempty"`
+	Nullable bool     `json:"nullable,omitempty"`
+	Required []string `json:"required,omitempty"`
+	// Properties 非空时对象只允许出现列出的字段。
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	// AdditionalProperties 描述 map 的值类型。
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+	Items                *Schema `json:"items,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf 根据 Go 值的类型与 json 标签生成 Schema：未标注 omitempty 或 `openapi:"optional"` 的字段为必填，
+// 切片、map 与指针可为 null，any 不限制类型；传入 *Schema 时原样返回。
+func SchemaOf(v any) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	if s, ok := v.(*Schema); ok {
+		return s
+	}
+	return schemaOfType(reflect.TypeOf(v))
+}
+
+func schemaOfType(t reflect.Type) *Schema {
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaOfType(t.Elem())
+		s.Nullable = true
+		return s
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: schemaOfType(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: schemaOfType(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		addFields(s, t)
+		sort.Strings(s.Required)
+		return s
+	}
+	// interface 等无法静态确定的类型。
+	return &Schema{}
+}
+
+// addFields 展开结构体字段，匿名嵌入的结构体与 encoding/json 一样提升到外层。
+func addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				addFields(s, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		s.Properties[name] = schemaOfType(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Tag.Get("openapi") != "optional" {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// Validate 校验 encoding/json 解码得到的值是否符合 Schema：类型、必填字段，以及不得出现未声明的字段。
+func Validate(s *Schema, value any) error {
+	return validate(s, value, "$")
+}
+
+func validate(s *Schema, value any, path string) error {
+	if s == nil || (s.Type == "" && s.Properties == nil) {
+		return nil
+	}
+	if value == nil {
+		if s.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed", path)
+	}
+	switch s.Type {
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean, got %T", path, value)
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected %s, got %T", path, s.Type, value)
+		}
+		if s.Type == "integer" && n != float64(int64(n)) {
+			return fmt.Errorf("%s: expected integer, got %v", path, n)
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: expected string, got %T", path, value)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array, got %T", path, value)
+		}
+		for i, item := range items {
+			if err := validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object, got %T", path, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required field %q", path, name)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := path + "." + key
+			if prop, ok := s.Properties[key]; ok {
+				if err := validate(prop, obj[key], child); err != nil {
+					return err
+				}
+				continue
+			}
+			if s.AdditionalProperties != nil {
+				if err := validate(s.AdditionalProperties, obj[key], child); err != nil {
+					return err
+				}
+				continue
+			}
+			if s.Properties != nil {
+				return fmt.Errorf("%s: field is not declared in the spec", child)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// adminUIPrefix 为内嵌管理界面的挂载路径。
+const adminUIPrefix = "/admin/ui"
+
+//go:embed adminui
+var adminUIFiles embed.FS
+
+// isAdminUIAsset 判断请求是否为管理界面的静态文件。静态文件不含任何数据，无需令牌即可加载，
+// 页面内的数据请求仍携带令牌访问 /admin/*、/proxy/metrics 等接口并按原有权限校验。
+func isAdminUIAsset(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return req.URL.Path == adminUIPrefix || strings.HasPrefix(req.URL.Path, adminUIPrefix+"/")
+}
+
+// registerAdminUI 挂载 /admin/ui 静态页面；禁止被嵌入其他页面，脚本只能来自本站。
+func registerAdminUI(engine *gin.Engine) {
+	files, err := fs.Sub(adminUIFiles, "adminui")
+	if err != nil {
+		panic(err)
+	}
+	ui := engine.Group(adminUIPrefix, func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+		header.Set("X-Frame-Options", "DENY")
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "no-referrer")
+		header.Set("Cache-Control", "no-cache")
+		c.Next()
+	})
+	ui.StaticFS("/", http.FS(files))
+}
+
+// tokenInfo 为 /admin/tokens 中的单个令牌，不包含令牌明文与摘要。
+type tokenInfo struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	UserID    *int64     `json:"userId,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Expired   bool       `json:"expired"`
+	// Legacy 表示该身份来自旧版单一 authToken。
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+// tokensResponse 为 /admin/tokens 的响应。
+type tokensResponse struct {
+	AuthMode string      `json:"authMode"`
+	Tokens   []tokenInfo `json:"tokens"`
+}
+
+// tokens 列出当前配置中的桥接令牌；AList 委托鉴权的用户不在此列出。
+func (g *statusGate) tokens(now time.Time) tokensResponse {
+	cfg := g.cfg.Load()
+	resp := tokensResponse{AuthMode: cfg.Auth.Mode, Tokens: []tokenInfo{}}
+	if resp.AuthMode == "" {
+		resp.AuthMode = appconfig.AuthModeToken
+	}
+	if cfg.AuthToken != "" {
+		resp.Tokens = append(resp.Tokens, tokenInfo{Name: defaultIdentity, Scopes: allScopes, Legacy: true})
+	}
+	for _, token := range cfg.Tokens {
+		info := tokenInfo{Name: token.Name, Scopes: token.Scopes, UserID: token.UserID}
+		if info.Scopes == nil {
+			info.Scopes = []string{}
+		}
+		if at, ok := token.Expiry(); ok {
+			at = at.UTC()
+			info.ExpiresAt = &at
+			info.Expired = !now.Before(at)
+		}
+		resp.Tokens = append(resp.Tokens, info)
+	}
+	return resp
+}
@@ -0,0 +1,360 @@
+'use strict';
+
+// 管理界面只在浏览器内调用现有接口：令牌保存在 sessionStorage，以 Authorization 头发送，不使用 Cookie。
+// 所有数据都通过 textContent 写入页面，文件名、错误信息等来自服务端的字符串不会被当作 HTML 解析。
+
+const tokenKey = 'goBridgeAdminToken';
+const refreshMs = 5000;
+
+const state = {
+  modules: [],
+  preview: null,
+  timer: null,
+};
+
+const $ = (id) => document.getElementById(id);
+
+class HttpError extends Error {
+  constructor(status, message) {
+    super(message);
+    this.status = status;
+  }
+}
+
+async function api(method, path, body) {
+  const headers = {};
+  const token = sessionStorage.getItem(tokenKey);
+  if (token) headers.Authorization = 'Bearer ' + token;
+  if (body !== undefined) headers['Content-Type'] = 'application/json';
+  const resp = await fetch(path, {
+    method,
+    headers,
+    body: body === undefined ? undefined : JSON.stringify(body),
+    cache: 'no-store',
+  });
+  let data = null;
+  try {
+    data = await resp.json();
+  } catch (_) {
+    // 非 JSON 响应按状态码处理。
+  }
+  if (!resp.ok) {
+    throw new HttpError(resp.status, (data && data.error) || resp.status + ' ' + resp.statusText);
+  }
+  return data;
+}
+
+// ---------- 格式化 ----------
+
+function fmtBytes(n) {
+  if (!n) return '0 B';
+  const units = ['B', 'KiB', 'MiB', 'GiB', 'TiB'];
+  let i = 0;
+  while (n >= 1024 && i < units.length - 1) {
+    n /= 1024;
+    i++;
+  }
+  return n.toFixed(i === 0 ? 0 : 1) + ' ' + units[i];
+}
+
+function fmtDuration(sec) {
+  sec = Math.max(0, Math.floor(sec || 0));
+  const d = Math.floor(sec / 86400);
+  const h = Math.floor((sec % 86400) / 3600);
+  const m = Math.floor((sec % 3600) / 60);
+  const s = sec % 60;
+  if (d) return d + '天 ' + h + '时';
+  if (h) return h + '时 ' + m + '分';
+  if (m) return m + '分 ' + s + '秒';
+  return s + '秒';
+}
+
+const fmtNum = (n, digits = 1) => (n === undefined || n === null ? '-' : Number(n).toFixed(digits));
+const fmtMs = (n) => (n ? fmtNum(n, 0) + ' ms' : '-');
+const fmtPct = (n) => (n === undefined || n === null ? '-' : (n * 100).toFixed(1) + '%');
+const fmtTime = (s) => (s && !s.startsWith('0001-') ? new Date(s).toLocaleString() : '-');
+
+// ---------- DOM ----------
+
+function el(tag, text, className) {
+  const node = document.createElement(tag);
+  if (text !== undefined && text !== null) node.textContent = String(text);
+  if (className) node.className = className;
+  return node;
+}
+
+function badge(text, level) {
+  return el('span', text, 'badge ' + level);
+}
+
+function fillGrid(id, items) {
+  const grid = $(id);
+  grid.replaceChildren();
+  for (const [label, value] of items) {
+    const item = el('div');
+    item.append(el('dt', label), el('dd', value));
+    grid.append(item);
+  }
+}
+
+// fillTable 按行渲染表格；单元格可以是字符串、数字或已构造的节点。
+function fillTable(id, rows, emptyText) {
+  const table = $(id);
+  const body = table.tBodies[0];
+  body.replaceChildren();
+  if (!rows.length) {
+    const td = el('td', emptyText || '无', 'empty');
+    td.colSpan = table.tHead.rows[0].cells.length;
+    const tr = el('tr');
+    tr.append(td);
+    body.append(tr);
+    return;
+  }
+  for (const row of rows) {
+    const tr = el('tr');
+    for (const cell of row) {
+      const td = el('td', null, 'wrap');
+      if (cell instanceof Node) td.append(cell);
+      else td.textContent = cell === undefined || cell === null || cell === '' ? '-' : String(cell);
+      tr.append(td);
+    }
+    body.append(tr);
+  }
+}
+
+function showError(err) {
+  $('error').textContent = err ? String(err.message || err) : '';
+}
+
+// ---------- 各区块 ----------
+
+function renderStatus(status) {
+  state.modules = status.modules || [];
+  $('build').textContent = status.version + ' (' + (status.commit || 'unknown').slice(0, 12) + ', ' + status.buildMode + ')';
+  const rt = status.runtime || {};
+  fillGrid('overview', [
+    ['运行时长', fmtDuration(status.uptimeSeconds)],
+    ['启动时间', fmtTime(status.startedAt)],
+    ['模块', state.modules.join(', ') || '-'],
+    ['Go', rt.goVersion + ' ' + rt.os + '/' + rt.arch],
+    ['Goroutine', rt.goroutines],
+    ['堆内存', fmtBytes(rt.heapInuseBytes)],
+    ['GC 次数', rt.numGc],
+  ]);
+  for (const section of document.querySelectorAll('[data-module]')) {
+    section.hidden = !state.modules.includes(section.dataset.module);
+  }
+
+  const moduleStatus = status.moduleStatus || {};
+  const proxy = moduleStatus.proxy;
+  if (proxy) {
+    fillTable('streams', (proxy.active_streams || []).map((s) => [
+      s.id,
+      s.identity,
+      s.client_ip,
+      s.host,
+      s.path,
+      s.range,
+      s.status,
+      fmtDuration(s.duration_sec),
+      fmtBytes(s.bytes),
+      fmtNum(s.throughput_kbps, 0),
+    ]), '当前没有播放流');
+  }
+
+  const storage = moduleStatus.screenshot;
+  if (storage) {
+    fillGrid('storage', [
+      ['目录', storage.dir],
+      ['用户目录', storage.users],
+      ['文件数', storage.files],
+      ['占用', fmtBytes(storage.bytes)],
+    ].concat(storage.error ? [['错误', storage.error]] : []));
+  }
+}
+
+function hopHealth(hop) {
+  if (hop.last_error && hop.last_status >= 400 && !hop.requests_per_minute) return badge('不可达', 'bad');
+  if (hop.stale_seconds > 120) return badge('数据过期', 'warn');
+  if (!hop.requests_per_minute) return badge('空闲', 'ok');
+  if (hop.success_rate >= 0.95) return badge('正常', 'ok');
+  if (hop.success_rate >= 0.8) return badge('降级', 'warn');
+  return badge('异常', 'bad');
+}
+
+function breakerBadge(stateName) {
+  const level = stateName === 'closed' ? 'ok' : stateName === 'half_open' ? 'warn' : 'bad';
+  return badge(stateName, level);
+}
+
+function renderMetrics(m) {
+  fillGrid('proxy-summary', [
+    ['请求总数', m.total_requests],
+    ['错误总数', m.total_errors],
+    ['窗口成功率', fmtPct(m.success_rate)],
+    ['P50 / P90 / P99', [m.p50_latency_ms, m.p90_latency_ms, m.p99_latency_ms].map((v) => fmtNum(v, 0)).join(' / ') + ' ms'],
+    ['请求/分', fmtNum(m.requests_per_minute)],
+    ['平均吞吐', fmtNum(m.avg_throughput_kbps, 0) + ' kbps'],
+    ['累计流量', fmtBytes(m.total_bytes)],
+    ['最近状态', m.last_status ? m.last_status + (m.last_error ? ' ' + m.last_error : '') : '-'],
+  ]);
+  fillTable('hops', (m.hops || []).map((h) => [
+    h.endpoint,
+    hopHealth(h),
+    fmtPct(h.success_rate),
+    fmtMs(h.p50_latency_ms),
+    fmtMs(h.p90_latency_ms),
+    fmtMs(h.p99_latency_ms),
+    fmtNum(h.requests_per_minute),
+    fmtNum(h.avg_throughput_kbps, 0),
+    h.stale_seconds ? fmtDuration(h.stale_seconds) : '-',
+    h.last_error,
+  ]), '未配置代理链路');
+  fillTable('breakers', (m.breakers || []).map((b) => [
+    b.key,
+    breakerBadge(b.state),
+    b.samples,
+    fmtPct(b.error_rate),
+    fmtPct(b.slow_rate),
+    b.trips,
+    b.rejected,
+    b.retry_after_sec ? fmtDuration(b.retry_after_sec) : '-',
+    b.last_error,
+  ]), '暂无熔断记录');
+
+  const routing = m.routing;
+  $('routing-block').hidden = !routing;
+  if (routing) {
+    fillTable('routes', (routing.routes || []).map((r) => [
+      r.name + ' (' + (r.hops || []).join(' → ') + ')',
+      r.active ? '✓' : '',
+      r.healthy ? badge('健康', 'ok') : badge('异常', 'bad'),
+      fmtNum(r.score, 2),
+      fmtMs(r.ttfb_ms),
+      fmtNum(r.throughput_kbps, 0),
+      r.probes + ' / ' + r.failures,
+      r.last_error,
+    ]));
+  }
+}
+
+function renderTokens(data) {
+  $('auth-mode').textContent = '鉴权模式：' + data.authMode + '。令牌内容与摘要不会输出，增删令牌请修改配置文件（支持热更新）。';
+  fillTable('tokens', data.tokens.map((t) => [
+    t.name + (t.legacy ? '（authToken）' : ''),
+    t.scopes.join(', '),
+    t.userId === undefined ? '' : t.userId,
+    t.expiresAt ? fmtTime(t.expiresAt) : '永不过期',
+    t.expired ? badge('已过期', 'bad') : badge('有效', 'ok'),
+  ]), '未配置令牌');
+}
+
+function renderSync(result) {
+  const items = [
+    ['模式', result.dryRun ? '预览' : '已删除'],
+    ['候选文件', result.totalCandidates],
+    ['孤立文件', result.orphanCandidates],
+    ['已删除', result.deletedFiles],
+    ['跳过', result.skippedEntries],
+    ['耗时', result.durationMillis + ' ms'],
+  ];
+  if (result.orphanOverflow) items.push(['未列出', result.orphanOverflow]);
+  fillGrid('sync-summary', items);
+  fillTable('orphans', (result.orphanDetails || []).map((o) => [
+    o.userId,
+    o.videoSha1,
+    o.relativePath,
+    fmtBytes(o.sizeBytes),
+    fmtTime(o.modTime),
+  ]), result.dryRun ? '没有孤立截图' : '');
+  const errors = $('sync-errors');
+  errors.replaceChildren(...(result.errors || []).map((e) => el('li', e)));
+}
+
+// ---------- 流程 ----------
+
+async function refresh() {
+  try {
+    const status = await api('GET', '/admin/status');
+    renderStatus(status);
+    const tasks = [api('GET', '/admin/tokens').then(renderTokens)];
+    if (state.modules.includes('proxy')) {
+      tasks.push(api('GET', '/proxy/metrics').then(renderMetrics));
+    }
+    await Promise.all(tasks);
+    showError(null);
+    return true;
+  } catch (err) {
+    if (err.status === 401 || err.status === 403) {
+      showLogin(err.message);
+      return false;
+    }
+    showError(err);
+    return true;
+  }
+}
+
+function schedule() {
+  clearInterval(state.timer);
+  state.timer = null;
+  if ($('auto-refresh').checked && !$('console').hidden) {
+    state.timer = setInterval(refresh, refreshMs);
+  }
+}
+
+function showLogin(message) {
+  clearInterval(state.timer);
+  state.timer = null;
+  $('console').hidden = true;
+  $('logout').hidden = true;
+  $('login').hidden = false;
+  $('login-error').textContent = message || '';
+  $('token').focus();
+}
+
+async function enter() {
+  $('login').hidden = true;
+  $('console').hidden = false;
+  $('logout').hidden = !sessionStorage.getItem(tokenKey);
+  if (await refresh()) schedule();
+}
+
+async function syncScreenshots(apply) {
+  const preview = $('sync-preview');
+  const confirmButton = $('sync-apply');
+  if (apply) {
+    const count = state.preview ? state.preview.orphanCandidates : 0;
+    if (!window.confirm('确认删除 ' + count + ' 个孤立截图文件？此操作不可撤销。')) return;
+  }
+  preview.disabled = true;
+  confirmButton.disabled = true;
+  try {
+    const result = await api('POST', '/history/screenshot/sync', { dryRun: !apply, previewLimit: 500 });
+    renderSync(result);
+    state.preview = apply ? null : result;
+    confirmButton.disabled = apply || !result.orphanCandidates;
+    if (apply) refresh();
+  } catch (err) {
+    showError(err);
+  } finally {
+    preview.disabled = false;
+  }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+  $('login-form').addEventListener('submit', (event) => {
+    event.preventDefault();
+    sessionStorage.setItem(tokenKey, $('token').value.trim());
+    $('token').value = '';
+    enter();
+  });
+  $('logout').addEventListener('click', () => {
+    sessionStorage.removeItem(tokenKey);
+    showLogin('');
+  });
+  $('refresh').addEventListener('click', refresh);
+  $('auto-refresh').addEventListener('change', schedule);
+  $('sync-preview').addEventListener('click', () => syncScreenshots(false));
+  $('sync-apply').addEventListener('click', () => syncScreenshots(true));
+  enter();
+});
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+func TestAdminUIAndTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := int64(42)
+	cfg := appconfig.Config{
+		AuthToken: "legacy-secret",
+		Tokens: []appconfig.APIToken{
+			{Name: "viewer", SecretHash: appconfig.HashTokenSecret("viewer"), Scopes: []string{appconfig.ScopeProxy}, UserID: &userID},
+			{Name: "old", SecretHash: appconfig.HashTokenSecret("old"), Scopes: []string{appconfig.ScopeAdmin}, ExpiresAt: "2001-01-01T00:00:00Z"},
+		},
+	}
+	router := NewRouter(cfg, echoRegistrar{})
+	send := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 静态页面无需令牌，并禁止被嵌入。
+	w := send(http.MethodGet, "/admin/ui/", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "app.js") {
+		t.Fatalf("ui index: %d %s", w.Code, w.Body.String())
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-ancestors 'none'") {
+		t.Fatalf("missing CSP header: %q", csp)
+	}
+	if w := send(http.MethodGet, "/admin/ui/app.js", ""); w.Code != http.StatusOK {
+		t.Fatalf("ui script: %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/admin/ui", ""); w.Code != http.StatusMovedPermanently {
+		t.Fatalf("ui without trailing slash should redirect, got %d", w.Code)
+	}
+	// 豁免只覆盖静态文件：其他方法与借道 /admin/ui 的路径仍需鉴权。
+	if w := send(http.MethodPost, "/admin/ui/", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("POST to ui should require auth, got %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/admin/ui/../status", ""); strings.Contains(w.Body.String(), "uptimeSeconds") {
+		t.Fatalf("path traversal reached status: %s", w.Body.String())
+	}
+
+	if w := send(http.MethodGet, "/admin/tokens", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("tokens without token: %d", w.Code)
+	}
+	if w := send(http.MethodGet, "/admin/tokens", "viewer"); w.Code != http.StatusForbidden {
+		t.Fatalf("tokens with non-admin token: %d", w.Code)
+	}
+	w = send(http.MethodGet, "/admin/tokens", "legacy-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("tokens: %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "legacy-secret") || strings.Contains(w.Body.String(), "sha256:") {
+		t.Fatalf("tokens leak secrets: %s", w.Body.String())
+	}
+	var resp tokensResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.AuthMode != appconfig.AuthModeToken || len(resp.Tokens) != 3 {
+		t.Fatalf("unexpected tokens: %+v", resp)
+	}
+	if !resp.Tokens[0].Legacy || resp.Tokens[0].Name != defaultIdentity {
+		t.Fatalf("legacy token should be listed first: %+v", resp.Tokens[0])
+	}
+	if viewer := resp.Tokens[1]; viewer.UserID == nil || *viewer.UserID != userID || viewer.ExpiresAt != nil || viewer.Expired {
+		t.Fatalf("unexpected viewer: %+v", viewer)
+	}
+	if old := resp.Tokens[2]; old.ExpiresAt == nil || !old.ExpiresAt.Equal(time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)) || !old.Expired {
+		t.Fatalf("unexpected expired token: %+v", old)
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+const (
+	defaultAListCacheTTL = 5 * time.Minute
+	defaultAListTimeout  = 10 * time.Second
+	// alistNegativeTTL 为无效 JWT 的缓存时长，避免被反复用于打满 AList。
+	alistNegativeTTL = 30 * time.Second
+	alistCacheMax    = 1024
+	// alistRoleAdmin 对应 AList 用户角色中的管理员（0 普通、1 游客、2 管理员）。
+	alistRoleAdmin = 2
+	alistRoleGuest = 1
+)
+
+// alistMeResponse 对应 AList `/api/me` 的响应结构，字段与 lib/models/user_info.dart 一致。
+type alistMeResponse struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+		Role     int    `json:"role"`
+		Disabled bool   `json:"disabled"`
+	} `json:"data"`
+}
+
+type alistCacheEntry struct {
+	id        identity.Identity
+	valid     bool
+	expiresAt time.Time
+}
+
+// alistAuth 把 AList JWT 交给 AList 服务端的 /api/me 校验，结果按 JWT 摘要缓存。
+type alistAuth struct {
+	baseURL    string
+	client     *http.Client
+	ttl        time.Duration
+	userScopes []string
+	now        func() time.Time
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]alistCacheEntry
+}
+
+func newAListAuth(cfg appconfig.AListAuth, client *http.Client) *alistAuth {
+	ttl := cfg.CacheTTLDuration()
+	if ttl <= 0 {
+		ttl = defaultAListCacheTTL
+	}
+	if client == nil {
+		timeout := cfg.TimeoutDuration()
+		if timeout <= 0 {
+			timeout = defaultAListTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	return &alistAuth{
+		baseURL:    cfg.BaseURL,
+		client:     client,
+		ttl:        ttl,
+		userScopes: cfg.UserScopes,
+		now:        time.Now,
+		cache:      map[[sha256.Size]byte]alistCacheEntry{},
+	}
+}
+
+func (a *alistAuth) authenticate(ctx context.Context, secret string) (identity.Identity, error) {
+	key := sha256.Sum256([]byte(secret))
+	if entry, ok := a.cached(key); ok {
+		if !entry.valid {
+			return identity.Identity{}, errInvalidToken
+		}
+		return entry.id, nil
+	}
+
+	id, err := a.fetchMe(ctx, secret)
+	switch {
+	case err == nil:
+		a.store(key, alistCacheEntry{id: id, valid: true, expiresAt: a.now().Add(a.ttl)})
+	case errors.Is(err, errInvalidToken):
+		a.store(key, alistCacheEntry{expiresAt: a.now().Add(min(a.ttl, alistNegativeTTL))})
+	}
+	return id, err
+}
+
+// fetchMe 调用 AList /api/me；AList 对无效令牌同样返回 HTTP 200，需要检查响应体中的 code。
+func (a *alistAuth) fetchMe(ctx context.Context, secret string) (identity.Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/api/me", nil)
+	if err != nil {
+		return identity.Identity{}, fmt.Errorf("alist auth: %v", err)
+	}
+	req.Header.Set("Authorization", secret)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return identity.Identity{}, fmt.Errorf("alist auth unavailable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return identity.Identity{}, errInvalidToken
+	}
+	if resp.StatusCode != http.StatusOK {
+		return identity.Identity{}, fmt.Errorf("alist auth unavailable: upstream returned %s", resp.Status)
+	}
+	var me alistMeResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&me); err != nil {
+		return identity.Identity{}, fmt.Errorf("alist auth unavailable: decode /api/me: %v", err)
+	}
+	if me.Code != http.StatusOK || me.Data.ID == 0 || me.Data.Disabled || me.Data.Role == alistRoleGuest {
+		return identity.Identity{}, errInvalidToken
+	}
+
+	userID := me.Data.ID
+	id := identity.Identity{
+		Name:   "alist:" + me.Data.Username,
+		Scopes: a.userScopes,
+		UserID: &userID,
+		Admin:  me.Data.Role == alistRoleAdmin,
+	}
+	if me.Data.Username == "" {
+		id.Name = "alist:" + strconv.FormatInt(userID, 10)
+	}
+	if id.Admin {
+		id.Scopes = allScopes
+	}
+	return id, nil
+}
+
+func (a *alistAuth) cached(key [sha256.Size]byte) (alistCacheEntry, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.cache[key]
+	if !ok {
+		return alistCacheEntry{}, false
+	}
+	if !a.now().Before(entry.expiresAt) {
+		delete(a.cache, key)
+		return alistCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (a *alistAuth) store(key [sha256.Size]byte, entry alistCacheEntry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.cache) >= alistCacheMax {
+		now := a.now()
+		for k, v := range a.cache {
+			if !now.Before(v.expiresAt) {
+				delete(a.cache, k)
+			}
+		}
+		if len(a.cache) >= alistCacheMax {
+			// 仍然超限时整体清空，令牌会在下次请求时重新校验。
+			a.cache = map[[sha256.Size]byte]alistCacheEntry{}
+		}
+	}
+	a.cache[key] = entry
+}
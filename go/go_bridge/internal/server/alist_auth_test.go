@@ -0,0 +1,91 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+// newFakeAList 模拟 AList /api/me：无效令牌同样返回 HTTP 200 与 code=401。
+func newFakeAList(t *testing.T, calls *int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/me" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.Header.Get("Authorization") {
+		case "jwt-user":
+			_, _ = w.Write([]byte(`{"code":200,"message":"success","data":{"id":3,"username":"alice","role":0,"disabled":false}}`))
+		case "jwt-admin":
+			_, _ = w.Write([]byte(`{"code":200,"message":"success","data":{"id":1,"username":"admin","role":2,"disabled":false}}`))
+		default:
+			_, _ = w.Write([]byte(`{"code":401,"message":"token is invalidated","data":null}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAListAuthResolvesAndCachesIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls int32
+	alist := newFakeAList(t, &calls)
+
+	cfg := appconfig.Config{Auth: appconfig.AuthConfig{
+		Mode: appconfig.AuthModeAList,
+		AList: appconfig.AListAuth{
+			BaseURL:    alist.URL,
+			UserScopes: []string{appconfig.ScopeProxy, appconfig.ScopeSQLRead},
+		},
+	}}
+	engine := NewRouter(cfg)
+	engine.GET("/proxy/media", func(c *gin.Context) {
+		id, _ := identity.From(c)
+		c.JSON(http.StatusOK, id)
+	})
+	engine.POST("/sql/delete", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		w := do(http.MethodGet, "/proxy/media", "jwt-user")
+		if w.Code != http.StatusOK || w.Body.String() != `{"name":"alist:alice","scopes":["proxy","sql:read"],"userId":3}` {
+			t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected cached /api/me result, got %d calls", calls)
+	}
+
+	if w := do(http.MethodPost, "/sql/delete", "jwt-user"); w.Code != http.StatusForbidden {
+		t.Fatalf("normal user should lack sql:write, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/sql/delete", "Bearer jwt-admin"); w.Code != http.StatusOK {
+		t.Fatalf("admin should pass, got %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodGet, "/proxy/media", "jwt-bogus"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("invalid jwt should be rejected, got %d", w.Code)
+	}
+
+	alist.Close()
+	if w := do(http.MethodGet, "/proxy/media", "jwt-unknown"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unreachable alist should yield 503, got %d", w.Code)
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+// defaultIdentity 为兼容旧版单一 authToken 时使用的身份名称，拥有全部权限。
+const defaultIdentity = "default"
+
+// routeScope 描述一个路由组（按路径前缀匹配）所需的权限。
+type routeScope struct {
+	prefix string
+	scope  string
+}
+
+// routeScopes 按前缀从长到短排列，首个命中的规则生效；未命中的路由（如 /health）只需通过鉴权。
+var routeScopes = []routeScope{
+	{prefix: "/history/screenshot", scope: appconfig.ScopeScreenshot},
+	{prefix: "/sql/query", scope: appconfig.ScopeSQLRead},
+	{prefix: "/proxy/", scope: appconfig.ScopeProxy},
+	{prefix: "/admin/", scope: appconfig.ScopeAdmin},
+	{prefix: "/sql/", scope: appconfig.ScopeSQLWrite},
+}
+
+// RequiredScope 返回访问指定路径所需的权限，空字符串表示无额外要求。
+func RequiredScope(path string) string {
+	for _, rule := range routeScopes {
+		if strings.HasPrefix(path, rule.prefix) {
+			return rule.scope
+		}
+	}
+	return ""
+}
+
+var allScopes = []string{
+	appconfig.ScopeProxy,
+	appconfig.ScopeSQLRead,
+	appconfig.ScopeSQLWrite,
+	appconfig.ScopeScreenshot,
+	appconfig.ScopeAdmin,
+}
+
+var (
+	errInvalidToken = errors.New("invalid token")
+	errTokenExpired = errors.New("token expired")
+)
+
+// authenticator 将请求携带的凭据解析为调用方身份；无法识别时返回 errInvalidToken。
+type authenticator interface {
+	authenticate(ctx context.Context, secret string) (identity.Identity, error)
+}
+
+// tokenAuth 校验配置中的桥接令牌（旧版 authToken 与 tokens 列表）。
+type tokenAuth struct {
+	legacy string
+	tokens []appconfig.APIToken
+	now    func() time.Time
+}
+
+func newTokenAuth(cfg appconfig.Config) *tokenAuth {
+	if cfg.AuthToken == "" && len(cfg.Tokens) == 0 {
+		return nil
+	}
+	return &tokenAuth{legacy: cfg.AuthToken, tokens: cfg.Tokens, now: time.Now}
+}
+
+func (a *tokenAuth) authenticate(_ context.Context, secret string) (identity.Identity, error) {
+	if a.legacy != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(a.legacy)) == 1 {
+		return identity.Identity{Name: defaultIdentity, Scopes: allScopes}, nil
+	}
+	for _, token := range a.tokens {
+		if !token.Matches(secret) {
+			continue
+		}
+		if at, has := token.Expiry(); has && !a.now().Before(at) {
+			return identity.Identity{}, errTokenExpired
+		}
+		return identity.Identity{Name: token.Name, Scopes: token.Scopes, UserID: token.UserID}, nil
+	}
+	return identity.Identity{}, errInvalidToken
+}
+
+// newAuthenticators 按 auth.mode 组装鉴权链，返回空切片表示未开启鉴权。
+func newAuthenticators(cfg appconfig.Config) []authenticator {
+	var chain []authenticator
+	if cfg.Auth.Mode != appconfig.AuthModeAList {
+		if auth := newTokenAuth(cfg); auth != nil {
+			chain = append(chain, auth)
+		}
+	}
+	if cfg.Auth.Mode == appconfig.AuthModeAList || cfg.Auth.Mode == appconfig.AuthModeMixed {
+		chain = append(chain, newAListAuth(cfg.Auth.AList, nil))
+	}
+	return chain
+}
+
+// requestSecrets 提取请求携带的凭据：Authorization 头（兼容 `Bearer <token>` 与 AList 的裸 JWT）及 access_token 查询参数。
+func requestSecrets(c *gin.Context) (header, query string) {
+	header = strings.TrimSpace(c.GetHeader("Authorization"))
+	if strings.HasPrefix(header, "Bearer ") {
+		header = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	return header, strings.TrimSpace(c.Query("access_token"))
+}
+
+// authGate 持有当前鉴权链与限流状态，配置热更新时整体替换鉴权链；链为空表示未开启鉴权。
+type authGate struct {
+	chain   atomic.Pointer[[]authenticator]
+	limiter *limiter
+}
+
+func newAuthGate(cfg appconfig.Config) *authGate {
+	gate := &authGate{limiter: newLimiter(cfg.RateLimit)}
+	gate.reload(cfg)
+	return gate
+}
+
+func (g *authGate) reload(cfg appconfig.Config) {
+	chain := newAuthenticators(cfg)
+	g.chain.Store(&chain)
+	g.limiter.reload(cfg.RateLimit)
+}
+
+// authMiddleware 先检查 IP 锁定与按 IP 限流，再依次尝试鉴权链并按路由组检查权限与按身份限流：
+// 凭据无效返回 401（累计后锁定该 IP），权限不足返回 403，超出限流或被锁定返回 429，外部鉴权服务不可用返回 503。
+// 管理界面的静态文件（/admin/ui）无需令牌，见 isAdminUIAsset。
+func (g *authGate) authMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		group := rateGroupFor(c.Request.URL.Path)
+		if wait, locked := g.limiter.lockedFor(ip); locked {
+			abortTooManyRequests(c, wait, "too many invalid tokens, try again later")
+			return
+		}
+		if wait, ok := g.limiter.allowIP(group, ip); !ok {
+			abortTooManyRequests(c, wait, "rate limit exceeded")
+			return
+		}
+
+		chain := *g.chain.Load()
+		if len(chain) == 0 || isAdminUIAsset(c.Request) {
+			c.Next()
+			return
+		}
+		header, query := requestSecrets(c)
+		id, err := authenticate(c.Request.Context(), chain, header, query)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if !errors.Is(err, errInvalidToken) && !errors.Is(err, errTokenExpired) {
+				status = http.StatusServiceUnavailable
+			}
+			if status == http.StatusUnauthorized {
+				g.limiter.recordFailure(ip)
+			}
+			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		g.limiter.recordSuccess(ip)
+		if query != "" {
+			params := c.Request.URL.Query()
+			params.Del("access_token")
+			c.Request.URL.RawQuery = params.Encode()
+		}
+
+		if scope := RequiredScope(c.Request.URL.Path); !id.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token lacks scope " + scope})
+			return
+		}
+		if wait, ok := g.limiter.allowIdentity(group, id.Name); !ok {
+			abortTooManyRequests(c, wait, "rate limit exceeded")
+			return
+		}
+		identity.Set(c, id)
+		c.Next()
+	}
+}
+
+// authenticate 对每个凭据依次尝试鉴权链，返回首个成功的身份；全部失败时优先报告过期或服务不可用。
+func authenticate(ctx context.Context, chain []authenticator, secrets ...string) (identity.Identity, error) {
+	result := errInvalidToken
+	for i, secret := range secrets {
+		if secret == "" || (i > 0 && secret == secrets[i-1]) {
+			continue
+		}
+		for _, auth := range chain {
+			id, err := auth.authenticate(ctx, secret)
+			if err == nil {
+				return id, nil
+			}
+			if !errors.Is(err, errInvalidToken) {
+				result = err
+			}
+		}
+	}
+	return identity.Identity{}, result
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+type echoRegistrar struct{}
+
+func (echoRegistrar) Register(engine *gin.Engine) {
+	handler := func(c *gin.Context) {
+		c.String(http.StatusOK, identity.Name(c)+"|"+c.Request.URL.RawQuery)
+	}
+	engine.GET("/proxy/media", handler)
+	engine.POST("/sql/delete", handler)
+	engine.POST("/sql/query", handler)
+	engine.GET("/health", handler)
+}
+
+func TestScopedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := appconfig.Config{
+		AuthToken: "legacy",
+		Tokens: []appconfig.APIToken{
+			{Name: "friend-phone", SecretHash: appconfig.HashTokenSecret("friend"), Scopes: []string{appconfig.ScopeProxy}},
+			{Name: "reader", SecretHash: appconfig.HashTokenSecret("reader"), Scopes: []string{appconfig.ScopeSQLRead}},
+			{Name: "old", SecretHash: appconfig.HashTokenSecret("old"), Scopes: []string{appconfig.ScopeAdmin}, ExpiresAt: "2000-01-01T00:00:00Z"},
+		},
+	}
+	engine := NewRouter(cfg, echoRegistrar{})
+
+	cases := []struct {
+		method, path, bearer string
+		want                 int
+		body                 string
+	}{
+		{http.MethodGet, "/proxy/media?target=x&access_token=friend", "", http.StatusOK, "friend-phone|target=x"},
+		{http.MethodPost, "/sql/delete", "friend", http.StatusForbidden, ""},
+		{http.MethodPost, "/sql/query", "reader", http.StatusOK, "reader|"},
+		{http.MethodPost, "/sql/delete", "reader", http.StatusForbidden, ""},
+		{http.MethodGet, "/health", "friend", http.StatusOK, "friend-phone|"},
+		{http.MethodPost, "/sql/delete", "legacy", http.StatusOK, "default|"},
+		{http.MethodGet, "/health", "old", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/health", "nope", http.StatusUnauthorized, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		if tc.bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.bearer)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("%s %s with %q: expected %d, got %d (%s)", tc.method, tc.path, tc.bearer, tc.want, w.Code, w.Body.String())
+		}
+		if tc.body != "" && w.Body.String() != tc.body {
+			t.Fatalf("%s %s: unexpected body %q", tc.method, tc.path, w.Body.String())
+		}
+	}
+}
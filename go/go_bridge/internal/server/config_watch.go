@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// configDebounce 合并编辑器保存时产生的多次写入事件。
+const configDebounce = 300 * time.Millisecond
+
+// WatchConfig 监视配置文件 path 的修改并响应 SIGHUP：重新加载并校验配置后交给 target 应用，
+// 校验失败时保留当前配置继续运行；listen、driver、dsn 等需重启的字段变化只记录日志。
+// 阻塞直到 ctx 被取消。
+func WatchConfig(ctx context.Context, path string, current appconfig.Config, load func() (appconfig.Config, error), target Reloadable) {
+	trigger := make(chan string, 1)
+	notify := func(reason string) {
+		select {
+		case trigger <- reason:
+		default:
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	events, stopWatch := watchConfigFile(path)
+	defer stopWatch()
+
+	var debounce *time.Timer
+	defer func() {
+		if debounce != nil {
+			debounce.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			notify("SIGHUP")
+		case <-events:
+			if debounce != nil {
+				debounce.Stop()
+			}
+			debounce = time.AfterFunc(configDebounce, func() { notify("file change") })
+		case reason := <-trigger:
+			current = reloadConfig(current, load, target, reason)
+		}
+	}
+}
+
+// watchConfigFile 监视配置文件所在目录，以兼容编辑器“写临时文件再改名”及 Kubernetes ConfigMap 的符号链接切换。
+// 监视失败时仅记录日志，此时仍可通过 SIGHUP 触发重新加载。
+func watchConfigFile(path string) (<-chan struct{}, func()) {
+	changed := make(chan struct{}, 1)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		slog.Warn("config watch disabled", "error", err)
+		return changed, func() {}
+	}
+	dir, name := filepath.Split(filepath.Clean(path))
+	if dir == "" {
+		dir = "."
+	}
+	if err := watcher.Add(dir); err != nil {
+		slog.Warn("config watch disabled", "path", dir, "error", err)
+		watcher.Close()
+		return changed, func() {}
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				base := filepath.Base(event.Name)
+				if base != name && !strings.HasPrefix(base, "..data") {
+					continue
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("config watch error", "error", err)
+			}
+		}
+	}()
+	return changed, func() { watcher.Close() }
+}
+
+// reloadConfig 加载并应用新配置，返回此后作为比较基准的配置。
+func reloadConfig(current appconfig.Config, load func() (appconfig.Config, error), target Reloadable, reason string) appconfig.Config {
+	next, err := load()
+	if err != nil {
+		slog.Error("config reload rejected, keeping current config", "trigger", reason, "error", err)
+		return current
+	}
+	if reflect.DeepEqual(current, next) {
+		return current
+	}
+	if fields := current.RestartRequired(next); len(fields) > 0 {
+		slog.Warn("config reload: restart required for changed fields", "fields", strings.Join(fields, ","))
+	}
+	if err := target.Reload(next); err != nil {
+		slog.Error("config reload partially applied", "trigger", reason, "error", err)
+	} else {
+		slog.Info("config reloaded", "trigger", reason)
+	}
+	return next
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+func TestWatchConfigReloadsTokensOnFileChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	// 轮询新令牌期间会产生多次 401，关闭锁定以免干扰。
+	const noLockout = "rateLimit:\n  lockout:\n    maxFailures: -1\n"
+	if err := os.WriteFile(path, []byte("authToken: first\n"+noLockout), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	t.Setenv("GO_BRIDGE_CONFIG", path)
+
+	cfg, err := appconfig.Load(false)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	router := NewRouter(cfg, echoRegistrar{})
+	status := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go WatchConfig(ctx, path, cfg, func() (appconfig.Config, error) { return appconfig.Load(false) }, router)
+	time.Sleep(100 * time.Millisecond)
+
+	// 非法配置被拒绝，旧令牌继续有效。
+	if err := os.WriteFile(path, []byte("auth:\n  mode: bogus\n"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	time.Sleep(configDebounce + 300*time.Millisecond)
+	if code := status("first"); code != http.StatusOK {
+		t.Fatalf("invalid config must keep previous token, got %d", code)
+	}
+
+	if err := os.WriteFile(path, []byte("authToken: second\n"+noLockout), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for status("second") != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatalf("token was not reloaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if code := status("first"); code != http.StatusUnauthorized {
+		t.Fatalf("old token should be rejected after reload, got %d", code)
+	}
+}
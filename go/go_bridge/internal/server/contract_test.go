@@ -0,0 +1,171 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/audit"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/proxy"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/screenshot"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/sqlapi"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/modules/usage"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/openapi"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server"
+)
+
+// TestOpenAPIContract 调用每个 JSON 接口，校验实际响应与 /openapi.json 中声明的结构一致，
+// 处理器响应结构体改动而未同步文档时失败。
+func TestOpenAPIContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	sqlxDB := sqlx.NewDb(db, "sqlmock")
+	t.Cleanup(func() { _ = sqlxDB.Close() })
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"code":200,"data":{"files":[]}}`))
+	}))
+	t.Cleanup(upstream.Close)
+
+	dir := t.TempDir()
+	cfg := appconfig.Config{
+		AuthToken:     "secret",
+		ScreenshotDir: filepath.Join(dir, "screenshots"),
+		Audit:         appconfig.AuditConfig{FallbackFile: filepath.Join(dir, "audit.jsonl")},
+	}
+	auditRegistrar, err := audit.NewRegistrar(nil, cfg)
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	usageRegistrar, err := usage.NewRegistrar(nil, cfg)
+	if err != nil {
+		t.Fatalf("usage: %v", err)
+	}
+	t.Cleanup(func() { _ = usageRegistrar.Close(context.Background()) })
+	router := server.NewRouter(cfg,
+		auditRegistrar,
+		sqlapi.NewRegistrar(sqlxDB, cfg),
+		screenshot.NewRegistrar(sqlxDB, cfg),
+		usageRegistrar,
+		proxy.NewRegistrar(upstream.Client(), nil),
+	)
+
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodGet, "/openapi.json", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("openapi.json: %d %s", w.Code, w.Body.String())
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	if doc.OpenAPI != openapi.Version {
+		t.Fatalf("unexpected openapi version %q", doc.OpenAPI)
+	}
+	if got := doc.Paths["/sql/delete"]["post"]; got == nil || got.RequiredScope != appconfig.ScopeSQLWrite {
+		t.Fatalf("/sql/delete should require %s, got %+v", appconfig.ScopeSQLWrite, got)
+	}
+
+	mock.ExpectQuery(`SELECT id, name FROM t_user`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).AddRow(1, []byte("alice")))
+	mock.ExpectExec(`INSERT INTO t_user`).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(`UPDATE t_user SET`).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM t_user WHERE`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT COUNT\(1\) FROM t_historical_records`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+
+	image := `"` + base64Image + `"`
+	calls := []struct {
+		method, path, body string
+		specPath           string
+	}{
+		{http.MethodGet, "/health", "", ""},
+		{http.MethodPost, "/sql/query", `{"sql":"SELECT id, name FROM t_user"}`, ""},
+		{http.MethodPost, "/sql/insert", `{"table":"t_user","values":{"name":"bob"}}`, ""},
+		{http.MethodPost, "/sql/update", `{"table":"t_user","values":{"name":"carol"},"where":"id = @id","whereArgs":{"id":1}}`, ""},
+		{http.MethodPost, "/sql/delete", `{"table":"t_user","where":"id = @id","whereArgs":{"id":7}}`, ""},
+		{http.MethodPost, "/history/screenshot", `{"videoSha1":"abc123","userId":1,"imageBase64":` + image + `}`, ""},
+		{http.MethodPost, "/history/screenshot/sync", `{"dryRun":true}`, ""},
+		{http.MethodGet, "/proxy/api?target=" + upstream.URL + "/api/fs/list", "", "/proxy/api"},
+		{http.MethodGet, "/proxy/metrics", "", ""},
+		{http.MethodGet, "/admin/usage?period=day", "", "/admin/usage"},
+		{http.MethodGet, "/admin/audit", "", ""},
+		{http.MethodGet, "/admin/status", "", ""},
+		{http.MethodGet, "/admin/tokens", "", ""},
+	}
+	for _, call := range calls {
+		specPath := call.specPath
+		if specPath == "" {
+			specPath = call.path
+		}
+		schema, ok := doc.ResponseSchema(call.method, specPath, http.StatusOK)
+		if !ok {
+			t.Fatalf("%s %s is not described in the spec", call.method, specPath)
+		}
+		w := send(call.method, call.path, call.body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected 200, got %d (%s)", call.method, call.path, w.Code, w.Body.String())
+		}
+		var body any
+		if err := json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&body); err != nil {
+			t.Fatalf("%s %s: decode: %v", call.method, call.path, err)
+		}
+		if err := openapi.Validate(schema, body); err != nil {
+			t.Fatalf("%s %s drifted from the spec: %v\nbody: %s", call.method, call.path, err, w.Body.String())
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("expectations: %v", err)
+	}
+}
+
+// TestOpenAPIReflectsBuild 确认文档只包含实际编译进来的模块，例如仅代理构建不出现 SQL 接口。
+func TestOpenAPIReflectsBuild(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := appconfig.Config{AuthToken: "secret"}
+	router := server.NewRouter(cfg, sqlapi.NewRegistrar(nil, cfg), screenshot.NewRegistrar(nil, cfg), proxy.NewRegistrar(nil, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var doc openapi.Document
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decode spec: %v", err)
+	}
+	for path := range doc.Paths {
+		if strings.HasPrefix(path, "/sql/") || strings.HasPrefix(path, "/history/") || path == "/health" {
+			t.Fatalf("proxy-only spec must not describe %s", path)
+		}
+	}
+	if doc.Paths["/proxy/media"]["get"] == nil || doc.Paths["/openapi.json"]["get"] == nil {
+		t.Fatalf("proxy routes missing from spec: %v", doc.Paths)
+	}
+}
+
+// base64Image 为 1x1 PNG。
+const base64Image = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// corsPolicy 为 appconfig.CORSConfig 预先拼好的响应头。
+type corsPolicy struct {
+	anyOrigin bool
+	origins   map[string]bool
+	// wildcards 保存 `scheme://*.domain` 形式的来源，拆分为协议前缀与域名后缀。
+	wildcards   [][2]string
+	methods     string
+	anyHeader   bool
+	headers     string
+	exposed     string
+	credentials bool
+	maxAge      string
+}
+
+func newCORSPolicy(cfg appconfig.CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		origins:     map[string]bool{},
+		methods:     strings.Join(cfg.AllowedMethods, ", "),
+		exposed:     strings.Join(cfg.ExposedHeaders, ", "),
+		credentials: cfg.AllowCredentials,
+		maxAge:      strconv.Itoa(int(cfg.MaxAgeDuration().Seconds())),
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://")
+			p.wildcards = append(p.wildcards, [2]string{scheme + "://", strings.TrimPrefix(host, "*")})
+		default:
+			p.origins[origin] = true
+		}
+	}
+	var headers []string
+	for _, header := range cfg.AllowedHeaders {
+		if strings.TrimSpace(header) == "*" {
+			p.anyHeader = true
+			continue
+		}
+		headers = append(headers, http.CanonicalHeaderKey(strings.TrimSpace(header)))
+	}
+	p.headers = strings.Join(headers, ", ")
+	return p
+}
+
+// allows 判断来源是否命中策略，比较时忽略大小写。
+func (p *corsPolicy) allows(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, wildcard := range p.wildcards {
+		if strings.HasPrefix(origin, wildcard[0]) && strings.HasSuffix(origin, wildcard[1]) &&
+			len(origin) > len(wildcard[0])+len(wildcard[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// corsGate 持有当前跨域策略，配置热更新时整体替换。
+type corsGate struct {
+	policy atomic.Pointer[corsPolicy]
+}
+
+func newCORSGate(cfg appconfig.Config) *corsGate {
+	gate := &corsGate{}
+	gate.reload(cfg)
+	return gate
+}
+
+func (g *corsGate) reload(cfg appconfig.Config) {
+	g.policy.Store(newCORSPolicy(cfg.CORS))
+}
+
+// middleware 为跨域请求写入 CORS 响应头，并在鉴权之前直接应答已注册路由的预检请求
+// （浏览器预检不会携带 Authorization）；来源不在白名单时不写入 Allow-Origin，由浏览器拦截。
+func (g *corsGate) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		p := g.policy.Load()
+		headers := c.Writer.Header()
+		headers.Add("Vary", "Origin")
+		allowed := p.allows(origin)
+		if allowed {
+			if p.anyOrigin && !p.credentials {
+				headers.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				headers.Set("Access-Control-Allow-Origin", origin)
+			}
+			if p.credentials {
+				headers.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		// 未注册的路径交给 gin 返回 404。
+		if !preflight || c.FullPath() == "" {
+			if allowed && p.exposed != "" {
+				headers.Set("Access-Control-Expose-Headers", p.exposed)
+			}
+			c.Next()
+			return
+		}
+		headers.Add("Vary", "Access-Control-Request-Method")
+		headers.Add("Vary", "Access-Control-Request-Headers")
+		if allowed {
+			headers.Set("Access-Control-Allow-Methods", p.methods)
+			allowHeaders := p.headers
+			if p.anyHeader {
+				allowHeaders = c.GetHeader("Access-Control-Request-Headers")
+			}
+			if allowHeaders != "" {
+				headers.Set("Access-Control-Allow-Headers", allowHeaders)
+			}
+			headers.Set("Access-Control-Max-Age", p.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// registerPreflightRoutes 为每个已注册的路径补充 OPTIONS 路由，使预检请求能匹配到路由；
+// 实际应答由 corsGate.middleware 完成，未携带预检头的 OPTIONS 请求直接返回 204。
+func registerPreflightRoutes(engine *gin.Engine) {
+	seen := map[string]bool{}
+	for _, route := range engine.Routes() {
+		if route.Method == http.MethodOptions {
+			seen[route.Path] = true
+		}
+	}
+	for _, route := range engine.Routes() {
+		if seen[route.Path] {
+			continue
+		}
+		seen[route.Path] = true
+		engine.OPTIONS(route.Path, func(c *gin.Context) {
+			c.Status(http.StatusNoContent)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+func TestCORSPreflightBypassesAuthForRegisteredRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := appconfig.Config{
+		AuthToken: "secret",
+		CORS: appconfig.CORSConfig{
+			AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+			AllowedMethods:   []string{"GET", "POST"},
+			AllowedHeaders:   []string{"authorization", "content-type"},
+			ExposedHeaders:   []string{"X-Request-ID"},
+			AllowCredentials: true,
+			MaxAge:           "5m",
+		},
+	}
+	router := NewRouter(cfg, echoRegistrar{})
+	send := func(method, path, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "authorization")
+		} else {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodOptions, "/sql/query", "https://app.example.com", true)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight: expected 204, got %d", w.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Authorization, Content-Type",
+		"Access-Control-Max-Age":           "300",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Fatalf("%s: expected %q, got %q", header, want, got)
+		}
+	}
+
+	if w := send(http.MethodOptions, "/proxy/media", "https://tv.example.org", true); w.Header().Get("Access-Control-Allow-Origin") != "https://tv.example.org" {
+		t.Fatalf("wildcard subdomain should be allowed, got %q", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w := send(http.MethodOptions, "/sql/query", "https://evil.test", true); w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed origin must not be granted, got %d %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+	if w := send(http.MethodOptions, "/not-registered", "https://app.example.com", true); w.Code == http.StatusNoContent ||
+		w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Fatalf("unregistered route preflight must not be answered, got %d", w.Code)
+	}
+
+	w = send(http.MethodPost, "/sql/delete", "https://app.example.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "X-Request-ID" {
+		t.Fatalf("actual request: %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,75 @@
+package identity
+
+import "github.com/gin-gonic/gin"
+
+// contextKey 为鉴权中间件写入 gin.Context 的调用方身份键名。
+const contextKey = "bridge.identity"
+
+// Anonymous 为未开启鉴权时的默认身份名称。
+const Anonymous = "anonymous"
+
+// Identity 描述通过鉴权的调用方，仅包含名称等非敏感信息，绝不携带令牌明文。
+type Identity struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes,omitempty"`
+	// UserID 为令牌绑定的业务用户，nil 表示未绑定。
+	UserID *int64 `json:"userId,omitempty"`
+	// Admin 表示调用方为管理员（如 AList 管理员角色），拥有全部权限。
+	Admin bool `json:"admin,omitempty"`
+}
+
+// adminScope 与 appconfig.ScopeAdmin 一致，拥有该权限即视为拥有全部权限。
+const adminScope = "admin"
+
+// HasScope 判断身份是否拥有指定权限；scope 为空表示仅需通过鉴权。
+func (id Identity) HasScope(scope string) bool {
+	if scope == "" || id.Admin {
+		return true
+	}
+	for _, s := range id.Scopes {
+		if s == scope || s == adminScope {
+			return true
+		}
+	}
+	return false
+}
+
+// Set 将调用方身份写入请求上下文，供后续模块做计量与授权。
+func Set(c *gin.Context, id Identity) {
+	c.Set(contextKey, id)
+}
+
+// From 读取请求上下文中的调用方身份。
+func From(c *gin.Context) (Identity, bool) {
+	value, ok := c.Get(contextKey)
+	if !ok {
+		return Identity{}, false
+	}
+	id, ok := value.(Identity)
+	return id, ok
+}
+
+// Name 返回调用方身份名称，未鉴权时返回 Anonymous。
+func Name(c *gin.Context) string {
+	if id, ok := From(c); ok && id.Name != "" {
+		return id.Name
+	}
+	return Anonymous
+}
+
+// RestrictedUser 返回需要做行级隔离的用户 id：仅当身份绑定了用户且不具备管理员权限时 ok=true。
+func (id Identity) RestrictedUser() (int64, bool) {
+	if id.UserID == nil || id.HasScope(adminScope) {
+		return 0, false
+	}
+	return *id.UserID, true
+}
+
+// RestrictedUser 读取请求上下文中的身份并返回需要隔离的用户 id。
+func RestrictedUser(c *gin.Context) (int64, bool) {
+	id, ok := From(c)
+	if !ok {
+		return 0, false
+	}
+	return id.RestrictedUser()
+}
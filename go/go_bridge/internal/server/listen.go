@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/tlsutil"
+)
+
+// Closer 为需要在连接排空后释放资源的模块（如代理指标轮询、流量计量落库）。
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// drainLogInterval 为排空期间打印进度的间隔。
+const drainLogInterval = 2 * time.Second
+
+// inflightHandler 统计进行中的请求，排空时按类别输出剩余数量。
+type inflightHandler struct {
+	next       http.Handler
+	streams    atomic.Int64
+	syncs      atomic.Int64
+	others     atomic.Int64
+	total      atomic.Int64
+	rejectNew  atomic.Bool
+	retryAfter string
+}
+
+func (h *inflightHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// Shutdown 已关闭监听，但 keep-alive 连接上仍可能到达新请求，此时直接拒绝。
+	if h.rejectNew.Load() {
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Retry-After", h.retryAfter)
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte(`{"error":"server is shutting down"}`))
+		return
+	}
+	counter := &h.others
+	switch {
+	case strings.HasPrefix(req.URL.Path, "/proxy/"):
+		counter = &h.streams
+	case req.URL.Path == "/history/screenshot/sync":
+		counter = &h.syncs
+	}
+	counter.Add(1)
+	h.total.Add(1)
+	defer func() {
+		counter.Add(-1)
+		h.total.Add(-1)
+	}()
+	h.next.ServeHTTP(w, req)
+}
+
+// progress 返回各类进行中请求的数量，作为日志属性输出。
+func (h *inflightHandler) progress() []any {
+	return []any{
+		"in_flight", h.total.Load(),
+		"proxy", h.streams.Load(),
+		"screenshot_sync", h.syncs.Load(),
+		"other", h.others.Load(),
+	}
+}
+
+// Serve 按配置在 listen 中的全部地址（TCP、unix:// 套接字或 systemd:// 传入的套接字）上启动服务，
+// TCP 监听按配置启用 HTTPS（可选 mTLS），直到 ctx 被取消（通常为 SIGINT/SIGTERM）。
+// 退出时先停止接受新连接，等待进行中的播放流与截图同步在 shutdownTimeout 内结束，
+// 超时后强制断开，最后依次关闭 closers。
+func Serve(ctx context.Context, cfg appconfig.Config, handler http.Handler, closers ...Closer) error {
+	timeout := cfg.ShutdownTimeoutDuration()
+	tracked := &inflightHandler{next: localPeerHandler{next: handler}, retryAfter: fmt.Sprint(int(timeout.Seconds()))}
+	srv := &http.Server{
+		Handler:  tracked,
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+
+	var reloader *tlsutil.CertReloader
+	listeners, err := openListeners(cfg)
+	if err == nil && cfg.TLS.Enabled() {
+		var tlsCfg *tls.Config
+		if tlsCfg, reloader, err = listenerTLS(cfg.TLS); err != nil {
+			for _, ln := range listeners {
+				_ = ln.Close()
+			}
+		}
+		srv.TLSConfig = tlsCfg
+	}
+	if err != nil {
+		closeAll(closers)
+		return err
+	}
+
+	serveErr := make(chan error, len(listeners))
+	for _, ln := range listeners {
+		useTLS := ln.tls && srv.TLSConfig != nil
+		slog.Info("serving", "listen", ln.addr, "addr", ln.Addr().String(), "tls", useTLS)
+		go func(ln boundListener) {
+			if useTLS {
+				serveErr <- srv.ServeTLS(ln, "", "")
+				return
+			}
+			serveErr <- srv.Serve(ln)
+		}(ln)
+	}
+
+	select {
+	case err = <-serveErr:
+		// 任一监听异常退出时关闭其余监听，并同样释放后台资源。
+		_ = srv.Close()
+	case <-ctx.Done():
+		err = drain(srv, tracked, timeout)
+	}
+	if reloader != nil {
+		reloader.Stop()
+	}
+	closeAll(closers)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// closeAll 依次关闭 closers，单个失败只记录日志。
+func closeAll(closers []Closer) {
+	closeCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, closer := range closers {
+		if closer == nil {
+			continue
+		}
+		if cerr := closer.Close(closeCtx); cerr != nil {
+			slog.Error("shutdown: close failed", "module", fmt.Sprintf("%T", closer), "error", cerr)
+		}
+	}
+}
+
+// drain 关闭监听并等待进行中的请求结束，期间定期打印剩余数量。
+func drain(srv *http.Server, tracked *inflightHandler, timeout time.Duration) error {
+	slog.Info("shutdown: stop accepting connections", append(tracked.progress(), "timeout", timeout.String())...)
+	tracked.rejectNew.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- srv.Shutdown(shutdownCtx) }()
+
+	ticker := time.NewTicker(drainLogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			if errors.Is(err, context.DeadlineExceeded) {
+				slog.Warn("shutdown: drain timeout reached, forcing close", tracked.progress()...)
+				_ = srv.Close()
+				return nil
+			}
+			slog.Info("shutdown: all connections drained")
+			return err
+		case <-ticker.C:
+			slog.Info("shutdown: draining", tracked.progress()...)
+		}
+	}
+}
+
+// listenerTLS 构建监听端 TLS 配置；selfSigned 时若证书不存在先生成自签名证书。
+func listenerTLS(cfg appconfig.ListenerTLS) (*tls.Config, *tlsutil.CertReloader, error) {
+	if cfg.SelfSigned {
+		created, err := tlsutil.EnsureSelfSigned(cfg.CertFile, cfg.KeyFile, cfg.Hosts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generate self-signed certificate: %w", err)
+		}
+		if created {
+			slog.Info("generated self-signed certificate", "cert_file", cfg.CertFile)
+		}
+	}
+	reloader, err := tlsutil.NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load tls certificate: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if cfg.ClientCAFile != "" {
+		pool, err := tlsutil.LoadCAPool(cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("load client ca: %w", err)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.ClientAuth == appconfig.ClientAuthVerifyIfGiven {
+			tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	reloader.Watch(cfg.ReloadIntervalDuration())
+	return tlsCfg, reloader, nil
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+type closeRecorder struct{ closed chan struct{} }
+
+func (c *closeRecorder) Close(context.Context) error {
+	close(c.closed)
+	return nil
+}
+
+func TestServeDrainsInflightStreamsBeforeClosing(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	closer := &closeRecorder{closed: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, appconfig.Config{Listen: addr, ShutdownTimeout: "5s"}, handler, closer)
+	}()
+
+	status := make(chan int, 1)
+	go func() {
+		for i := 0; i < 50; i++ {
+			resp, err := http.Get("http://" + addr + "/proxy/media")
+			if err == nil {
+				resp.Body.Close()
+				status <- resp.StatusCode
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		status <- 0
+	}()
+	<-started
+	cancel()
+
+	select {
+	case <-closer.closed:
+		t.Fatalf("closers must wait for in-flight streams")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+
+	if code := <-status; code != http.StatusNoContent {
+		t.Fatalf("in-flight stream should complete, got %d", code)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	select {
+	case <-closer.closed:
+	default:
+		t.Fatalf("closer not called")
+	}
+}
+
+func TestServeOnTCPAndUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket permissions are not meaningful on windows")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	sock := filepath.Join(t.TempDir(), "bridge.sock")
+	// 残留的套接字文件（上次进程异常退出）应被清理。
+	stale, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.RemoteAddr)
+	})
+	cfg := appconfig.Config{
+		Listen:     addr + ", unix://" + sock,
+		UnixSocket: appconfig.UnixSocket{Mode: "0600"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- Serve(ctx, cfg, handler) }()
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+	get := func(client *http.Client, url string) string {
+		for i := 0; i < 50; i++ {
+			resp, err := client.Get(url)
+			if err == nil {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				return string(body)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("GET %s never succeeded", url)
+		return ""
+	}
+	if got := get(http.DefaultClient, "http://"+addr+"/health"); !strings.HasPrefix(got, "127.0.0.1:") {
+		t.Fatalf("tcp remote addr: %q", got)
+	}
+	if got := get(unixClient, "http://bridge/health"); got != "127.0.0.1:0" {
+		t.Fatalf("unix peers should be treated as loopback, got %q", got)
+	}
+	info, err := os.Stat(sock)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("socket mode: expected 0600, got %o", perm)
+	}
+	unixClient.CloseIdleConnections()
+	http.DefaultClient.CloseIdleConnections()
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+	if _, err := os.Stat(sock); !os.IsNotExist(err) {
+		t.Fatalf("socket file should be removed on shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestInheritedListeners(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	file, err := ln.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatalf("file: %v", err)
+	}
+	defer file.Close()
+	// inheritedListeners 会关闭传入的描述符，复制一份模拟 systemd 传入的 fd。
+	fd, err := syscall.Dup(int(file.Fd()))
+	if err != nil {
+		t.Fatalf("dup: %v", err)
+	}
+
+	env := map[string]string{"LISTEN_PID": "42", "LISTEN_FDS": "1", "LISTEN_FDNAMES": "http"}
+	getenv := func(key string) string { return env[key] }
+	if _, err := inheritedListeners(getenv, 7, fd); err == nil {
+		t.Fatalf("sockets passed to another pid must be ignored")
+	}
+	inherited, err := inheritedListeners(getenv, 42, fd)
+	if err != nil {
+		t.Fatalf("inherit: %v", err)
+	}
+	defer inherited[0].Close()
+	if len(inherited) != 1 || inherited[0].name != "http" || inherited[0].Addr().String() != ln.Addr().String() {
+		t.Fatalf("unexpected inherited listeners: %+v", inherited)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// systemdFirstFD 为 systemd 套接字激活传入的第一个文件描述符（SD_LISTEN_FDS_START）。
+const systemdFirstFD = 3
+
+// boundListener 为一个已打开的监听；unix 套接字只对本机可见并由文件权限保护，不套用 TLS。
+type boundListener struct {
+	net.Listener
+	addr string
+	tls  bool
+}
+
+// openListeners 按 listen 中的全部地址打开监听，任一地址失败时关闭已打开的监听并返回错误。
+func openListeners(cfg appconfig.Config) ([]boundListener, error) {
+	var opened []boundListener
+	fail := func(err error) ([]boundListener, error) {
+		for _, ln := range opened {
+			_ = ln.Close()
+		}
+		return nil, err
+	}
+	for _, addr := range cfg.ListenAddrs() {
+		switch {
+		case strings.HasPrefix(addr, appconfig.ListenUnixPrefix):
+			ln, err := listenUnix(strings.TrimPrefix(addr, appconfig.ListenUnixPrefix), cfg.UnixSocket)
+			if err != nil {
+				return fail(err)
+			}
+			opened = append(opened, boundListener{Listener: ln, addr: addr})
+		case strings.HasPrefix(addr, appconfig.ListenSystemdPrefix):
+			inherited, err := systemdListeners()
+			if err != nil {
+				return fail(err)
+			}
+			name := strings.TrimPrefix(addr, appconfig.ListenSystemdPrefix)
+			matched := 0
+			for _, ln := range inherited {
+				if name != "" && ln.name != name {
+					continue
+				}
+				matched++
+				_, isUnix := ln.Listener.(*net.UnixListener)
+				opened = append(opened, boundListener{Listener: ln.Listener, addr: addr, tls: !isUnix})
+			}
+			if matched == 0 {
+				return fail(fmt.Errorf("listen %s: no matching socket passed by systemd (LISTEN_FDS)", addr))
+			}
+		default:
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return fail(err)
+			}
+			opened = append(opened, boundListener{Listener: ln, addr: addr, tls: true})
+		}
+	}
+	if len(opened) == 0 {
+		return nil, errors.New("no listen address configured")
+	}
+	return opened, nil
+}
+
+// listenUnix 创建 Unix 域套接字并设置权限；残留的套接字文件若无进程监听则先删除。
+func listenUnix(path string, opts appconfig.UnixSocket) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create socket dir: %w", err)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&fs.ModeSocket == 0 {
+			return nil, fmt.Errorf("listen unix %s: path exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("listen unix %s: socket is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("remove stale socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, opts.FileMode()); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("chmod socket: %w", err)
+	}
+	if opts.Group != "" {
+		gid, err := lookupGroup(opts.Group)
+		if err == nil {
+			err = os.Chown(path, -1, gid)
+		}
+		if err != nil {
+			_ = ln.Close()
+			return nil, fmt.Errorf("chown socket to group %s: %w", opts.Group, err)
+		}
+	}
+	return ln, nil
+}
+
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// namedListener 为 systemd 传入的监听及其在 LISTEN_FDNAMES 中的名称。
+type namedListener struct {
+	net.Listener
+	name string
+}
+
+var (
+	systemdOnce   sync.Once
+	systemdResult []namedListener
+	systemdErr    error
+)
+
+// systemdListeners 读取 systemd 套接字激活传入的监听，只解析一次并清除相关环境变量，避免子进程误用。
+func systemdListeners() ([]namedListener, error) {
+	systemdOnce.Do(func() {
+		systemdResult, systemdErr = inheritedListeners(os.Getenv, os.Getpid(), systemdFirstFD)
+		for _, key := range []string{"LISTEN_PID", "LISTEN_FDS", "LISTEN_FDNAMES"} {
+			_ = os.Unsetenv(key)
+		}
+	})
+	return systemdResult, systemdErr
+}
+
+// inheritedListeners 按 sd_listen_fds(3) 的约定解析 LISTEN_PID、LISTEN_FDS 与 LISTEN_FDNAMES，
+// 从 firstFD 开始将文件描述符转换为监听。
+func inheritedListeners(getenv func(string) string, pid, firstFD int) ([]namedListener, error) {
+	if getenv("LISTEN_PID") != strconv.Itoa(pid) {
+		return nil, errors.New("LISTEN_PID is not set for this process")
+	}
+	count, err := strconv.Atoi(getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", getenv("LISTEN_FDS"))
+	}
+	names := strings.Split(getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]namedListener, 0, count)
+	for i := range count {
+		name := "LISTEN_FD_" + strconv.Itoa(firstFD+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(firstFD+i), name)
+		ln, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("systemd socket %s: %w", name, err)
+		}
+		listeners = append(listeners, namedListener{Listener: ln, name: name})
+	}
+	return listeners, nil
+}
+
+// localPeerHandler 将 Unix 套接字连接（RemoteAddr 无端口）视为来自本机回环地址，限流与锁定不会把所有本机调用方合并为空 IP；
+// trustedProxies 包含 127.0.0.1 时，ClientIP 还会采信经套接字接入的本机反向代理写入的 X-Forwarded-For。
+type localPeerHandler struct {
+	next http.Handler
+}
+
+func (h localPeerHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if _, _, err := net.SplitHostPort(req.RemoteAddr); err != nil {
+		req.RemoteAddr = "127.0.0.1:0"
+	}
+	h.next.ServeHTTP(w, req)
+}
@@ -0,0 +1,227 @@
+package server
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+// limiterSweepInterval 为清理空闲令牌桶与过期锁定记录的最小间隔。
+const limiterSweepInterval = time.Minute
+
+// 参与限流的路由组，与 appconfig.RateLimitConfig 中的预算一一对应。
+const (
+	rateGroupProxy      = "proxy"
+	rateGroupSQL        = "sql"
+	rateGroupScreenshot = "screenshot"
+)
+
+// rateGroups 按路径前缀划分限流组；未命中的路由（如 /health、/admin/）不限流，但仍受无效令牌锁定约束。
+var rateGroups = []struct {
+	prefix string
+	group  string
+}{
+	{prefix: "/history/screenshot", group: rateGroupScreenshot},
+	{prefix: "/proxy/", group: rateGroupProxy},
+	{prefix: "/sql/", group: rateGroupSQL},
+}
+
+func rateGroupFor(path string) string {
+	for _, rule := range rateGroups {
+		if strings.HasPrefix(path, rule.prefix) {
+			return rule.group
+		}
+	}
+	return ""
+}
+
+// tokenBucket 为单个 IP 或身份在某个路由组内的令牌桶。
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	rate   float64
+	burst  float64
+}
+
+// lockoutState 记录单个 IP 的无效令牌次数与逐级递增的锁定。
+type lockoutState struct {
+	failures    int
+	windowStart time.Time
+	level       int
+	lockedUntil time.Time
+	lastFailure time.Time
+}
+
+// limiter 在内存中维护各路由组的令牌桶与无效令牌锁定，配置热更新时保留已有状态。
+type limiter struct {
+	mu        sync.Mutex
+	cfg       appconfig.RateLimitConfig
+	buckets   map[string]*tokenBucket
+	lockouts  map[string]*lockoutState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newLimiter(cfg appconfig.RateLimitConfig) *limiter {
+	return &limiter{
+		cfg:      cfg,
+		buckets:  map[string]*tokenBucket{},
+		lockouts: map[string]*lockoutState{},
+		now:      time.Now,
+	}
+}
+
+func (l *limiter) reload(cfg appconfig.RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg = cfg
+}
+
+func (l *limiter) budget(group string) appconfig.RateBudget {
+	switch group {
+	case rateGroupProxy:
+		return l.cfg.Proxy
+	case rateGroupSQL:
+		return l.cfg.SQL
+	case rateGroupScreenshot:
+		return l.cfg.Screenshot
+	}
+	return appconfig.RateBudget{PerIP: -1, PerIdentity: -1}
+}
+
+// allowIP 按客户端 IP 扣减路由组预算，超出时返回需要等待的时长。
+func (l *limiter) allowIP(group, ip string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	budget := l.budget(group)
+	return l.take(group+"|ip|"+ip, budget.PerIP, budget.Burst)
+}
+
+// allowIdentity 按调用方身份扣减路由组预算，同一令牌在多个 IP 上共享该预算。
+func (l *limiter) allowIdentity(group, name string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	budget := l.budget(group)
+	return l.take(group+"|id|"+name, budget.PerIdentity, budget.Burst)
+}
+
+// take 需持有锁调用；rate 不为正数时不限流。
+func (l *limiter) take(key string, rate float64, burst int) (time.Duration, bool) {
+	if rate <= 0 {
+		return 0, true
+	}
+	now := l.now()
+	l.sweep(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: float64(burst), last: now}
+		l.buckets[key] = b
+	}
+	// 预算热更新后按新的速率与容量继续计算。
+	b.rate, b.burst = rate, float64(burst)
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return wait, false
+}
+
+// lockedFor 返回 IP 剩余的锁定时长。
+func (l *limiter) lockedFor(ip string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	state, ok := l.lockouts[ip]
+	if !ok {
+		return 0, false
+	}
+	remaining := state.lockedUntil.Sub(l.now())
+	return remaining, remaining > 0
+}
+
+// recordFailure 记录一次无效令牌；window 内达到阈值后锁定该 IP，每次重新触发锁定时长翻倍。
+func (l *limiter) recordFailure(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cfg := l.cfg.Lockout
+	// 未经 appconfig 归一化的配置（maxFailures 为 0）同样视为关闭锁定。
+	if cfg.MaxFailures <= 0 {
+		return
+	}
+	now := l.now()
+	l.sweep(now)
+	state, ok := l.lockouts[ip]
+	if !ok {
+		state = &lockoutState{}
+		l.lockouts[ip] = state
+	}
+	// 距上次失败超过最长锁定时长后，锁定等级从头计算。
+	if !state.lastFailure.IsZero() && now.Sub(state.lastFailure) > cfg.MaxDurationValue() {
+		state.level = 0
+	}
+	if now.Sub(state.windowStart) > cfg.WindowDuration() {
+		state.failures = 0
+		state.windowStart = now
+	}
+	state.failures++
+	state.lastFailure = now
+	if state.failures < cfg.MaxFailures {
+		return
+	}
+
+	duration := cfg.BaseDurationValue()
+	for i := 0; i < state.level && duration < cfg.MaxDurationValue(); i++ {
+		duration *= 2
+	}
+	duration = min(duration, cfg.MaxDurationValue())
+	state.level++
+	state.failures = 0
+	state.windowStart = now
+	state.lockedUntil = now.Add(duration)
+	slog.Warn("client locked out after invalid tokens", "client_ip", ip, "duration", duration.String(), "level", state.level)
+}
+
+// recordSuccess 在鉴权成功后清零失败次数，锁定等级保留到自然衰减。
+func (l *limiter) recordSuccess(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if state, ok := l.lockouts[ip]; ok {
+		state.failures = 0
+	}
+}
+
+// sweep 需持有锁调用，每隔 limiterSweepInterval 移除已回满的令牌桶与过期的锁定记录。
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < limiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last).Seconds()*b.rate >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+	idle := max(l.cfg.Lockout.WindowDuration(), l.cfg.Lockout.MaxDurationValue())
+	for ip, state := range l.lockouts {
+		if now.After(state.lockedUntil) && now.Sub(state.lastFailure) > idle {
+			delete(l.lockouts, ip)
+		}
+	}
+}
+
+// abortTooManyRequests 返回 429，Retry-After 向上取整到秒。
+func abortTooManyRequests(c *gin.Context, wait time.Duration, message string) {
+	seconds := max(int(math.Ceil(wait.Seconds())), 1)
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+)
+
+func TestRateLimitPerIPAndIdentity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := appconfig.Config{
+		Tokens: []appconfig.APIToken{
+			{Name: "reader", SecretHash: appconfig.HashTokenSecret("reader"), Scopes: []string{appconfig.ScopeSQLRead}},
+		},
+		RateLimit: appconfig.RateLimitConfig{
+			SQL: appconfig.RateBudget{PerIP: 1, PerIdentity: 1, Burst: 2},
+		},
+	}
+	router := NewRouter(cfg, echoRegistrar{})
+	send := func(method, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("Authorization", "Bearer reader")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := send(http.MethodPost, "/sql/query", "192.0.2.1")
+		if w.Code != want {
+			t.Fatalf("request %d: expected %d, got %d", i, want, w.Code)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+			t.Fatalf("expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+		}
+	}
+	// 换一个 IP 仍受同一身份的预算约束。
+	if w := send(http.MethodPost, "/sql/query", "192.0.2.2"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("identity budget should apply across IPs, got %d", w.Code)
+	}
+	// 未划入路由组的接口不限流。
+	if w := send(http.MethodGet, "/health", "192.0.2.1"); w.Code != http.StatusOK {
+		t.Fatalf("health should not be rate limited, got %d", w.Code)
+	}
+}
+
+func TestLockoutEscalatesAfterInvalidTokens(t *testing.T) {
+	now := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	l := newLimiter(appconfig.RateLimitConfig{Lockout: appconfig.LockoutConfig{
+		MaxFailures:  3,
+		Window:       "10m",
+		BaseDuration: "1m",
+		MaxDuration:  "3m",
+	}})
+	l.now = func() time.Time { return now }
+
+	fail := func(times int) {
+		for range times {
+			l.recordFailure("192.0.2.1")
+		}
+	}
+	fail(2)
+	if _, locked := l.lockedFor("192.0.2.1"); locked {
+		t.Fatalf("should not lock before reaching maxFailures")
+	}
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute} {
+		fail(3)
+		wait, locked := l.lockedFor("192.0.2.1")
+		if !locked || wait != want {
+			t.Fatalf("expected lockout %s, got %s (locked=%v)", want, wait, locked)
+		}
+		now = now.Add(wait)
+	}
+	if _, locked := l.lockedFor("192.0.2.2"); locked {
+		t.Fatalf("lockout must be per IP")
+	}
+
+	// 长时间无失败后锁定等级与记录被清理。
+	now = now.Add(time.Hour)
+	l.recordSuccess("192.0.2.1")
+	l.sweep(now)
+	if len(l.lockouts) != 0 {
+		t.Fatalf("expected stale lockouts to be swept, got %d", len(l.lockouts))
+	}
+}
+
+func TestLockoutIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := appconfig.Config{
+		AuthToken: "secret",
+		RateLimit: appconfig.RateLimitConfig{Lockout: appconfig.LockoutConfig{MaxFailures: 2, Window: "10m", BaseDuration: "1m", MaxDuration: "1h"}},
+	}
+	send := func(router *Router, peer, forwarded string) int {
+		req := httptest.NewRequest(http.MethodGet, "/health", nil)
+		req.RemoteAddr = peer + ":1234"
+		req.Header.Set("Authorization", "Bearer wrong")
+		req.Header.Set("X-Forwarded-For", forwarded)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// 未配置 trustedProxies 时每次更换 X-Forwarded-For 也无法绕过对端地址的锁定。
+	router := NewRouter(cfg, echoRegistrar{})
+	for i, want := range []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests} {
+		if code := send(router, "192.0.2.1", fmt.Sprintf("198.51.100.%d", i+1)); code != want {
+			t.Fatalf("request %d: expected %d, got %d", i, want, code)
+		}
+	}
+
+	// 可信反向代理转发的请求按其写入的客户端地址分别计数。
+	cfg.TrustedProxies = []string{"127.0.0.1"}
+	router = NewRouter(cfg, echoRegistrar{})
+	for i := range 3 {
+		if code := send(router, "127.0.0.1", fmt.Sprintf("198.51.100.%d", i+1)); code != http.StatusUnauthorized {
+			t.Fatalf("request %d via trusted proxy: expected 401, got %d", i, code)
+		}
+	}
+}
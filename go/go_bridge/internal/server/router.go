@@ -1,12 +1,15 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/logging"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/openapi"
 )
 
 // RouteRegistrar 抽象每个功能模块的路由注册行为。
@@ -14,36 +17,122 @@ type RouteRegistrar interface {
 	Register(r *gin.Engine)
 }
 
-// NewRouter 基于公共配置创建 gin 引擎，并应用鉴权及模块路由。
-func NewRouter(cfg appconfig.Config, registrars ...RouteRegistrar) *gin.Engine {
-	r := gin.Default()
-
-	if cfg.AuthToken != "" {
-		expected := "Bearer " + cfg.AuthToken
-		r.Use(func(c *gin.Context) {
-			auth := c.GetHeader("Authorization")
-			tokenQuery := strings.TrimSpace(c.Query("access_token"))
-
-			if auth == expected || tokenQuery == cfg.AuthToken {
-				if tokenQuery == cfg.AuthToken {
-					params := c.Request.URL.Query()
-					params.Del("access_token")
-					c.Request.URL.RawQuery = params.Encode()
-				}
-				c.Next()
-				return
-			}
-
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
-		})
+// MiddlewareProvider 为需要拦截全部请求的模块（如流量计量）提供的可选扩展，
+// 中间件挂载在鉴权之后、路由注册之前，因此可读取调用方身份。
+type MiddlewareProvider interface {
+	Middleware() gin.HandlerFunc
+}
+
+// Reloadable 为支持配置热更新的模块提供的可选扩展，返回错误时保留该模块的旧配置。
+type Reloadable interface {
+	Reload(cfg appconfig.Config) error
+}
+
+// APIDescriber 为模块描述自身接口的可选扩展，汇总后由 /openapi.json 输出；
+// 文档只包含当前构建（完整模式或 proxy_only）实际挂载的模块。
+type APIDescriber interface {
+	APIRoutes() []openapi.Route
+}
+
+// Router 为挂载了鉴权与模块路由的 gin 引擎，并负责将热更新后的配置分发给各模块。
+type Router struct {
+	*gin.Engine
+	auth       *authGate
+	cors       *corsGate
+	status     *statusGate
+	registrars []RouteRegistrar
+}
+
+// NewRouter 基于公共配置创建 gin 引擎，并应用跨域策略、鉴权及模块路由。
+func NewRouter(cfg appconfig.Config, registrars ...RouteRegistrar) *Router {
+	r := gin.New()
+	// gin 默认信任所有对端的 X-Forwarded-For，调用方可逐个请求伪造来源绕过按 IP 限流与锁定；
+	// 只采信 trustedProxies 中的反向代理，未配置时 ClientIP 即连接的对端地址。格式已由 appconfig 校验。
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		_ = r.SetTrustedProxies(nil)
 	}
+	// 访问日志位于最外层，鉴权失败的请求同样会被记录。
+	r.Use(logging.Middleware(), logging.Recovery())
+
+	// 跨域策略位于鉴权之前，预检请求无需携带令牌。
+	cors := newCORSGate(cfg)
+	r.Use(cors.middleware())
 
+	// 鉴权中间件始终挂载，热更新后新增的令牌无需重启即可生效。
+	auth := newAuthGate(cfg)
+	r.Use(auth.authMiddleware())
+
+	for _, registrar := range registrars {
+		if provider, ok := registrar.(MiddlewareProvider); ok {
+			r.Use(provider.Middleware())
+		}
+	}
 	for _, registrar := range registrars {
 		if registrar == nil {
 			continue
 		}
 		registrar.Register(r)
 	}
+	status := newStatusGate(cfg, registrars)
+	status.register(r)
+	registerAdminUI(r)
+	registerOpenAPI(r, registrars)
+	registerPreflightRoutes(r)
+
+	return &Router{Engine: r, auth: auth, cors: cors, status: status, registrars: registrars}
+}
+
+// Reload 替换鉴权令牌、跨域策略、日志级别与状态接口输出的配置，并依次调用实现了 Reloadable 的模块；单个模块失败不影响其余模块。
+func (r *Router) Reload(cfg appconfig.Config) error {
+	logging.SetLevel(cfg.Log.Level)
+	r.auth.reload(cfg)
+	r.cors.reload(cfg)
+	r.status.reload(cfg)
+	var errs []error
+	for _, registrar := range r.registrars {
+		reloadable, ok := registrar.(Reloadable)
+		if !ok {
+			continue
+		}
+		if err := reloadable.Reload(cfg); err != nil {
+			errs = append(errs, fmt.Errorf("%T: %w", registrar, err))
+		}
+	}
+	return errors.Join(errs...)
+}
 
-	return r
+// registerOpenAPI 汇总各模块的接口描述并挂载 /openapi.json，文档在启动时生成一次。
+func registerOpenAPI(engine *gin.Engine, registrars []RouteRegistrar) {
+	routes := []openapi.Route{{
+		Method:   http.MethodGet,
+		Path:     "/openapi.json",
+		Summary:  "当前构建的 OpenAPI 3 文档",
+		Tag:      "meta",
+		Response: map[string]any{},
+	}, {
+		Method:   http.MethodGet,
+		Path:     "/admin/status",
+		Summary:  "版本、构建模式、运行时与各模块状态，以及脱敏后的生效配置",
+		Tag:      "admin",
+		Response: statusResponse{},
+	}, {
+		Method:   http.MethodGet,
+		Path:     "/admin/tokens",
+		Summary:  "已配置的桥接令牌（名称、权限、绑定用户与过期时间，不含令牌内容）",
+		Tag:      "admin",
+		Response: tokensResponse{},
+	}}
+	for _, registrar := range registrars {
+		if describer, ok := registrar.(APIDescriber); ok {
+			routes = append(routes, describer.APIRoutes()...)
+		}
+	}
+	doc := openapi.Build(openapi.Info{
+		Title:       "go_bridge",
+		Version:     "1.0",
+		Description: "WebDAV video bridge: SQL, screenshot and media proxy APIs",
+	}, routes, RequiredScope)
+	engine.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
 }
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/pprof"
+	"path"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/buildinfo"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/server/identity"
+)
+
+// StatusReporter 为模块在 /admin/status 中附加运行状态（如连接池计数、磁盘占用）的可选扩展。
+type StatusReporter interface {
+	Status(ctx context.Context) any
+}
+
+// statusResponse 为 /admin/status 的响应。
+type statusResponse struct {
+	Version       string        `json:"version"`
+	Commit        string        `json:"commit"`
+	BuildMode     string        `json:"buildMode"`
+	StartedAt     time.Time     `json:"startedAt"`
+	UptimeSeconds int64         `json:"uptimeSeconds"`
+	Runtime       runtimeStatus `json:"runtime"`
+	// Modules 为当前构建挂载的模块名，ModuleStatus 只包含实现了 StatusReporter 的模块。
+	Modules      []string       `json:"modules"`
+	ModuleStatus map[string]any `json:"moduleStatus"`
+	// Config 以配置路径为键输出生效配置，敏感字段已脱敏。
+	Config map[string]any `json:"config"`
+}
+
+type runtimeStatus struct {
+	GoVersion    string  `json:"goVersion"`
+	OS           string  `json:"os"`
+	Arch         string  `json:"arch"`
+	NumCPU       int     `json:"numCpu"`
+	GOMAXPROCS   int     `json:"gomaxprocs"`
+	Goroutines   int     `json:"goroutines"`
+	HeapAlloc    uint64  `json:"heapAllocBytes"`
+	HeapInuse    uint64  `json:"heapInuseBytes"`
+	Sys          uint64  `json:"sysBytes"`
+	NumGC        uint32  `json:"numGc"`
+	PauseTotalMs float64 `json:"gcPauseTotalMs"`
+}
+
+// statusGate 保存启动时间与当前生效配置，配置热更新后 /admin/status 输出新值。
+type statusGate struct {
+	started    time.Time
+	cfg        atomic.Pointer[appconfig.Config]
+	registrars []RouteRegistrar
+}
+
+func newStatusGate(cfg appconfig.Config, registrars []RouteRegistrar) *statusGate {
+	gate := &statusGate{started: time.Now(), registrars: registrars}
+	gate.reload(cfg)
+	return gate
+}
+
+func (g *statusGate) reload(cfg appconfig.Config) {
+	g.cfg.Store(&cfg)
+}
+
+// register 挂载 /admin/status、/admin/tokens 与 /admin/debug/pprof，均位于 /admin/ 下，需要 admin 权限。
+func (g *statusGate) register(engine *gin.Engine) {
+	engine.GET("/admin/status", func(c *gin.Context) {
+		c.JSON(http.StatusOK, g.snapshot(c.Request.Context()))
+	})
+	engine.GET("/admin/tokens", func(c *gin.Context) {
+		c.JSON(http.StatusOK, g.tokens(time.Now()))
+	})
+
+	debug := engine.Group("/admin/debug/pprof", requireAuthenticated)
+	debug.GET("/", gin.WrapF(pprof.Index))
+	debug.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+	debug.GET("/profile", gin.WrapF(pprof.Profile))
+	debug.GET("/symbol", gin.WrapF(pprof.Symbol))
+	debug.POST("/symbol", gin.WrapF(pprof.Symbol))
+	debug.GET("/trace", gin.WrapF(pprof.Trace))
+	// pprof.Index 按 /debug/pprof/ 前缀解析 profile 名，挂载在其他路径下时需显式分发。
+	debug.GET("/:name", func(c *gin.Context) {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Request)
+	})
+}
+
+// requireAuthenticated 拒绝未开启鉴权时的 pprof 请求，避免在无令牌的部署中暴露堆栈与内存内容。
+func requireAuthenticated(c *gin.Context) {
+	if _, ok := identity.From(c); !ok {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "pprof requires authentication to be enabled"})
+		return
+	}
+	c.Next()
+}
+
+func (g *statusGate) snapshot(ctx context.Context) statusResponse {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	resp := statusResponse{
+		Version:       buildinfo.Version,
+		Commit:        buildinfo.CommitHash(),
+		BuildMode:     buildinfo.Mode,
+		StartedAt:     g.started.UTC(),
+		UptimeSeconds: int64(time.Since(g.started).Seconds()),
+		Runtime: runtimeStatus{
+			GoVersion:    runtime.Version(),
+			OS:           runtime.GOOS,
+			Arch:         runtime.GOARCH,
+			NumCPU:       runtime.NumCPU(),
+			GOMAXPROCS:   runtime.GOMAXPROCS(0),
+			Goroutines:   runtime.NumGoroutine(),
+			HeapAlloc:    mem.HeapAlloc,
+			HeapInuse:    mem.HeapInuse,
+			Sys:          mem.Sys,
+			NumGC:        mem.NumGC,
+			PauseTotalMs: float64(mem.PauseTotalNs) / float64(time.Millisecond),
+		},
+		Modules:      []string{},
+		ModuleStatus: map[string]any{},
+		Config:       appconfig.EffectiveValues(*g.cfg.Load()),
+	}
+	for _, registrar := range g.registrars {
+		if registrar == nil {
+			continue
+		}
+		name := moduleName(registrar)
+		resp.Modules = append(resp.Modules, name)
+		if reporter, ok := registrar.(StatusReporter); ok {
+			resp.ModuleStatus[name] = reporter.Status(ctx)
+		}
+	}
+	return resp
+}
+
+// moduleName 取注册器所在包名作为模块名，例如 sqlapi、proxy。
+func moduleName(registrar RouteRegistrar) string {
+	t := reflect.TypeOf(registrar)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.PkgPath() == "" {
+		return t.String()
+	}
+	return path.Base(t.PkgPath())
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/zhouquan/webdav_video/go_bridge/internal/appconfig"
+	"github.com/zhouquan/webdav_video/go_bridge/internal/buildinfo"
+)
+
+type reportingRegistrar struct{ echoRegistrar }
+
+func (reportingRegistrar) Status(context.Context) any { return map[string]int{"sessions": 3} }
+
+func TestAdminStatusAndPprof(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := appconfig.Config{
+		AuthToken: "legacy-secret",
+		Tokens: []appconfig.APIToken{
+			{Name: "viewer", SecretHash: appconfig.HashTokenSecret("viewer"), Scopes: []string{appconfig.ScopeProxy}},
+		},
+		ScreenshotDir: "data/screenshots",
+	}
+	router := NewRouter(cfg, &reportingRegistrar{})
+	send := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/admin/status", "legacy-secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: %d %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "legacy-secret") || strings.Contains(w.Body.String(), cfg.Tokens[0].SecretHash) {
+		t.Fatalf("status leaks secrets: %s", w.Body.String())
+	}
+	var status statusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if status.BuildMode != buildinfo.Mode || status.Version != buildinfo.Version || status.Runtime.Goroutines == 0 {
+		t.Fatalf("unexpected build/runtime info: %+v", status)
+	}
+	if len(status.Modules) != 1 || status.Modules[0] != "server" {
+		t.Fatalf("unexpected modules: %v", status.Modules)
+	}
+	if _, ok := status.ModuleStatus["server"]; !ok {
+		t.Fatalf("module status missing: %v", status.ModuleStatus)
+	}
+	if status.Config["authToken"] != "******" || status.Config["screenshotDir"] != "data/screenshots" {
+		t.Fatalf("unexpected config: %v", status.Config)
+	}
+
+	if err := router.Reload(appconfig.Config{AuthToken: "legacy-secret", ScreenshotDir: "other"}); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if err := json.Unmarshal(send("/admin/status", "legacy-secret").Body.Bytes(), &status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if status.Config["screenshotDir"] != "other" {
+		t.Fatalf("status should reflect reloaded config, got %v", status.Config["screenshotDir"])
+	}
+
+	if w := send("/admin/debug/pprof/goroutine?debug=1", "legacy-secret"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Fatalf("pprof goroutine: %d", w.Code)
+	}
+	if w := send("/admin/debug/pprof/", "legacy-secret"); w.Code != http.StatusOK {
+		t.Fatalf("pprof index: %d", w.Code)
+	}
+	if err := router.Reload(cfg); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if w := send("/admin/debug/pprof/heap", "viewer"); w.Code != http.StatusForbidden {
+		t.Fatalf("non-admin pprof should be forbidden, got %d", w.Code)
+	}
+
+	open := NewRouter(appconfig.Config{}, echoRegistrar{})
+	req := httptest.NewRequest(http.MethodGet, "/admin/debug/pprof/heap", nil)
+	w = httptest.NewRecorder()
+	open.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("pprof without auth should be forbidden, got %d", w.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package tlsutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CertReloader 持有当前证书，并在证书或私钥文件修改后自动重新加载，
+// 适配 certbot 等工具原地续期的场景。
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewCertReloader 立即加载一次证书，失败时返回错误。
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile, stopCh: make(chan struct{})}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Watch 按 interval 检查文件修改时间，变化时重新加载；加载失败会保留旧证书并记录日志。
+func (r *CertReloader) Watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !r.changed() {
+					continue
+				}
+				if err := r.reload(); err != nil {
+					slog.Warn("tls reload failed, keeping previous certificate", "cert_file", r.certFile, "error", err)
+					continue
+				}
+				slog.Info("tls certificate reloaded", "cert_file", r.certFile)
+			case <-r.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止文件监视。
+func (r *CertReloader) Stop() {
+	r.stopOnce.Do(func() { close(r.stopCh) })
+}
+
+// GetCertificate 供 tls.Config.GetCertificate 使用。
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// GetClientCertificate 供 tls.Config.GetClientCertificate 使用，hop 之间的客户端证书同样支持热更新。
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+func (r *CertReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+func (r *CertReloader) changed() bool {
+	latest := r.latestModTime()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return latest.After(r.modTime)
+}
+
+func (r *CertReloader) reload() error {
+	modTime := r.latestModTime()
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+// LoadCAPool 读取 PEM 格式的 CA 证书集合。
+func LoadCAPool(caFile string) (*x509.CertPool, error) {
+	raw, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
+
+// EnsureSelfSigned 在证书或私钥不存在时生成一张有效期一年的 ECDSA 自签名证书，
+// hosts 中的 IP 与域名会写入 SAN，并始终包含 localhost 与 127.0.0.1。
+// 只存在其中一个文件时返回错误，不会覆盖用户提供的证书或私钥。返回值表示本次是否新生成了证书。
+func EnsureSelfSigned(certFile, keyFile string, hosts []string) (bool, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if certErr == nil && keyErr == nil {
+		return false, nil
+	}
+	if certErr != nil && !errors.Is(certErr, os.ErrNotExist) {
+		return false, certErr
+	}
+	if keyErr != nil && !errors.Is(keyErr, os.ErrNotExist) {
+		return false, keyErr
+	}
+	if certErr == nil {
+		return false, fmt.Errorf("certificate %s exists but key %s is missing", certFile, keyFile)
+	}
+	if keyErr == nil {
+		return false, fmt.Errorf("key %s exists but certificate %s is missing", keyFile, certFile)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return false, fmt.Errorf("generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return false, fmt.Errorf("generate serial: %w", err)
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "go_bridge self-signed", Organization: []string{"alist_video"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range append([]string{"localhost", "127.0.0.1"}, hosts...) {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if host != "" {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return false, fmt.Errorf("create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return false, fmt.Errorf("marshal key: %w", err)
+	}
+
+	for _, dir := range []string{filepath.Dir(certFile), filepath.Dir(keyFile)} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return false, fmt.Errorf("create tls dir: %w", err)
+		}
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		return false, fmt.Errorf("write key: %w", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		return false, fmt.Errorf("write certificate: %w", err)
+	}
+	return true, nil
+}
+
+// ClientConfig 构建连接下一跳时使用的 TLS 配置：可选的客户端证书（支持热更新）、
+// 自定义 CA 与 SNI 名称。返回的 reloader 为 nil 表示未配置客户端证书。
+func ClientConfig(certFile, keyFile, caFile, serverName string) (*tls.Config, *CertReloader, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if caFile != "" {
+		pool, err := LoadCAPool(caFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if certFile == "" && keyFile == "" {
+		return cfg, nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, nil, errors.New("client certFile and keyFile must be set together")
+	}
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.GetClientCertificate = reloader.GetClientCertificate
+	return cfg, reloader, nil
+}
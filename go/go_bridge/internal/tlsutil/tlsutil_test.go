@@ -0,0 +1,131 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedMutualTLSAndReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls", "cert.pem")
+	keyFile := filepath.Join(dir, "tls", "key.pem")
+
+	created, err := EnsureSelfSigned(certFile, keyFile, []string{"hop.example.com"})
+	if err != nil || !created {
+		t.Fatalf("expected certificate to be generated, created=%v err=%v", created, err)
+	}
+	if created, err := EnsureSelfSigned(certFile, keyFile, nil); err != nil || created {
+		t.Fatalf("existing certificate should be kept, created=%v err=%v", created, err)
+	}
+
+	reloader, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("reloader: %v", err)
+	}
+	pool, err := LoadCAPool(certFile)
+	if err != nil {
+		t.Fatalf("ca pool: %v", err)
+	}
+
+	// 自签名证书同时作为服务端证书、客户端证书与双方信任的 CA。
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			http.Error(w, "no client cert", http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.TLS = &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		ClientCAs:      pool,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+	}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	clientCfg, clientReloader, err := ClientConfig(certFile, keyFile, certFile, "localhost")
+	if err != nil || clientReloader == nil {
+		t.Fatalf("client config: %v", err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientCfg}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("mtls request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", resp.StatusCode)
+	}
+
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"}}}
+	if resp, err := anonymous.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Fatalf("request without client certificate should fail")
+	}
+
+	before, _ := reloader.GetCertificate(nil)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Remove(file); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+	}
+	if _, err := EnsureSelfSigned(certFile, keyFile, nil); err != nil {
+		t.Fatalf("regenerate: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	if !reloader.changed() {
+		t.Fatalf("expected reloader to notice modified certificate")
+	}
+	if err := reloader.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	renewed, _ := reloader.GetCertificate(nil)
+	if renewed == before {
+		t.Fatalf("expected renewed certificate after reload")
+	}
+
+	if err := os.WriteFile(certFile, []byte("broken"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := reloader.reload(); err == nil {
+		t.Fatalf("broken certificate should fail to load")
+	}
+	if after, _ := reloader.GetCertificate(nil); after != renewed {
+		t.Fatalf("failed reload must keep previous certificate")
+	}
+}
+
+func TestEnsureSelfSignedKeepsLoneFiles(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, []byte("user certificate"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if created, err := EnsureSelfSigned(certFile, keyFile, nil); err == nil || created {
+		t.Fatalf("missing key must not regenerate the certificate, created=%v err=%v", created, err)
+	}
+	if raw, _ := os.ReadFile(certFile); string(raw) != "user certificate" {
+		t.Fatalf("user certificate was overwritten: %q", raw)
+	}
+
+	if err := os.Rename(certFile, keyFile); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if created, err := EnsureSelfSigned(certFile, keyFile, nil); err == nil || created {
+		t.Fatalf("missing certificate must not overwrite the key, created=%v err=%v", created, err)
+	}
+
+	if _, err := EnsureSelfSigned(certFile, filepath.Join(keyFile, "key.pem"), nil); err == nil {
+		t.Fatalf("unreadable key path should be reported")
+	}
+}